@@ -0,0 +1,203 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"context"
+	"sync"
+)
+
+// MaxRegisterBatch is the largest number of users Easemob registers in one
+// request.
+const MaxRegisterBatch = 60
+
+// defaultRegisterConcurrency is the number of batches BulkRegister sends at
+// once unless told otherwise.
+const defaultRegisterConcurrency = 4
+
+// RegisterStatus is the outcome of registering one user.
+type RegisterStatus int
+
+// Outcomes of registering a user.
+const (
+	RegisterPending RegisterStatus = iota // no response for the user yet
+	RegisterCreated                       // the user was registered
+	RegisterExisted                       // the user already existed
+	RegisterFailed                        // the registration failed
+)
+
+func (s RegisterStatus) String() string {
+	switch s {
+	case RegisterPending:
+		return "pending"
+	case RegisterCreated:
+		return "created"
+	case RegisterExisted:
+		return "existed"
+	case RegisterFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// RegisterResult is the outcome of registering one user in a bulk
+// registration.
+type RegisterResult struct {
+	Username string
+	Status   RegisterStatus
+	User     *User // the registered user, if returned by the server
+	Err      error // why the registration failed
+}
+
+// RegisterReport holds the results of a bulk registration, in the order of
+// the users given.
+type RegisterReport []*RegisterResult
+
+// Usernames returns the usernames of the results with status st.
+func (r RegisterReport) Usernames(st RegisterStatus) []string {
+	var names []string
+	for _, res := range r {
+		if res.Status == st {
+			names = append(names, res.Username)
+		}
+	}
+	return names
+}
+
+// Failed returns the results of the registrations that failed.
+func (r RegisterReport) Failed() []*RegisterResult {
+	var failed []*RegisterResult
+	for _, res := range r {
+		if res.Status == RegisterFailed {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// BulkRegisterOptions specifies the optional parameters to BulkRegister.
+type BulkRegisterOptions struct {
+	// BatchSize is the number of users registered per request, at most
+	// MaxRegisterBatch, which is the default.
+	BatchSize int
+
+	// Concurrency is the number of requests in flight at once. Defaults
+	// to 4.
+	Concurrency int
+
+	// Progress, if set, is called after each batch with the results of
+	// the batch. Calls are serialized.
+	Progress func(batch RegisterReport)
+}
+
+// BulkRegister registers any number of users, each with at least a username
+// and a password, in batches sent concurrently. Easemob rejects a whole
+// batch if one of its users already exists; the users of such a batch are
+// then registered one by one, so that the others still get created.
+//
+// Every user gets a result in the report. The error is only set if ctx was
+// done before all batches were sent, in which case the users of the batches
+// left out are reported as failed with it.
+func (s *UsersService) BulkRegister(ctx context.Context, users []PutOptions, opt *BulkRegisterOptions) (RegisterReport, error) {
+	if opt == nil {
+		opt = &BulkRegisterOptions{}
+	}
+	size := opt.BatchSize
+	if size <= 0 || size > MaxRegisterBatch {
+		size = MaxRegisterBatch
+	}
+	workers := opt.Concurrency
+	if workers <= 0 {
+		workers = defaultRegisterConcurrency
+	}
+
+	report := make(RegisterReport, len(users))
+	for i, u := range users {
+		report[i] = &RegisterResult{Username: u.Username}
+	}
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, workers)
+	)
+	for start := 0; start < len(users); start += size {
+		end := start + size
+		if end > len(users) {
+			end = len(users)
+		}
+		batch, results := users[start:end], report[start:end]
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for _, res := range report[start:] {
+				res.Status, res.Err = RegisterFailed, ctx.Err()
+			}
+			wg.Wait()
+			return report, ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.registerBatch(ctx, batch, results)
+			if opt.Progress != nil {
+				mu.Lock()
+				opt.Progress(results)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return report, nil
+}
+
+// registerBatch registers users in one request, falling back to one request
+// per user if some already exist, and fills in their results.
+func (s *UsersService) registerBatch(ctx context.Context, users []PutOptions, results RegisterReport) {
+	created, err := s.register(ctx, users)
+	switch {
+	case err == nil:
+		byName := make(map[string]*User, len(created))
+		for _, u := range created {
+			byName[u.Username] = u
+		}
+		for _, res := range results {
+			res.Status, res.User = RegisterCreated, byName[res.Username]
+		}
+	case IsDuplicateUser(err) && len(users) > 1:
+		for i := range users {
+			s.registerBatch(ctx, users[i:i+1], results[i:i+1])
+		}
+	case IsDuplicateUser(err):
+		results[0].Status = RegisterExisted
+	default:
+		for _, res := range results {
+			res.Status, res.Err = RegisterFailed, err
+		}
+	}
+}
+
+// register registers users in one request.
+func (s *UsersService) register(ctx context.Context, users []PutOptions) ([]*User, error) {
+	var u string
+	u = "users"
+
+	req, err := s.client.NewRequestContext(ctx, "POST", u, users)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []*User
+	err = resp.decodeEntities(&created)
+	return created, err
+}
@@ -0,0 +1,184 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// registry serves user registration, rejecting a whole request with
+// duplicate_unique_property_exists if one of its users exists, as Easemob
+// does.
+type registry struct {
+	mu      sync.Mutex
+	users   map[string]bool
+	batches []int // size of each request
+}
+
+func handleRegistry(t *testing.T, mux *http.ServeMux, existing ...string) *registry {
+	reg := &registry{users: make(map[string]bool)}
+	for _, u := range existing {
+		reg.users[u] = true
+	}
+	mux.HandleFunc("/org/app/users", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		var users []PutOptions
+		if err := json.NewDecoder(r.Body).Decode(&users); err != nil {
+			t.Errorf("decoding users: %v", err)
+		}
+
+		reg.mu.Lock()
+		defer reg.mu.Unlock()
+		reg.batches = append(reg.batches, len(users))
+		for _, u := range users {
+			if reg.users[u.Username] {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"error":"duplicate_unique_property_exists","error_description":"Entity user requires that property named username be unique, value of %v exists"}`, u.Username)
+				return
+			}
+		}
+		var entities []map[string]string
+		for _, u := range users {
+			reg.users[u.Username] = true
+			entities = append(entities, map[string]string{"username": u.Username, "uuid": "uuid-" + u.Username})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"entities": entities})
+	})
+	return reg
+}
+
+func newUsers(n int) []PutOptions {
+	users := make([]PutOptions, n)
+	for i := range users {
+		users[i] = PutOptions{Username: fmt.Sprintf("u%03d", i), Password: "pw"}
+	}
+	return users
+}
+
+func TestUsersService_BulkRegister(t *testing.T) {
+	client, mux := setup(t)
+	reg := handleRegistry(t, mux)
+	users := newUsers(130)
+
+	var progress int
+	report, err := client.Users.BulkRegister(context.Background(), users, &BulkRegisterOptions{
+		Progress: func(batch RegisterReport) { progress += len(batch) },
+	})
+	if err != nil {
+		t.Fatalf("BulkRegister returned error: %v", err)
+	}
+	if want := []int{10, 60, 60}; !reflect.DeepEqual(sortedInts(reg.batches), want) {
+		t.Errorf("request sizes = %v, want %v", reg.batches, want)
+	}
+	if progress != len(users) {
+		t.Errorf("Progress saw %d results, want %d", progress, len(users))
+	}
+	for i, res := range report {
+		if res.Username != users[i].Username || res.Status != RegisterCreated {
+			t.Fatalf("report[%d] = %+v, want %v created", i, res, users[i].Username)
+		}
+		if res.User == nil || res.User.Uuid != "uuid-"+res.Username {
+			t.Errorf("report[%d].User = %+v", i, res.User)
+		}
+	}
+}
+
+func TestUsersService_BulkRegister_duplicate(t *testing.T) {
+	client, mux := setup(t)
+	reg := handleRegistry(t, mux, "u001", "u003")
+	users := newUsers(5)
+
+	report, err := client.Users.BulkRegister(context.Background(), users, &BulkRegisterOptions{BatchSize: 3})
+	if err != nil {
+		t.Fatalf("BulkRegister returned error: %v", err)
+	}
+	// The first batch of 3 is rejected and retried user by user; so is the
+	// second, of 2.
+	if got, want := sortedInts(reg.batches), []int{1, 1, 1, 1, 1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("request sizes = %v, want %v", got, want)
+	}
+	if got, want := report.Usernames(RegisterCreated), []string{"u000", "u002", "u004"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("created %v, want %v", got, want)
+	}
+	if got, want := report.Usernames(RegisterExisted), []string{"u001", "u003"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("existed %v, want %v", got, want)
+	}
+	if failed := report.Failed(); len(failed) != 0 {
+		t.Errorf("failed %v", failed)
+	}
+}
+
+func TestUsersService_BulkRegister_failed(t *testing.T) {
+	client, mux := setup(t)
+	mux.HandleFunc("/org/app/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"illegal_argument"}`)
+	})
+
+	report, err := client.Users.BulkRegister(context.Background(), newUsers(3), nil)
+	if err != nil {
+		t.Fatalf("BulkRegister returned error: %v", err)
+	}
+	failed := report.Failed()
+	if len(failed) != 3 {
+		t.Fatalf("%d failed, want 3", len(failed))
+	}
+	if failed[0].Err == nil {
+		t.Errorf("failed result has no error")
+	}
+}
+
+func TestUsersService_BulkRegister_canceled(t *testing.T) {
+	client, mux := setup(t)
+	reg := handleRegistry(t, mux)
+	users := newUsers(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	report, err := client.Users.BulkRegister(ctx, users, &BulkRegisterOptions{
+		BatchSize:   2,
+		Concurrency: 1,
+		Progress: func(batch RegisterReport) {
+			if batch[0].Username == "u002" {
+				cancel()
+			}
+		},
+	})
+	if err != context.Canceled {
+		t.Fatalf("BulkRegister returned %v, want context.Canceled", err)
+	}
+	if got, want := report.Usernames(RegisterCreated), []string{"u000", "u001", "u002", "u003"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("created %v, want %v", got, want)
+	}
+	if got := len(reg.batches); got != 2 {
+		t.Errorf("sent %d requests, want 2", got)
+	}
+	for _, res := range report[4:] {
+		if res.Status != RegisterFailed || res.Err != context.Canceled {
+			t.Errorf("result %+v, want failed with context.Canceled", res)
+		}
+	}
+}
+
+func TestRegisterStatus_zero(t *testing.T) {
+	var res RegisterResult
+	if res.Status != RegisterPending || res.Status.String() != "pending" {
+		t.Errorf("zero status = %v, want pending", res.Status)
+	}
+}
+
+func sortedInts(a []int) []int {
+	b := append([]int(nil), a...)
+	sort.Ints(b)
+	return b
+}
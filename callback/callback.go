@@ -0,0 +1,322 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package callback receives the callbacks Easemob sends to an app server:
+// messages, users logging in and out, and group and chatroom events.
+//
+// A Handler serves post-send callbacks, made after the fact, and
+// dispatches them to the funcs registered for each kind of event:
+//
+//	h := callback.NewHandler(secret)
+//	h.OnMessage(func(ctx context.Context, m *callback.Message) error {
+//		log.Printf("%v to %v: %v bodies", m.From, m.To, len(m.Payload.Bodies))
+//		return nil
+//	})
+//	h.OnPresence(func(ctx context.Context, p *callback.Presence) error {
+//		return markOnline(p.User, p.Online())
+//	})
+//	http.Handle("/easemob/callback", h)
+//
+// A PreSendHandler serves pre-send callbacks, made before a message is
+// delivered, and replies whether to deliver it, possibly modified:
+//
+//	http.Handle("/easemob/presend", callback.NewPreSendHandler(secret,
+//		func(ctx context.Context, m *callback.Message) (*callback.Reply, error) {
+//			if spam(m) {
+//				return callback.Deny("spam"), nil
+//			}
+//			return callback.Allow(), nil
+//		}))
+//
+// Moderation makes such decisions with a chain of moderators, such as
+// keyword filters and rate limits, and falls back to a default decision
+// when they take too long.
+//
+// Both check the signature Easemob computes from the secret configured for
+// the callback rule, and reject callbacks whose signature does not match.
+package callback
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/weilixu7/easemob"
+)
+
+// maxBodySize is the largest callback body read.
+const maxBodySize = 1 << 20
+
+// ErrSignature is returned by Verify for callbacks without a valid
+// signature.
+var ErrSignature = errors.New("callback: invalid signature")
+
+// Signature returns the signature of a callback, the hex MD5 digest of its
+// call id, the secret and its timestamp.
+func Signature(callID, secret string, timestamp int64) string {
+	sum := md5.Sum([]byte(callID + secret + strconv.FormatInt(timestamp, 10)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks the signature of the callback e against secret.
+func Verify(e Event, secret string) error {
+	env := e.envelope()
+	want := Signature(env.CallID, secret, env.Timestamp)
+	if subtle.ConstantTimeCompare([]byte(want), []byte(env.Security)) != 1 {
+		return ErrSignature
+	}
+	return nil
+}
+
+// receiver reads, decodes and verifies callbacks, for both handlers.
+type receiver struct {
+	secret string
+
+	// maxAge is how old a callback may be, or zero for no limit.
+	maxAge time.Duration
+
+	// errorLog, if set, is called with the callbacks rejected or failing.
+	errorLog func(r *http.Request, err error)
+}
+
+// receive reads the callback of r. It replies to the failed requests itself
+// and returns a nil event for them.
+func (rc *receiver) receive(w http.ResponseWriter, r *http.Request) Event {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		rc.fail(w, r, http.StatusBadRequest, err)
+		return nil
+	}
+	if len(body) > maxBodySize {
+		rc.fail(w, r, http.StatusRequestEntityTooLarge, errors.New("callback: body too large"))
+		return nil
+	}
+	e, err := Decode(body)
+	if err != nil {
+		rc.fail(w, r, http.StatusBadRequest, err)
+		return nil
+	}
+	if rc.secret != "" {
+		if err := Verify(e, rc.secret); err != nil {
+			rc.fail(w, r, http.StatusForbidden, err)
+			return nil
+		}
+	}
+	if rc.maxAge > 0 {
+		if age := time.Since(e.envelope().Time()); age > rc.maxAge || age < -rc.maxAge {
+			rc.fail(w, r, http.StatusForbidden, fmt.Errorf("callback: stale callback, sent %v ago", age))
+			return nil
+		}
+	}
+	return e
+}
+
+func (rc *receiver) fail(w http.ResponseWriter, r *http.Request, code int, err error) {
+	if rc.errorLog != nil {
+		rc.errorLog(r, err)
+	}
+	http.Error(w, http.StatusText(code), code)
+}
+
+// A Handler is an http.Handler serving post-send callbacks. It dispatches
+// each callback to the func registered for its kind of event, and replies
+// with a 500 status, for Easemob to retry, if the func fails.
+//
+// Funcs may be registered at any time, but MaxAge and ErrorLog are set
+// before the handler serves requests.
+type Handler struct {
+	rc receiver
+
+	mu        sync.RWMutex
+	onMessage func(context.Context, *Message) error
+	onPres    func(context.Context, *Presence) error
+	onGroup   func(context.Context, *GroupEvent) error
+	onUnknown func(context.Context, *Unknown) error
+}
+
+// NewHandler returns a handler of the callbacks signed with secret. If
+// secret is empty, signatures are not checked.
+func NewHandler(secret string) *Handler {
+	return &Handler{rc: receiver{secret: secret}}
+}
+
+// MaxAge makes the handler reject callbacks sent longer than d ago, or as
+// far in the future, as replays. Easemob retries failed callbacks for a
+// while, so d should leave room for them. The default is no limit.
+func (h *Handler) MaxAge(d time.Duration) {
+	h.rc.maxAge = d
+}
+
+// ErrorLog sets a func called with each callback rejected or failing.
+func (h *Handler) ErrorLog(f func(r *http.Request, err error)) {
+	h.rc.errorLog = f
+}
+
+// OnMessage registers the func handling messages.
+func (h *Handler) OnMessage(f func(ctx context.Context, m *Message) error) {
+	h.mu.Lock()
+	h.onMessage = f
+	h.mu.Unlock()
+}
+
+// OnPresence registers the func handling users logging in and out.
+func (h *Handler) OnPresence(f func(ctx context.Context, p *Presence) error) {
+	h.mu.Lock()
+	h.onPres = f
+	h.mu.Unlock()
+}
+
+// OnGroupEvent registers the func handling group and chatroom events.
+func (h *Handler) OnGroupEvent(f func(ctx context.Context, g *GroupEvent) error) {
+	h.mu.Lock()
+	h.onGroup = f
+	h.mu.Unlock()
+}
+
+// OnUnknown registers the func handling callbacks of other kinds.
+func (h *Handler) OnUnknown(f func(ctx context.Context, u *Unknown) error) {
+	h.mu.Lock()
+	h.onUnknown = f
+	h.mu.Unlock()
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e := h.rc.receive(w, r)
+	if e == nil {
+		return
+	}
+	if err := h.dispatch(r.Context(), e); err != nil {
+		h.rc.fail(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// dispatch calls the func registered for e. Events with no func are
+// acknowledged and dropped.
+func (h *Handler) dispatch(ctx context.Context, e Event) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	switch e := e.(type) {
+	case *Message:
+		if h.onMessage != nil {
+			return h.onMessage(ctx, e)
+		}
+	case *Presence:
+		if h.onPres != nil {
+			return h.onPres(ctx, e)
+		}
+	case *GroupEvent:
+		if h.onGroup != nil {
+			return h.onGroup(ctx, e)
+		}
+	case *Unknown:
+		if h.onUnknown != nil {
+			return h.onUnknown(ctx, e)
+		}
+	}
+	return nil
+}
+
+// Reply is the answer to a pre-send callback.
+type Reply struct {
+	// Valid tells whether to deliver the message.
+	Valid bool `json:"valid"`
+
+	// Code is the reason a message is not delivered, passed on to its
+	// sender.
+	Code string `json:"code,omitempty"`
+
+	// Payload, if set, is delivered in place of the message's own.
+	Payload *easemob.MessagePayload `json:"payload,omitempty"`
+}
+
+// Allow returns a reply delivering the message as it is.
+func Allow() *Reply {
+	return &Reply{Valid: true}
+}
+
+// Deny returns a reply dropping the message for the reason code.
+func Deny(code string) *Reply {
+	return &Reply{Valid: false, Code: code}
+}
+
+// Modify returns a reply delivering p in place of the message's payload.
+func Modify(p *easemob.MessagePayload) *Reply {
+	return &Reply{Valid: true, Payload: p}
+}
+
+// A PreSendFunc decides whether to deliver a message.
+type PreSendFunc func(ctx context.Context, m *Message) (*Reply, error)
+
+// A PreSendHandler is an http.Handler serving pre-send callbacks. It
+// replies with the decision of its func on each message. If the func
+// fails, it replies with a 500 status, leaving the decision to the policy
+// of the callback rule. Callbacks other than messages are allowed.
+type PreSendHandler struct {
+	rc receiver
+	f  PreSendFunc
+}
+
+// NewPreSendHandler returns a handler of the pre-send callbacks signed with
+// secret, deciding with f. If secret is empty, signatures are not checked.
+func NewPreSendHandler(secret string, f PreSendFunc) *PreSendHandler {
+	return &PreSendHandler{rc: receiver{secret: secret}, f: f}
+}
+
+// MaxAge is like Handler.MaxAge.
+func (h *PreSendHandler) MaxAge(d time.Duration) {
+	h.rc.maxAge = d
+}
+
+// ErrorLog is like Handler.ErrorLog.
+func (h *PreSendHandler) ErrorLog(f func(r *http.Request, err error)) {
+	h.rc.errorLog = f
+}
+
+// ServeHTTP implements http.Handler.
+func (h *PreSendHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e := h.rc.receive(w, r)
+	if e == nil {
+		return
+	}
+
+	reply := Allow()
+	if m, ok := e.(*Message); ok {
+		var err error
+		reply, err = h.f(r.Context(), m)
+		if err == nil && reply == nil {
+			err = errors.New("callback: nil reply")
+		}
+		if err != nil {
+			h.rc.fail(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		h.rc.fail(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,325 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weilixu7/easemob"
+)
+
+const testSecret = "s3cret"
+
+// signed returns the callback body holding fields, signed with secret as
+// of t.
+func signed(secret string, t time.Time, fields map[string]interface{}) string {
+	ts := t.UnixNano() / int64(time.Millisecond)
+	body := map[string]interface{}{
+		"callId":    "call-1",
+		"timestamp": ts,
+		"appkey":    "org#app",
+		"security":  Signature("call-1", secret, ts),
+	}
+	for k, v := range fields {
+		body[k] = v
+	}
+	data, _ := json.Marshal(body)
+	return string(data)
+}
+
+var chatFields = map[string]interface{}{
+	"eventType": "chat",
+	"chat_type": "chat",
+	"msg_id":    "m1",
+	"from":      "alice",
+	"to":        "bob",
+	"payload":   map[string]interface{}{"bodies": []interface{}{map[string]string{"type": "txt", "msg": "hello"}}},
+}
+
+func post(h http.Handler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/callback", strings.NewReader(body)))
+	return w
+}
+
+func TestSignature(t *testing.T) {
+	// md5("call-1" + "s3cret" + "1500000000000")
+	if got, want := Signature("call-1", testSecret, 1500000000000), "210bf5a91f0ff766ccbd0c204e274754"; got != want {
+		t.Errorf("Signature = %q, want %q", got, want)
+	}
+}
+
+func TestHandler_signature(t *testing.T) {
+	now := time.Now()
+	tampered := func(field string, v interface{}) string {
+		var m map[string]interface{}
+		json.Unmarshal([]byte(signed(testSecret, now, chatFields)), &m)
+		m[field] = v
+		data, _ := json.Marshal(m)
+		return string(data)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		want   int
+	}{
+		{"valid", testSecret, signed(testSecret, now, chatFields), http.StatusOK},
+		{"other secret", testSecret, signed("other", now, chatFields), http.StatusForbidden},
+		{"tampered call id", testSecret, tampered("callId", "call-2"), http.StatusForbidden},
+		{"tampered timestamp", testSecret, tampered("timestamp", now.Add(time.Second).UnixNano()/int64(time.Millisecond)), http.StatusForbidden},
+		{"tampered signature", testSecret, tampered("security", strings.Repeat("0", 32)), http.StatusForbidden},
+		{"unsigned", testSecret, tampered("security", ""), http.StatusForbidden},
+		{"not checked", "", signed("other", now, chatFields), http.StatusOK},
+		{"within max age", testSecret, signed(testSecret, now.Add(-time.Minute), chatFields), http.StatusOK},
+		{"expired", testSecret, signed(testSecret, now.Add(-10*time.Minute), chatFields), http.StatusForbidden},
+		{"from the future", testSecret, signed(testSecret, now.Add(10*time.Minute), chatFields), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		var handled, logged bool
+		h := NewHandler(tt.secret)
+		h.MaxAge(5 * time.Minute)
+		h.ErrorLog(func(r *http.Request, err error) { logged = true })
+		h.OnMessage(func(ctx context.Context, m *Message) error {
+			handled = true
+			return nil
+		})
+
+		w := post(h, tt.body)
+		if w.Code != tt.want {
+			t.Errorf("%v: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if ok := tt.want == http.StatusOK; handled != ok || logged == ok {
+			t.Errorf("%v: handled %v, logged %v", tt.name, handled, logged)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	e, err := Decode([]byte(signed(testSecret, time.Now(), chatFields)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(e, testSecret); err != nil {
+		t.Errorf("Verify returned %v", err)
+	}
+	if err := Verify(e, "other"); !errors.Is(err, ErrSignature) {
+		t.Errorf("Verify with another secret returned %v, want ErrSignature", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	ts := time.Unix(1500000000, 0)
+	env := func(eventType string) Envelope {
+		return Envelope{
+			CallID:    "call-1",
+			EventType: eventType,
+			Timestamp: 1500000000000,
+			AppKey:    "org#app",
+			Security:  Signature("call-1", testSecret, 1500000000000),
+		}
+	}
+	payload := easemob.MessagePayload{Bodies: []easemob.MessageBody{&easemob.TextMessage{Msg: "hello"}}}
+	offline := map[string]interface{}{}
+	for k, v := range chatFields {
+		offline[k] = v
+	}
+	offline["eventType"] = "chat_offline"
+	offline["chat_type"] = "groupchat"
+	offline["group_id"] = "g1"
+
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		want   Event
+	}{
+		{"chat", chatFields, &Message{
+			Envelope: env("chat"), MsgID: "m1", ChatType: ChatTypeChat,
+			From: "alice", To: "bob", Payload: payload,
+		}},
+		{"chat_offline", offline, &Message{
+			Envelope: env("chat_offline"), MsgID: "m1", ChatType: ChatTypeGroup,
+			From: "alice", To: "bob", GroupID: "g1", Payload: payload,
+		}},
+		{"userStatus", map[string]interface{}{
+			"eventType": "userStatus",
+			"user":      "org#app_alice/android_1",
+			"status":    "offline",
+			"reason":    "replaced",
+			"os":        "android",
+		}, &Presence{
+			Envelope: env("userStatus"), User: "alice", Resource: "android_1",
+			Status: "offline", Reason: "replaced", OS: "android",
+		}},
+		{"muc", map[string]interface{}{
+			"eventType": "chat",
+			"chat_type": "muc",
+			"from":      "alice",
+			"payload": map[string]interface{}{
+				"muc_id":      "org#app_42@conference.easemob.com",
+				"is_chatroom": true,
+				"operation":   "kick",
+				"reason":      "spam",
+			},
+		}, &GroupEvent{
+			Envelope: env("chat"), From: "alice", GroupID: "42",
+			Chatroom: true, Operation: "kick", Reason: "spam",
+		}},
+	}
+	for _, tt := range tests {
+		body := signed(testSecret, ts, tt.fields)
+		e, err := Decode([]byte(body))
+		if err != nil {
+			t.Errorf("%v: Decode returned error: %v", tt.name, err)
+			continue
+		}
+		if g, ok := e.(*GroupEvent); ok {
+			g.Payload = nil
+		}
+		if !reflect.DeepEqual(e, tt.want) {
+			t.Errorf("%v: Decode = %+v, want %+v", tt.name, e, tt.want)
+		}
+	}
+
+	if m, _ := Decode([]byte(signed(testSecret, ts, offline))); !m.(*Message).Offline() {
+		t.Errorf("chat_offline message not Offline")
+	}
+
+	body := signed(testSecret, ts, map[string]interface{}{"eventType": "recall"})
+	e, err := Decode([]byte(body))
+	if err != nil {
+		t.Fatalf("Decode of an unknown event returned error: %v", err)
+	}
+	if u, ok := e.(*Unknown); !ok || u.EventType != "recall" || string(u.Raw) != body {
+		t.Errorf("Decode of an unknown event = %+v", e)
+	}
+
+	if _, err := Decode([]byte(`{"eventType":"chat","payload":{"bodies":[1]}}`)); err == nil {
+		t.Errorf("Decode of a malformed message returned no error")
+	}
+	if _, err := Decode([]byte(`not json`)); err == nil {
+		t.Errorf("Decode of garbage returned no error")
+	}
+}
+
+func TestHandler_dispatch(t *testing.T) {
+	var got []string
+	h := NewHandler(testSecret)
+	h.OnMessage(func(ctx context.Context, m *Message) error {
+		got = append(got, "message "+m.From)
+		return nil
+	})
+	h.OnPresence(func(ctx context.Context, p *Presence) error {
+		got = append(got, fmt.Sprintf("presence %v %v", p.User, p.Online()))
+		return nil
+	})
+	h.OnGroupEvent(func(ctx context.Context, g *GroupEvent) error {
+		got = append(got, "group "+g.Operation)
+		return errors.New("database down")
+	})
+
+	now := time.Now()
+	bodies := []struct {
+		fields map[string]interface{}
+		want   int
+	}{
+		{chatFields, http.StatusOK},
+		{map[string]interface{}{"eventType": "userStatus", "user": "org#app_bob/ios", "status": "online"}, http.StatusOK},
+		{map[string]interface{}{"eventType": "chat", "chat_type": "muc", "payload": map[string]interface{}{"operation": "join"}}, http.StatusInternalServerError},
+		// No func registered for unknown events: acknowledged and dropped.
+		{map[string]interface{}{"eventType": "recall"}, http.StatusOK},
+	}
+	for _, b := range bodies {
+		if w := post(h, signed(testSecret, now, b.fields)); w.Code != b.want {
+			t.Errorf("%v: status %d, want %d", b.fields["eventType"], w.Code, b.want)
+		}
+	}
+	if want := []string{"message alice", "presence bob true", "group join"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dispatched %v, want %v", got, want)
+	}
+
+	var unknown string
+	h.OnUnknown(func(ctx context.Context, u *Unknown) error {
+		unknown = u.EventType
+		return nil
+	})
+	post(h, signed(testSecret, now, map[string]interface{}{"eventType": "recall"}))
+	if unknown != "recall" {
+		t.Errorf("OnUnknown got %q", unknown)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/callback", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		t.Errorf("GET: status %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+	if w := post(h, "{"); w.Code != http.StatusBadRequest {
+		t.Errorf("malformed body: status %d, want 400", w.Code)
+	}
+	if w := post(h, `{"eventType":"chat","padding":"`+strings.Repeat("x", maxBodySize)+`"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large body: status %d, want 413", w.Code)
+	}
+}
+
+func TestPreSendHandler_reply(t *testing.T) {
+	masked := &easemob.MessagePayload{Bodies: []easemob.MessageBody{&easemob.TextMessage{Msg: "***"}}}
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		reply  *Reply
+		err    error
+		status int
+		want   string
+	}{
+		{"allow", chatFields, Allow(), nil, http.StatusOK, `{"valid":true}`},
+		{"deny", chatFields, Deny("spam"), nil, http.StatusOK, `{"valid":false,"code":"spam"}`},
+		{"modify", chatFields, Modify(masked), nil, http.StatusOK,
+			`{"valid":true,"payload":{"bodies":[{"msg":"***","type":"txt"}]}}`},
+		{"not a message", map[string]interface{}{"eventType": "userStatus"}, Deny("unused"), nil, http.StatusOK, `{"valid":true}`},
+		{"error", chatFields, nil, errors.New("filter down"), http.StatusInternalServerError, ""},
+		{"nil reply", chatFields, nil, nil, http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		h := NewPreSendHandler(testSecret, func(ctx context.Context, m *Message) (*Reply, error) {
+			if m.From != "alice" {
+				t.Errorf("%v: func got %+v", tt.name, m)
+			}
+			return tt.reply, tt.err
+		})
+		w := post(h, signed(testSecret, time.Now(), tt.fields))
+		if w.Code != tt.status {
+			t.Errorf("%v: status %d, want %d", tt.name, w.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("%v: reply %s, want %s", tt.name, got, tt.want)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%v: Content-Type %q", tt.name, ct)
+		}
+	}
+
+	h := NewPreSendHandler(testSecret, func(ctx context.Context, m *Message) (*Reply, error) {
+		t.Errorf("func called for a forged callback")
+		return Allow(), nil
+	})
+	if w := post(h, signed("other", time.Now(), chatFields)); w.Code != http.StatusForbidden {
+		t.Errorf("forged callback: status %d, want 403", w.Code)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/weilixu7/easemob"
+)
+
+// Event types, as found in the "eventType" field of a callback.
+const (
+	EventChat        = "chat"         // a message, delivered online
+	EventChatOffline = "chat_offline" // a message, stored for an offline user
+	EventUserStatus  = "userStatus"   // a user logged in or out
+)
+
+// Chat types, as found in the "chat_type" field of a callback.
+const (
+	ChatTypeChat     = "chat"      // one-to-one chat
+	ChatTypeGroup    = "groupchat" // group chat
+	ChatTypeChatroom = "chatroom"  // chatroom
+	ChatTypeMUC      = "muc"       // group or chatroom event
+)
+
+// An Event is a decoded callback: a *Message, *Presence, *GroupEvent or
+// *Unknown.
+type Event interface {
+	envelope() *Envelope
+}
+
+// Envelope holds the fields common to all callbacks.
+type Envelope struct {
+	CallID    string `json:"callId"`
+	EventType string `json:"eventType"`
+	Timestamp int64  `json:"timestamp"` // milliseconds since the epoch
+	AppKey    string `json:"appkey"`    // "org#app"
+	Host      string `json:"host,omitempty"`
+	Security  string `json:"security"` // signature of the callback
+}
+
+func (e *Envelope) envelope() *Envelope { return e }
+
+// Time returns the time of the callback.
+func (e *Envelope) Time() time.Time {
+	return time.Unix(0, e.Timestamp*int64(time.Millisecond))
+}
+
+// Message is a message sent in the app.
+type Message struct {
+	Envelope
+	MsgID    string                 `json:"msg_id"`
+	ChatType string                 `json:"chat_type"` // ChatTypeChat, ChatTypeGroup or ChatTypeChatroom
+	From     string                 `json:"from"`
+	To       string                 `json:"to"`                 // username, or group or chatroom id
+	GroupID  string                 `json:"group_id,omitempty"` // for group and chatroom messages
+	Payload  easemob.MessagePayload `json:"payload"`
+}
+
+// Offline reports whether the message was stored for an offline recipient
+// rather than delivered.
+func (m *Message) Offline() bool {
+	return m.EventType == EventChatOffline
+}
+
+// Presence is a user logging in or out.
+type Presence struct {
+	Envelope
+	User     string `json:"-"`      // username
+	Resource string `json:"-"`      // device the user connected from
+	Status   string `json:"status"` // "online" or "offline"
+	Reason   string `json:"reason"` // "login", "logout" or "replaced"
+	OS       string `json:"os,omitempty"`
+	IP       string `json:"ip,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// Online reports whether the user came online.
+func (p *Presence) Online() bool {
+	return p.Status == "online"
+}
+
+// GroupEvent is an operation on a group or chatroom, such as its creation
+// or a member joining or leaving.
+type GroupEvent struct {
+	Envelope
+	From      string `json:"from"`
+	GroupID   string `json:"-"` // group or chatroom id
+	Chatroom  bool   `json:"-"` // whether GroupID is a chatroom
+	Operation string `json:"-"` // "create", "destroy", "join", "leave", "kick", ...
+	Reason    string `json:"-"`
+
+	// Payload is the raw payload of the event, for the fields specific to
+	// each operation.
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Unknown is a callback of a type this package does not decode.
+type Unknown struct {
+	Envelope
+	Raw json.RawMessage `json:"-"`
+}
+
+// Decode decodes the body of a callback into its typed event.
+func Decode(data []byte) (Event, error) {
+	var probe struct {
+		Envelope
+		ChatType string `json:"chat_type"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("callback: decoding event: %w", err)
+	}
+
+	switch {
+	case probe.ChatType == ChatTypeMUC:
+		return decodeGroupEvent(data)
+	case probe.EventType == EventUserStatus:
+		return decodePresence(data)
+	case probe.EventType == EventChat || probe.EventType == EventChatOffline:
+		m := new(Message)
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, fmt.Errorf("callback: decoding message: %w", err)
+		}
+		return m, nil
+	}
+	return &Unknown{Envelope: probe.Envelope, Raw: append(json.RawMessage(nil), data...)}, nil
+}
+
+func decodePresence(data []byte) (*Presence, error) {
+	var v struct {
+		Presence
+		User string `json:"user"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("callback: decoding presence: %w", err)
+	}
+	p := &v.Presence
+	// Users come as "org#app_username/resource".
+	user := strings.TrimPrefix(v.User, p.AppKey+"_")
+	if i := strings.IndexByte(user, '/'); i >= 0 {
+		user, p.Resource = user[:i], user[i+1:]
+	}
+	p.User = user
+	return p, nil
+}
+
+func decodeGroupEvent(data []byte) (*GroupEvent, error) {
+	g := new(GroupEvent)
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, fmt.Errorf("callback: decoding group event: %w", err)
+	}
+	var p struct {
+		MucID      string `json:"muc_id"`
+		IsChatroom bool   `json:"is_chatroom"`
+		Operation  string `json:"operation"`
+		Reason     string `json:"reason"`
+	}
+	if len(g.Payload) > 0 {
+		if err := json.Unmarshal(g.Payload, &p); err != nil {
+			return nil, fmt.Errorf("callback: decoding group event: %w", err)
+		}
+	}
+	// Groups come as "org#app_groupid@conference.easemob.com".
+	id := strings.TrimPrefix(p.MucID, g.AppKey+"_")
+	if i := strings.IndexByte(id, '@'); i >= 0 {
+		id = id[:i]
+	}
+	g.GroupID, g.Chatroom, g.Operation, g.Reason = id, p.IsChatroom, p.Operation, p.Reason
+	return g, nil
+}
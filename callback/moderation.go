@@ -0,0 +1,354 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/weilixu7/easemob"
+)
+
+// defaultModerationTimeout is the time Moderation leaves its moderator
+// unless told otherwise.
+const defaultModerationTimeout = time.Second
+
+// Reasons given by the built-in middlewares for rejecting a message.
+const (
+	CodeFiltered    = "filtered"     // the message matched a filter
+	CodeRateLimited = "rate_limited" // the sender sent too many messages
+)
+
+// Verdict is what to do with a message.
+type Verdict int
+
+// Verdicts on a message.
+const (
+	Pass    Verdict = iota // deliver the message as it is
+	Reject                 // drop the message
+	Replace                // deliver another payload in its place
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Pass:
+		return "pass"
+	case Reject:
+		return "reject"
+	case Replace:
+		return "replace"
+	}
+	return "unknown"
+}
+
+// A Decision is the verdict of a moderator on a message.
+type Decision struct {
+	Verdict Verdict
+
+	// Code is the reason of a Reject, passed on to the sender.
+	Code string
+
+	// Payload is the payload delivered on Replace.
+	Payload *easemob.MessagePayload
+}
+
+// PassDecision is the decision to deliver a message as it is.
+var PassDecision = Decision{Verdict: Pass}
+
+// RejectDecision returns the decision to drop a message for the reason code.
+func RejectDecision(code string) Decision {
+	return Decision{Verdict: Reject, Code: code}
+}
+
+// ReplaceDecision returns the decision to deliver p in place of a message's
+// payload.
+func ReplaceDecision(p *easemob.MessagePayload) Decision {
+	return Decision{Verdict: Replace, Payload: p}
+}
+
+// Reply returns the reply to a pre-send callback carrying d.
+func (d Decision) Reply() *Reply {
+	switch d.Verdict {
+	case Reject:
+		return Deny(d.Code)
+	case Replace:
+		return Modify(d.Payload)
+	}
+	return Allow()
+}
+
+// A Moderator decides what to do with messages.
+type Moderator interface {
+	Moderate(ctx context.Context, m *Message) (Decision, error)
+}
+
+// ModeratorFunc adapts a func to a Moderator.
+type ModeratorFunc func(ctx context.Context, m *Message) (Decision, error)
+
+// Moderate calls f(ctx, m).
+func (f ModeratorFunc) Moderate(ctx context.Context, m *Message) (Decision, error) {
+	return f(ctx, m)
+}
+
+// passAll is the moderator passing every message.
+var passAll = ModeratorFunc(func(context.Context, *Message) (Decision, error) {
+	return PassDecision, nil
+})
+
+// A Middleware wraps a moderator, deciding on messages itself or handing
+// them, possibly modified, to the next one.
+type Middleware func(next Moderator) Moderator
+
+// Chain returns a moderator running messages through mw in order, then to
+// final. If final is nil, the messages passing all middlewares are passed.
+func Chain(final Moderator, mw ...Middleware) Moderator {
+	if final == nil {
+		final = passAll
+	}
+	for i := len(mw) - 1; i >= 0; i-- {
+		final = mw[i](final)
+	}
+	return final
+}
+
+// Moderation answers pre-send callbacks with the decisions of a moderator,
+// within a time limit:
+//
+//	mod := &callback.Moderation{
+//		Moderator: callback.Chain(nil,
+//			callback.RateLimit(5, 10),
+//			callback.KeywordFilter(callback.FilterReject, "spam", "scam"),
+//			callback.RegexFilter(callback.FilterMask, regexp.MustCompile(`\d{11}`)),
+//		),
+//		Timeout:  500 * time.Millisecond,
+//		Fallback: callback.PassDecision,
+//	}
+//	http.Handle("/easemob/presend", callback.NewPreSendHandler(secret, mod.PreSend))
+//
+// Easemob only waits a short while for the reply to a pre-send callback
+// before applying the policy of the callback rule. When the moderator takes
+// longer than Timeout, fails or panics, Moderation replies with the
+// Fallback decision rather than let the callback time out.
+type Moderation struct {
+	Moderator Moderator
+
+	// Timeout is how long the moderator has to decide. Defaults to one
+	// second. It must leave room for the reply to reach Easemob in time.
+	Timeout time.Duration
+
+	// Fallback is the decision replied when the moderator does not
+	// decide in time or fails. The zero value passes the message.
+	Fallback Decision
+
+	// OnFallback, if set, is called with the message and the reason each
+	// time the Fallback decision is used.
+	OnFallback func(m *Message, err error)
+}
+
+// PreSend decides on m; it is a PreSendFunc. It only fails if there is no
+// moderator.
+func (mo *Moderation) PreSend(ctx context.Context, m *Message) (*Reply, error) {
+	if mo.Moderator == nil {
+		return nil, errors.New("callback: Moderation without a Moderator")
+	}
+	timeout := mo.Timeout
+	if timeout <= 0 {
+		timeout = defaultModerationTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		d   Decision
+		err error
+	}
+	// The channel is buffered so that a moderator ignoring ctx can still
+	// deliver its late decision and end.
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				done <- result{err: fmt.Errorf("callback: moderator panic: %v", v)}
+			}
+		}()
+		d, err := mo.Moderator.Moderate(ctx, m)
+		done <- result{d, err}
+	}()
+
+	var err error
+	select {
+	case res := <-done:
+		if res.err == nil {
+			return res.d.Reply(), nil
+		}
+		err = res.err
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if mo.OnFallback != nil {
+		mo.OnFallback(m, err)
+	}
+	return mo.Fallback.Reply(), nil
+}
+
+// FilterMode is what a filter does with the messages it matches.
+type FilterMode int
+
+// Filter modes.
+const (
+	FilterReject FilterMode = iota // reject the message with CodeFiltered
+	FilterMask                     // replace each match with asterisks
+)
+
+// KeywordFilter returns a middleware filtering the text messages containing
+// any of words, regardless of case.
+func KeywordFilter(mode FilterMode, words ...string) Middleware {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		if w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return func(next Moderator) Moderator { return next }
+	}
+	return RegexFilter(mode, regexp.MustCompile(`(?i)`+strings.Join(quoted, "|")))
+}
+
+// RegexFilter returns a middleware filtering the text messages matching any
+// of patterns.
+func RegexFilter(mode FilterMode, patterns ...*regexp.Regexp) Middleware {
+	return func(next Moderator) Moderator {
+		return ModeratorFunc(func(ctx context.Context, m *Message) (Decision, error) {
+			masked := false
+			bodies := make([]easemob.MessageBody, len(m.Payload.Bodies))
+			for i, b := range m.Payload.Bodies {
+				bodies[i] = b
+				text, ok := b.(*easemob.TextMessage)
+				if !ok {
+					continue
+				}
+				msg := text.Msg
+				for _, re := range patterns {
+					if !re.MatchString(msg) {
+						continue
+					}
+					if mode == FilterReject {
+						return RejectDecision(CodeFiltered), nil
+					}
+					msg = re.ReplaceAllStringFunc(msg, func(s string) string {
+						return strings.Repeat("*", utf8.RuneCountInString(s))
+					})
+				}
+				if msg != text.Msg {
+					bodies[i] = &easemob.TextMessage{Msg: msg}
+					masked = true
+				}
+			}
+			if !masked {
+				return next.Moderate(ctx, m)
+			}
+
+			// The next moderators see the masked message, and the
+			// masked payload is delivered unless they decide otherwise.
+			mm := *m
+			mm.Payload = easemob.MessagePayload{Bodies: bodies, Ext: m.Payload.Ext}
+			d, err := next.Moderate(ctx, &mm)
+			if err == nil && d.Verdict == Pass {
+				d = ReplaceDecision(&mm.Payload)
+			}
+			return d, err
+		})
+	}
+}
+
+// rateSweepInterval is the number of messages between two sweeps of the
+// idle senders of a RateLimit middleware.
+const rateSweepInterval = 1024
+
+// RateLimit returns a middleware rejecting with CodeRateLimited the messages
+// of senders exceeding perSecond messages a second, after a burst of burst
+// messages.
+func RateLimit(perSecond float64, burst int) Middleware {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &senderLimiter{
+		rate:    perSecond,
+		burst:   float64(burst),
+		senders: make(map[string]*senderBucket),
+	}
+	return func(next Moderator) Moderator {
+		return ModeratorFunc(func(ctx context.Context, m *Message) (Decision, error) {
+			if !l.allow(m.From, time.Now()) {
+				return RejectDecision(CodeRateLimited), nil
+			}
+			return next.Moderate(ctx, m)
+		})
+	}
+}
+
+// senderLimiter is a token bucket per sender.
+type senderLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	senders map[string]*senderBucket
+	calls   int
+}
+
+type senderBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (l *senderLimiter) allow(sender string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.calls++; l.calls%rateSweepInterval == 0 {
+		l.sweep(now)
+	}
+	b, ok := l.senders[sender]
+	if !ok {
+		b = &senderBucket{tokens: l.burst, last: now}
+		l.senders[sender] = b
+	}
+	l.refill(b, now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (l *senderLimiter) refill(b *senderBucket, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+}
+
+// sweep forgets the senders whose buckets are full again, which are as good
+// as new.
+func (l *senderLimiter) sweep(now time.Time) {
+	for sender, b := range l.senders {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.senders, sender)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/weilixu7/easemob"
+)
+
+func textMessage(from string, texts ...string) *Message {
+	m := &Message{From: from, To: "bob", ChatType: ChatTypeChat}
+	for _, s := range texts {
+		m.Payload.Bodies = append(m.Payload.Bodies, &easemob.TextMessage{Msg: s})
+	}
+	return m
+}
+
+func TestModeration_fallback(t *testing.T) {
+	slow := ModeratorFunc(func(ctx context.Context, m *Message) (Decision, error) {
+		<-ctx.Done()
+		return PassDecision, nil
+	})
+	// Ignores ctx, and decides after the deadline.
+	stubborn := ModeratorFunc(func(ctx context.Context, m *Message) (Decision, error) {
+		time.Sleep(50 * time.Millisecond)
+		return PassDecision, nil
+	})
+	failing := ModeratorFunc(func(ctx context.Context, m *Message) (Decision, error) {
+		return Decision{}, errors.New("classifier down")
+	})
+	panicking := ModeratorFunc(func(ctx context.Context, m *Message) (Decision, error) {
+		panic("bug")
+	})
+
+	tests := []struct {
+		name string
+		mod  Moderator
+		err  func(error) bool
+	}{
+		{"timeout", slow, func(err error) bool { return errors.Is(err, context.DeadlineExceeded) }},
+		{"late", stubborn, func(err error) bool { return errors.Is(err, context.DeadlineExceeded) }},
+		{"error", failing, func(err error) bool { return err != nil && err.Error() == "classifier down" }},
+		{"panic", panicking, func(err error) bool { return err != nil }},
+	}
+	for _, tt := range tests {
+		var fallback error
+		mo := &Moderation{
+			Moderator:  tt.mod,
+			Timeout:    10 * time.Millisecond,
+			Fallback:   RejectDecision("busy"),
+			OnFallback: func(m *Message, err error) { fallback = err },
+		}
+		start := time.Now()
+		reply, err := mo.PreSend(context.Background(), textMessage("alice", "hi"))
+		if err != nil {
+			t.Fatalf("%v: PreSend returned error: %v", tt.name, err)
+		}
+		if want := Deny("busy"); !reflect.DeepEqual(reply, want) {
+			t.Errorf("%v: reply %+v, want the fallback %+v", tt.name, reply, want)
+		}
+		if !tt.err(fallback) {
+			t.Errorf("%v: OnFallback got %v", tt.name, fallback)
+		}
+		if d := time.Since(start); d > 40*time.Millisecond && tt.name == "late" {
+			t.Errorf("%v: PreSend waited %v for the moderator", tt.name, d)
+		}
+	}
+}
+
+func TestModeration_PreSend(t *testing.T) {
+	mo := &Moderation{Moderator: ModeratorFunc(func(ctx context.Context, m *Message) (Decision, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("moderator called without a deadline")
+		}
+		return RejectDecision("nope"), nil
+	})}
+	reply, err := mo.PreSend(context.Background(), textMessage("alice", "hi"))
+	if err != nil || !reflect.DeepEqual(reply, Deny("nope")) {
+		t.Errorf("PreSend = %+v, %v; want the moderator's decision", reply, err)
+	}
+
+	if _, err := (&Moderation{}).PreSend(context.Background(), textMessage("alice", "hi")); err == nil {
+		t.Errorf("PreSend without a Moderator returned no error")
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	record := func(name string, d *Decision) Middleware {
+		return func(next Moderator) Moderator {
+			return ModeratorFunc(func(ctx context.Context, m *Message) (Decision, error) {
+				calls = append(calls, name)
+				if d != nil {
+					return *d, nil
+				}
+				return next.Moderate(ctx, m)
+			})
+		}
+	}
+	final := ModeratorFunc(func(ctx context.Context, m *Message) (Decision, error) {
+		calls = append(calls, "final")
+		return PassDecision, nil
+	})
+	reject := RejectDecision("first")
+
+	d, _ := Chain(final, record("a", nil), record("b", nil)).Moderate(context.Background(), textMessage("alice", "hi"))
+	if want := []string{"a", "b", "final"}; d.Verdict != Pass || !reflect.DeepEqual(calls, want) {
+		t.Errorf("passing chain: %v, calls %v; want pass and %v", d, calls, want)
+	}
+
+	calls = nil
+	d, _ = Chain(final, record("a", nil), record("b", &reject), record("c", nil)).Moderate(context.Background(), textMessage("alice", "hi"))
+	if want := []string{"a", "b"}; d != reject || !reflect.DeepEqual(calls, want) {
+		t.Errorf("rejecting chain: %+v, calls %v; want %+v and %v", d, calls, reject, want)
+	}
+
+	calls = nil
+	d, _ = Chain(nil, record("a", nil)).Moderate(context.Background(), textMessage("alice", "hi"))
+	if d.Verdict != Pass || !reflect.DeepEqual(calls, []string{"a"}) {
+		t.Errorf("chain without final: %v, calls %v", d, calls)
+	}
+}
+
+func TestFilter_reject(t *testing.T) {
+	mod := Chain(nil, KeywordFilter(FilterReject, "Spam", ""))
+	tests := []struct {
+		texts []string
+		want  Verdict
+	}{
+		{[]string{"hello"}, Pass},
+		{[]string{"hello", "buy SPAM now"}, Reject},
+		{[]string{"spa m"}, Pass},
+	}
+	for _, tt := range tests {
+		d, err := mod.Moderate(context.Background(), textMessage("alice", tt.texts...))
+		if err != nil || d.Verdict != tt.want {
+			t.Errorf("%q: %+v, %v; want %v", tt.texts, d, err, tt.want)
+		}
+		if tt.want == Reject && d.Code != CodeFiltered {
+			t.Errorf("%q: code %q, want %q", tt.texts, d.Code, CodeFiltered)
+		}
+	}
+
+	if d, _ := Chain(nil, KeywordFilter(FilterReject)).Moderate(context.Background(), textMessage("alice", "")); d.Verdict != Pass {
+		t.Errorf("KeywordFilter without words: %v, want pass", d)
+	}
+}
+
+func TestFilter_mask(t *testing.T) {
+	image := &easemob.ImageMessage{URL: "https://example.com/13800138000.jpg"}
+	m := textMessage("alice", "call 13800138000 now", "no number here", "老板13800138000和垃圾")
+	m.Payload.Bodies = append(m.Payload.Bodies, image)
+	m.Payload.Ext = map[string]interface{}{"k": "v"}
+
+	var seen string
+	final := ModeratorFunc(func(ctx context.Context, m *Message) (Decision, error) {
+		seen = m.Payload.Bodies[0].(*easemob.TextMessage).Msg
+		return PassDecision, nil
+	})
+	mod := Chain(final,
+		RegexFilter(FilterMask, regexp.MustCompile(`\d{11}`)),
+		KeywordFilter(FilterMask, "垃圾"),
+	)
+	d, err := mod.Moderate(context.Background(), m)
+	if err != nil {
+		t.Fatalf("Moderate returned error: %v", err)
+	}
+	if d.Verdict != Replace {
+		t.Fatalf("verdict %v, want replace", d.Verdict)
+	}
+	want := []easemob.MessageBody{
+		&easemob.TextMessage{Msg: "call *********** now"},
+		&easemob.TextMessage{Msg: "no number here"},
+		&easemob.TextMessage{Msg: "老板***********和**"},
+		image,
+	}
+	if !reflect.DeepEqual(d.Payload.Bodies, want) {
+		t.Errorf("masked bodies %v, want %v", d.Payload.Bodies, want)
+	}
+	if d.Payload.Bodies[1] != m.Payload.Bodies[1] || d.Payload.Bodies[3] != image {
+		t.Errorf("unmatched bodies were replaced")
+	}
+	if !reflect.DeepEqual(d.Payload.Ext, m.Payload.Ext) {
+		t.Errorf("ext %v, want %v", d.Payload.Ext, m.Payload.Ext)
+	}
+	if seen != "call *********** now" {
+		t.Errorf("next moderator saw %q, want the masked text", seen)
+	}
+	if got := m.Payload.Bodies[0].(*easemob.TextMessage).Msg; got != "call 13800138000 now" {
+		t.Errorf("original message modified to %q", got)
+	}
+
+	// A later rejection wins over the masking.
+	mod = Chain(nil, RegexFilter(FilterMask, regexp.MustCompile(`\d{11}`)), KeywordFilter(FilterReject, "call"))
+	if d, _ := mod.Moderate(context.Background(), textMessage("alice", "call 13800138000")); d.Verdict != Reject {
+		t.Errorf("masked then rejected: %v, want reject", d)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	mod := Chain(nil, RateLimit(1, 2))
+	verdicts := func(from string, n int) []Verdict {
+		var vs []Verdict
+		for i := 0; i < n; i++ {
+			d, _ := mod.Moderate(context.Background(), textMessage(from, "hi"))
+			vs = append(vs, d.Verdict)
+		}
+		return vs
+	}
+	if got, want := verdicts("alice", 3), []Verdict{Pass, Pass, Reject}; !reflect.DeepEqual(got, want) {
+		t.Errorf("alice: %v, want %v", got, want)
+	}
+	// Other senders have buckets of their own.
+	if got, want := verdicts("bob", 2), []Verdict{Pass, Pass}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bob: %v, want %v", got, want)
+	}
+	if d, _ := mod.Moderate(context.Background(), textMessage("alice", "hi")); d.Code != CodeRateLimited {
+		t.Errorf("rate limited code %q, want %q", d.Code, CodeRateLimited)
+	}
+}
+
+func TestSenderLimiter(t *testing.T) {
+	l := &senderLimiter{rate: 2, burst: 2, senders: make(map[string]*senderBucket)}
+	now := time.Unix(1500000000, 0)
+
+	for i, want := range []bool{true, true, false} {
+		if got := l.allow("alice", now); got != want {
+			t.Errorf("alice call %d: %v, want %v", i, got, want)
+		}
+	}
+	if !l.allow("bob", now) {
+		t.Errorf("bob limited by alice's messages")
+	}
+
+	// Half a second refills one token for alice, no more.
+	now = now.Add(500 * time.Millisecond)
+	if !l.allow("alice", now) || l.allow("alice", now) {
+		t.Errorf("alice after 500ms: want exactly one more message")
+	}
+
+	// Senders whose buckets are full again are forgotten by the sweep.
+	now = now.Add(time.Minute)
+	for i := l.calls; i%rateSweepInterval != rateSweepInterval-1; i++ {
+		l.calls++
+	}
+	l.allow("carol", now)
+	if _, ok := l.senders["alice"]; ok {
+		t.Errorf("idle sender kept after a sweep")
+	}
+	if len(l.senders) != 1 {
+		t.Errorf("%d senders after the sweep, want carol only", len(l.senders))
+	}
+}
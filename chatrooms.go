@@ -0,0 +1,619 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"context"
+	"fmt"
+)
+
+// ChatroomService handles communication with the chatroom related
+// methods of the Easemob API.
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom
+type ChatroomService struct {
+	client *Client
+}
+
+// Chatroom is a chatroom as listed by ListAll and UserChatrooms.
+type Chatroom struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	Owner             string `json:"owner,omitempty"`
+	AffiliationsCount int    `json:"affiliations_count,omitempty"`
+}
+
+// ChatroomDetail is the full description of a chatroom returned by Get.
+type ChatroomDetail struct {
+	ID                string     `json:"id"`
+	Name              string     `json:"name"`
+	Description       string     `json:"description"`
+	MembersOnly       bool       `json:"membersonly"`
+	AllowInvites      bool       `json:"allowinvites"`
+	Maxusers          int        `json:"maxusers"`
+	Owner             string     `json:"owner"`
+	Created           int64      `json:"created"`
+	Custom            string     `json:"custom,omitempty"`
+	AffiliationsCount int        `json:"affiliations_count"`
+	Affiliations      MemberList `json:"affiliations"`
+}
+
+// ChatroomOptions specifies the parameters of a new chatroom.
+type ChatroomOptions struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Maxusers    int      `json:"maxusers,omitempty"`
+	Owner       string   `json:"owner"`
+	Members     []string `json:"members,omitempty"`
+}
+
+// adminResult is the data of requests granting or revoking admin rights.
+type adminResult struct {
+	Result string `json:"result"`
+}
+
+func (r adminResult) ok() bool {
+	return r.Result == "success"
+}
+
+// Create creates a new chatroom and returns its id
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#创建聊天室
+func (s *ChatroomService) Create(opt *ChatroomOptions) (string, *Response, error) {
+	return s.CreateContext(context.Background(), opt)
+}
+
+// CreateContext is like Create but sends the request with ctx.
+func (s *ChatroomService) CreateContext(ctx context.Context, opt *ChatroomOptions) (string, *Response, error) {
+	var u string
+	u = "chatrooms"
+
+	req, err := s.client.NewRequestContext(ctx, "POST", u, opt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", resp, err
+	}
+
+	var data struct {
+		ID string `json:"id"`
+	}
+	err = resp.decodeData(&data)
+	return data.ID, resp, err
+}
+
+// Get fetches chatroom details
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#获取聊天室详情
+func (s *ChatroomService) Get(chatrooms ...string) ([]*ChatroomDetail, *Response, error) {
+	return s.GetContext(context.Background(), chatrooms...)
+}
+
+// GetContext is like Get but sends the request with ctx.
+func (s *ChatroomService) GetContext(ctx context.Context, chatrooms ...string) ([]*ChatroomDetail, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/%v", generalizeStringList(chatrooms))
+
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var details []*ChatroomDetail
+	err = resp.decodeData(&details)
+	return details, resp, err
+}
+
+// ListAll lists all chatrooms
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#获取_app_中所有的聊天室
+func (s *ChatroomService) ListAll() ([]*Chatroom, *Response, error) {
+	return s.ListAllContext(context.Background())
+}
+
+// ListAllContext is like ListAll but sends the request with ctx.
+func (s *ChatroomService) ListAllContext(ctx context.Context) ([]*Chatroom, *Response, error) {
+	var u string
+	u = "chatrooms"
+
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var chatrooms []*Chatroom
+	err = resp.decodeData(&chatrooms)
+	return chatrooms, resp, err
+}
+
+// List lists a page of chatrooms
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#分页获取_app_下的聊天室
+func (s *ChatroomService) List(opt *PageOptions) ([]*Chatroom, *Response, error) {
+	return s.ListContext(context.Background(), opt)
+}
+
+// ListContext is like List but sends the request with ctx.
+func (s *ChatroomService) ListContext(ctx context.Context, opt *PageOptions) ([]*Chatroom, *Response, error) {
+	u, err := addOptions("chatrooms", opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var chatrooms []*Chatroom
+	err = resp.decodeData(&chatrooms)
+	return chatrooms, resp, err
+}
+
+// UserChatrooms lists the chatrooms a user has joined
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#获取用户加入的聊天室
+func (s *ChatroomService) UserChatrooms(user string) ([]*Chatroom, *Response, error) {
+	return s.UserChatroomsContext(context.Background(), user)
+}
+
+// UserChatroomsContext is like UserChatrooms but sends the request with ctx.
+func (s *ChatroomService) UserChatroomsContext(ctx context.Context, user string) ([]*Chatroom, *Response, error) {
+	var u string
+	u = fmt.Sprintf("users/%v/joined_chatrooms", user)
+
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var chatrooms []*Chatroom
+	err = resp.decodeData(&chatrooms)
+	return chatrooms, resp, err
+}
+
+// Update edits a chatroom's information
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#修改聊天室信息
+func (s *ChatroomService) Update(chatroomid string, name string, description string, maxusers int) (UpdateResult, *Response, error) {
+	return s.UpdateContext(context.Background(), chatroomid, name, description, maxusers)
+}
+
+// UpdateContext is like Update but sends the request with ctx.
+func (s *ChatroomService) UpdateContext(ctx context.Context, chatroomid string, name string, description string, maxusers int) (UpdateResult, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/%v", chatroomid)
+
+	put := new(PutOptions)
+	if name != "" {
+		put.Name = name
+	}
+	if description != "" {
+		put.Description = description
+	}
+	if maxusers > 0 {
+		put.Maxusers = maxusers
+	}
+
+	req, err := s.client.NewRequestContext(ctx, "PUT", u, put)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var result UpdateResult
+	err = resp.decodeData(&result)
+	return result, resp, err
+}
+
+// Delete removes a chatroom
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#删除聊天室
+func (s *ChatroomService) Delete(chatroomid string) (bool, *Response, error) {
+	return s.DeleteContext(context.Background(), chatroomid)
+}
+
+// DeleteContext is like Delete but sends the request with ctx.
+func (s *ChatroomService) DeleteContext(ctx context.Context, chatroomid string) (bool, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/%v", chatroomid)
+
+	req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
+	if err != nil {
+		return false, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, resp, err
+	}
+
+	var result successResult
+	err = resp.decodeData(&result)
+	return result.ok(), resp, err
+}
+
+// Members lists the members of a chatroom
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#分页获取聊天室成员
+func (s *ChatroomService) Members(chatroomid string) (MemberList, *Response, error) {
+	return s.MembersContext(context.Background(), chatroomid)
+}
+
+// MembersContext is like Members but sends the request with ctx.
+func (s *ChatroomService) MembersContext(ctx context.Context, chatroomid string) (MemberList, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/%v/users", chatroomid)
+
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var members MemberList
+	err = resp.decodeData(&members)
+	return members, resp, err
+}
+
+// MembersPage lists a page of the members of a chatroom
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#分页获取聊天室成员
+func (s *ChatroomService) MembersPage(chatroomid string, opt *PageOptions) (MemberList, *Response, error) {
+	return s.MembersPageContext(context.Background(), chatroomid, opt)
+}
+
+// MembersPageContext is like MembersPage but sends the request with ctx.
+func (s *ChatroomService) MembersPageContext(ctx context.Context, chatroomid string, opt *PageOptions) (MemberList, *Response, error) {
+	u, err := addOptions(fmt.Sprintf("chatrooms/%v/users", chatroomid), opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var members MemberList
+	err = resp.decodeData(&members)
+	return members, resp, err
+}
+
+// AddMember adds a user to a chatroom
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#添加单个聊天室成员
+func (s *ChatroomService) AddMember(chatroomid string, user string) (bool, *Response, error) {
+	return s.AddMemberContext(context.Background(), chatroomid, user)
+}
+
+// AddMemberContext is like AddMember but sends the request with ctx.
+func (s *ChatroomService) AddMemberContext(ctx context.Context, chatroomid string, user string) (bool, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/%v/users/%v", chatroomid, user)
+
+	req, err := s.client.NewRequestContext(ctx, "POST", u, nil)
+	if err != nil {
+		return false, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, resp, err
+	}
+
+	var result successResult
+	err = resp.decodeData(&result)
+	return result.ok(), resp, err
+}
+
+// AddMembers adds users to a chatroom and returns the ones added
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#批量添加聊天室成员
+func (s *ChatroomService) AddMembers(chatroomid string, users ...string) ([]string, *Response, error) {
+	return s.AddMembersContext(context.Background(), chatroomid, users...)
+}
+
+// AddMembersContext is like AddMembers but sends the request with ctx.
+func (s *ChatroomService) AddMembersContext(ctx context.Context, chatroomid string, users ...string) ([]string, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/%v/users", chatroomid)
+
+	put := &PutOptions{Usernames: users}
+	req, err := s.client.NewRequestContext(ctx, "POST", u, put)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var data struct {
+		Newmembers []string `json:"newmembers"`
+	}
+	err = resp.decodeData(&data)
+	return data.Newmembers, resp, err
+}
+
+// DeleteMember removes a user from a chatroom
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#删除单个聊天室成员
+func (s *ChatroomService) DeleteMember(chatroomid string, user string) (bool, *Response, error) {
+	return s.DeleteMemberContext(context.Background(), chatroomid, user)
+}
+
+// DeleteMemberContext is like DeleteMember but sends the request with ctx.
+func (s *ChatroomService) DeleteMemberContext(ctx context.Context, chatroomid string, user string) (bool, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/%v/users/%v", chatroomid, user)
+
+	req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
+	if err != nil {
+		return false, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, resp, err
+	}
+
+	var result successResult
+	err = resp.decodeData(&result)
+	return result.ok(), resp, err
+}
+
+// DeleteMembers removes users from a chatroom and returns the ones removed
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#批量删除聊天室成员
+func (s *ChatroomService) DeleteMembers(chatroomid string, users ...string) ([]string, *Response, error) {
+	return s.DeleteMembersContext(context.Background(), chatroomid, users...)
+}
+
+// DeleteMembersContext is like DeleteMembers but sends the request with ctx.
+func (s *ChatroomService) DeleteMembersContext(ctx context.Context, chatroomid string, users ...string) ([]string, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/%v/users/%v", chatroomid, generalizeStringList(users))
+
+	req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	type removal struct {
+		Result bool   `json:"result"`
+		User   string `json:"user"`
+	}
+	var results []removal
+	// The removal of a single user is answered with an object rather
+	// than a list.
+	if len(users) == 1 {
+		results = make([]removal, 1)
+		err = resp.decodeData(&results[0])
+	} else {
+		err = resp.decodeData(&results)
+	}
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var removed []string
+	for _, r := range results {
+		if r.Result {
+			removed = append(removed, r.User)
+		}
+	}
+	return removed, resp, nil
+}
+
+// SuperAdmins lists the chatroom super admins of the app, who may create
+// chatrooms from the client SDK
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#分页获取聊天室超级管理员列表
+func (s *ChatroomService) SuperAdmins() ([]string, *Response, error) {
+	return s.SuperAdminsContext(context.Background())
+}
+
+// SuperAdminsContext is like SuperAdmins but sends the request with ctx.
+func (s *ChatroomService) SuperAdminsContext(ctx context.Context) ([]string, *Response, error) {
+	var u string
+	u = "chatrooms/super_admin"
+
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var admins []string
+	err = resp.decodeData(&admins)
+	return admins, resp, err
+}
+
+// AddSuperAdmin makes a user a chatroom super admin
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#添加超级管理员
+func (s *ChatroomService) AddSuperAdmin(user string) (bool, *Response, error) {
+	return s.AddSuperAdminContext(context.Background(), user)
+}
+
+// AddSuperAdminContext is like AddSuperAdmin but sends the request with ctx.
+func (s *ChatroomService) AddSuperAdminContext(ctx context.Context, user string) (bool, *Response, error) {
+	var u string
+	u = "chatrooms/super_admin"
+
+	put := map[string]string{"superadmin": user}
+	req, err := s.client.NewRequestContext(ctx, "POST", u, put)
+	if err != nil {
+		return false, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, resp, err
+	}
+
+	var result adminResult
+	err = resp.decodeData(&result)
+	return result.ok(), resp, err
+}
+
+// DeleteSuperAdmin revokes a chatroom super admin
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#撤销超级管理员
+func (s *ChatroomService) DeleteSuperAdmin(user string) (bool, *Response, error) {
+	return s.DeleteSuperAdminContext(context.Background(), user)
+}
+
+// DeleteSuperAdminContext is like DeleteSuperAdmin but sends the request with ctx.
+func (s *ChatroomService) DeleteSuperAdminContext(ctx context.Context, user string) (bool, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/super_admin/%v", user)
+
+	req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
+	if err != nil {
+		return false, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, resp, err
+	}
+
+	var data struct {
+		NewSuperAdmin string `json:"newSuperAdmin"`
+	}
+	err = resp.decodeData(&data)
+	return data.NewSuperAdmin == user, resp, err
+}
+
+// Admins lists the admins of a chatroom
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#获取聊天室管理员列表
+func (s *ChatroomService) Admins(chatroomid string) ([]string, *Response, error) {
+	return s.AdminsContext(context.Background(), chatroomid)
+}
+
+// AdminsContext is like Admins but sends the request with ctx.
+func (s *ChatroomService) AdminsContext(ctx context.Context, chatroomid string) ([]string, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/%v/admin", chatroomid)
+
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var admins []string
+	err = resp.decodeData(&admins)
+	return admins, resp, err
+}
+
+// AddAdmin makes a chatroom member an admin of it
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#添加聊天室管理员
+func (s *ChatroomService) AddAdmin(chatroomid string, user string) (bool, *Response, error) {
+	return s.AddAdminContext(context.Background(), chatroomid, user)
+}
+
+// AddAdminContext is like AddAdmin but sends the request with ctx.
+func (s *ChatroomService) AddAdminContext(ctx context.Context, chatroomid string, user string) (bool, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/%v/admin", chatroomid)
+
+	put := map[string]string{"newadmin": user}
+	req, err := s.client.NewRequestContext(ctx, "POST", u, put)
+	if err != nil {
+		return false, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, resp, err
+	}
+
+	var result adminResult
+	err = resp.decodeData(&result)
+	return result.ok(), resp, err
+}
+
+// DeleteAdmin revokes a chatroom admin
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatroom#移除聊天室管理员
+func (s *ChatroomService) DeleteAdmin(chatroomid string, user string) (bool, *Response, error) {
+	return s.DeleteAdminContext(context.Background(), chatroomid, user)
+}
+
+// DeleteAdminContext is like DeleteAdmin but sends the request with ctx.
+func (s *ChatroomService) DeleteAdminContext(ctx context.Context, chatroomid string, user string) (bool, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatrooms/%v/admin/%v", chatroomid, user)
+
+	req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
+	if err != nil {
+		return false, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, resp, err
+	}
+
+	var result adminResult
+	err = resp.decodeData(&result)
+	return result.ok(), resp, err
+}
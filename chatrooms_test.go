@@ -0,0 +1,141 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestChatroomService_Create(t *testing.T) {
+	client, mux := setup(t)
+	mux.HandleFunc("/org/app/chatrooms", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		testJSONBody(t, r, `{"name":"lobby","description":"d","maxusers":50,"owner":"alice","members":["bob"]}`)
+		fmt.Fprint(w, `{"action":"post","data":{"id":"66211860774913"}}`)
+	})
+
+	id, _, err := client.Chatrooms.Create(&ChatroomOptions{
+		Name:        "lobby",
+		Description: "d",
+		Maxusers:    50,
+		Owner:       "alice",
+		Members:     []string{"bob"},
+	})
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if id != "66211860774913" {
+		t.Errorf("Create returned id %q", id)
+	}
+}
+
+func TestChatroomService_Get(t *testing.T) {
+	client, mux := setup(t)
+	mux.HandleFunc("/org/app/chatrooms/1,2", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"data":[
+			{"id":"1","name":"lobby","maxusers":50,"owner":"alice","affiliations_count":2,
+			 "affiliations":[{"owner":"alice"},{"member":"bob"}]},
+			{"id":"2","name":"empty","maxusers":200,"owner":"carol","affiliations_count":1,
+			 "affiliations":[{"owner":"carol"}]}
+		]}`)
+	})
+
+	rooms, _, err := client.Chatrooms.Get("1", "2")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if len(rooms) != 2 {
+		t.Fatalf("Get returned %d chatrooms, want 2", len(rooms))
+	}
+	r := rooms[0]
+	if r.ID != "1" || r.Name != "lobby" || r.Maxusers != 50 || r.AffiliationsCount != 2 {
+		t.Errorf("Get returned %+v", r)
+	}
+	if r.Affiliations.Owner() != "alice" || !reflect.DeepEqual(r.Affiliations.Usernames(), []string{"alice", "bob"}) {
+		t.Errorf("Get returned affiliations %v", r.Affiliations.Usernames())
+	}
+}
+
+func TestChatroomService_members(t *testing.T) {
+	client, mux := setup(t)
+	mux.HandleFunc("/org/app/chatrooms/1/users", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			fmt.Fprint(w, `{"data":[{"owner":"alice"},{"member":"bob"}]}`)
+		case "POST":
+			testJSONBody(t, r, `{"usernames":["carol","dave"]}`)
+			fmt.Fprint(w, `{"data":{"newmembers":["carol","dave"],"action":"add_member","id":"1"}}`)
+		default:
+			t.Errorf("unexpected %v", r.Method)
+		}
+	})
+	mux.HandleFunc("/org/app/chatrooms/1/users/erin", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			fmt.Fprint(w, `{"data":{"result":true,"action":"add_member","id":"1","user":"erin"}}`)
+		case "DELETE":
+			fmt.Fprint(w, `{"data":{"result":true,"action":"remove_member","id":"1","user":"erin"}}`)
+		}
+	})
+	mux.HandleFunc("/org/app/chatrooms/1/users/frank", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+		fmt.Fprint(w, `{"data":{"result":true,"action":"remove_member","id":"1","user":"frank"}}`)
+	})
+	mux.HandleFunc("/org/app/chatrooms/1/users/carol,dave", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+		fmt.Fprint(w, `{"data":[{"result":true,"user":"carol"},{"result":false,"user":"dave"}]}`)
+	})
+
+	members, _, err := client.Chatrooms.Members("1")
+	if err != nil {
+		t.Fatalf("Members returned error: %v", err)
+	}
+	if members.Owner() != "alice" || !reflect.DeepEqual(members.Usernames(), []string{"alice", "bob"}) {
+		t.Errorf("Members returned %v", members.Usernames())
+	}
+
+	added, _, err := client.Chatrooms.AddMembers("1", "carol", "dave")
+	if err != nil || !reflect.DeepEqual(added, []string{"carol", "dave"}) {
+		t.Errorf("AddMembers returned %v, %v", added, err)
+	}
+	if ok, _, err := client.Chatrooms.AddMember("1", "erin"); !ok || err != nil {
+		t.Errorf("AddMember returned %v, %v", ok, err)
+	}
+	if ok, _, err := client.Chatrooms.DeleteMember("1", "erin"); !ok || err != nil {
+		t.Errorf("DeleteMember returned %v, %v", ok, err)
+	}
+	removed, _, err := client.Chatrooms.DeleteMembers("1", "carol", "dave")
+	if err != nil || !reflect.DeepEqual(removed, []string{"carol"}) {
+		t.Errorf("DeleteMembers returned %v, %v; want only the users removed", removed, err)
+	}
+	removed, _, err = client.Chatrooms.DeleteMembers("1", "frank")
+	if err != nil || !reflect.DeepEqual(removed, []string{"frank"}) {
+		t.Errorf("DeleteMembers of one user returned %v, %v", removed, err)
+	}
+}
+
+func TestChatroomService_Delete(t *testing.T) {
+	client, mux := setup(t)
+	mux.HandleFunc("/org/app/chatrooms/1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+		fmt.Fprint(w, `{"data":{"success":true,"id":"1"}}`)
+	})
+	mux.HandleFunc("/org/app/chatrooms/2", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"service_resource_not_found","error_description":"grpID 2 does not exist!"}`)
+	})
+
+	if ok, _, err := client.Chatrooms.Delete("1"); !ok || err != nil {
+		t.Errorf("Delete returned %v, %v", ok, err)
+	}
+	if ok, _, err := client.Chatrooms.Delete("2"); ok || !IsNotFound(err) {
+		t.Errorf("Delete of a missing chatroom returned %v, %v; want ErrNotFound", ok, err)
+	}
+}
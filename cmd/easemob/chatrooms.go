@@ -0,0 +1,209 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/weilixu7/easemob"
+)
+
+var chatroomsResource = &resource{
+	name:  "chatrooms",
+	short: "manage chatrooms and their members",
+	commands: []*command{
+		{"list", "[-page n] [-page-size n] [-all]", "list chatrooms", chatroomsList},
+		{"get", "<chatroomid>...", "show chatrooms", chatroomsGet},
+		{"create", "[-desc text] [-maxusers n] [-members u1,u2] <owner> <name>", "create a chatroom", chatroomsCreate},
+		{"update", "[-name name] [-desc text] [-maxusers n] <chatroomid>", "change a chatroom", chatroomsUpdate},
+		{"delete", "<chatroomid>", "delete a chatroom", chatroomsDelete},
+		{"members", "<chatroomid>", "list the members of a chatroom", chatroomsMembers},
+		{"add-members", "<chatroomid> <username>...", "add members to a chatroom", chatroomsAddMembers},
+		{"remove-members", "<chatroomid> <username>...", "remove members from a chatroom", chatroomsRemoveMembers},
+		{"joined", "<username>", "list the chatrooms a user belongs to", chatroomsJoined},
+	},
+}
+
+func printChatrooms(e *env, chatrooms []*easemob.Chatroom) error {
+	if chatrooms == nil {
+		chatrooms = []*easemob.Chatroom{}
+	}
+	return e.print(chatrooms, func() *table {
+		t := &table{header: []string{"ID", "NAME", "OWNER", "MEMBERS"}}
+		for _, c := range chatrooms {
+			t.add(c.ID, c.Name, orDash(c.Owner), c.AffiliationsCount)
+		}
+		return t
+	})
+}
+
+func chatroomsList(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	page := fs.Int("page", 0, "number of the page to list, from 1")
+	pageSize := fs.Int("page-size", 0, "number of chatrooms per page")
+	all := fs.Bool("all", false, "list all chatrooms, page after page")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	if !*all {
+		var (
+			chatrooms []*easemob.Chatroom
+			err       error
+		)
+		if *page == 0 && *pageSize == 0 {
+			chatrooms, _, err = e.client.Chatrooms.ListAllContext(ctx)
+		} else {
+			chatrooms, _, err = e.client.Chatrooms.ListContext(ctx, &easemob.PageOptions{PageNum: *page, PageSize: *pageSize})
+		}
+		if err != nil {
+			return err
+		}
+		return printChatrooms(e, chatrooms)
+	}
+
+	var chatrooms []*easemob.Chatroom
+	it := e.client.Chatrooms.Iter(ctx, *pageSize)
+	for it.Next() {
+		chatrooms = append(chatrooms, it.Value())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return printChatrooms(e, chatrooms)
+}
+
+func chatroomsGet(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("get", flag.ContinueOnError), args, -1)
+	if err != nil {
+		return err
+	}
+	chatrooms, _, err := e.client.Chatrooms.GetContext(ctx, args...)
+	if err != nil {
+		return err
+	}
+	return e.print(chatrooms, func() *table {
+		t := &table{header: []string{"ID", "NAME", "OWNER", "MEMBERS", "MAXUSERS", "CREATED", "DESCRIPTION"}}
+		for _, c := range chatrooms {
+			t.add(c.ID, c.Name, orDash(c.Owner), c.AffiliationsCount, c.Maxusers,
+				formatTime(c.Created), orDash(c.Description))
+		}
+		return t
+	})
+}
+
+func chatroomsCreate(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	desc := fs.String("desc", "", "description of the chatroom")
+	maxusers := fs.Int("maxusers", 0, "maximum number of members")
+	members := fs.String("members", "", "comma-separated initial members")
+	args, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	id, _, err := e.client.Chatrooms.CreateContext(ctx, &easemob.ChatroomOptions{
+		Name:        args[1],
+		Description: *desc,
+		Maxusers:    *maxusers,
+		Owner:       args[0],
+		Members:     splitList(*members),
+	})
+	if err != nil {
+		return err
+	}
+	return e.print(map[string]string{"id": id}, func() *table {
+		t := &table{header: []string{"ID"}}
+		t.add(id)
+		return t
+	})
+}
+
+func chatroomsUpdate(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	name := fs.String("name", "", "new name")
+	desc := fs.String("desc", "", "new description")
+	maxusers := fs.Int("maxusers", 0, "new maximum number of members")
+	args, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *name == "" && *desc == "" && *maxusers == 0 {
+		return errUsage
+	}
+
+	result, _, err := e.client.Chatrooms.UpdateContext(ctx, args[0], *name, *desc, *maxusers)
+	if err != nil {
+		return err
+	}
+	return printUpdate(e, result)
+}
+
+func chatroomsDelete(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("delete", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	ok, _, err := e.client.Chatrooms.DeleteContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return e.printOK(ok)
+}
+
+func chatroomsMembers(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("members", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	var members easemob.MemberList
+	it := e.client.Chatrooms.MembersIter(ctx, args[0], 0)
+	for it.Next() {
+		members = append(members, it.Value())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return printMembers(e, members)
+}
+
+func chatroomsAddMembers(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("add-members", flag.ContinueOnError), args, -2)
+	if err != nil {
+		return err
+	}
+	added, _, err := e.client.Chatrooms.AddMembersContext(ctx, args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	return e.printList("ADDED", added)
+}
+
+func chatroomsRemoveMembers(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("remove-members", flag.ContinueOnError), args, -2)
+	if err != nil {
+		return err
+	}
+	removed, _, err := e.client.Chatrooms.DeleteMembersContext(ctx, args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	return e.printList("REMOVED", removed)
+}
+
+func chatroomsJoined(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("joined", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	chatrooms, _, err := e.client.Chatrooms.UserChatroomsContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return printChatrooms(e, chatrooms)
+}
@@ -0,0 +1,57 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/weilixu7/easemob"
+	"github.com/weilixu7/easemob/config"
+)
+
+// configNames are the names of the default config file, looked for in
+// order in easemob/ under the user's config directory.
+var configNames = []string{"config.yaml", "config.yml", "config.toml", "config.json"}
+
+// loadConfig reads the config file at path, $EASEMOB_CONFIG or else the
+// default one. With no default file, the app and credentials come from the
+// environment alone.
+func loadConfig(path string) (*config.Config, error) {
+	if path == "" {
+		path = os.Getenv("EASEMOB_CONFIG")
+	}
+	if path != "" {
+		return config.Load(path)
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return config.FromEnv(), nil
+	}
+	for _, name := range configNames {
+		path := filepath.Join(dir, "easemob", name)
+		if _, err := os.Stat(path); err == nil {
+			return config.Load(path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return config.FromEnv(), nil
+}
+
+// newClient returns a client of the named profile of cfg.
+func newClient(cfg *config.Config, profile string) (*easemob.Client, error) {
+	p, err := cfg.Profile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if p.UserAgent == "" {
+		p.UserAgent = "go-easemob-cli"
+	}
+	return p.Client()
+}
@@ -0,0 +1,107 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+)
+
+var contactsResource = &resource{
+	name:  "contacts",
+	short: "manage the contacts of a user",
+	commands: []*command{
+		{"list", "<username>", "list the contacts of a user", contactsList},
+		{"add", "<username> <contact>", "add a contact to a user", contactsAdd},
+		{"delete", "<username> <contact>", "remove a contact from a user", contactsDelete},
+	},
+}
+
+var blocksResource = &resource{
+	name:  "blocks",
+	short: "manage the users a user blocks",
+	commands: []*command{
+		{"list", "[-page-size n] <username>", "list the users a user blocks", blocksList},
+		{"add", "<username> <user>...", "block users", blocksAdd},
+		{"delete", "<username> <user>", "unblock a user", blocksDelete},
+	},
+}
+
+func contactsList(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("list", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	friends, _, err := e.client.Users.GetFriendsContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return e.printList("CONTACT", friends)
+}
+
+func contactsAdd(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("add", flag.ContinueOnError), args, 2)
+	if err != nil {
+		return err
+	}
+	if _, _, err := e.client.Users.AddFriendContext(ctx, args[0], args[1]); err != nil {
+		return err
+	}
+	return e.printOK(true)
+}
+
+func contactsDelete(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("delete", flag.ContinueOnError), args, 2)
+	if err != nil {
+		return err
+	}
+	if _, _, err := e.client.Users.DeleteFriendContext(ctx, args[0], args[1]); err != nil {
+		return err
+	}
+	return e.printOK(true)
+}
+
+func blocksList(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	pageSize := fs.Int("page-size", 0, "number of users fetched per request")
+	args, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	var blocks []string
+	it := e.client.Users.BlocksIter(ctx, args[0], *pageSize)
+	for it.Next() {
+		blocks = append(blocks, it.Value())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return e.printList("BLOCKED", blocks)
+}
+
+func blocksAdd(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("add", flag.ContinueOnError), args, -2)
+	if err != nil {
+		return err
+	}
+	blocked, _, err := e.client.Users.AddBlocksContext(ctx, args[0], args[1:])
+	if err != nil {
+		return err
+	}
+	return e.printList("BLOCKED", blocked)
+}
+
+func blocksDelete(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("delete", flag.ContinueOnError), args, 2)
+	if err != nil {
+		return err
+	}
+	if _, _, err := e.client.Users.DeleteBlockContext(ctx, args[0], args[1]); err != nil {
+		return err
+	}
+	return e.printOK(true)
+}
@@ -0,0 +1,241 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"sort"
+
+	"github.com/weilixu7/easemob"
+)
+
+var groupsResource = &resource{
+	name:  "groups",
+	short: "manage groups and their members",
+	commands: []*command{
+		{"list", "[-limit n] [-cursor c] [-all]", "list groups", groupsList},
+		{"get", "<groupid>...", "show groups", groupsGet},
+		{"create", "[-desc text] [-public] [-maxusers n] [-members u1,u2] <owner> <name>", "create a group", groupsCreate},
+		{"update", "[-name name] [-desc text] [-maxusers n] <groupid>", "change a group", groupsUpdate},
+		{"delete", "<groupid>", "delete a group", groupsDelete},
+		{"members", "<groupid>", "list the members of a group", groupsMembers},
+		{"add-members", "<groupid> <username>...", "add members to a group", groupsAddMembers},
+		{"remove-member", "<groupid> <username>", "remove a member from a group", groupsRemoveMember},
+		{"joined", "<username>", "list the groups a user belongs to", groupsJoined},
+	},
+}
+
+func printGroups(e *env, groups []*easemob.Group) error {
+	if groups == nil {
+		groups = []*easemob.Group{}
+	}
+	return e.print(groups, func() *table {
+		t := &table{header: []string{"ID", "NAME", "OWNER", "MEMBERS"}}
+		for _, g := range groups {
+			t.add(g.Groupid, g.Groupname, orDash(g.Owner), g.Affiliations)
+		}
+		return t
+	})
+}
+
+// printMembers prints the members of a group or chatroom.
+func printMembers(e *env, members easemob.MemberList) error {
+	if members == nil {
+		members = easemob.MemberList{}
+	}
+	return e.print(members, func() *table {
+		t := &table{header: []string{"USERNAME", "ROLE"}}
+		for _, m := range members {
+			t.add(m.Username, m.Role)
+		}
+		return t
+	})
+}
+
+// printUpdate prints which fields an update changed.
+func printUpdate(e *env, result easemob.UpdateResult) error {
+	return e.print(result, func() *table {
+		t := &table{header: []string{"FIELD", "UPDATED"}}
+		fields := make([]string, 0, len(result))
+		for f := range result {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		for _, f := range fields {
+			t.add(f, result[f])
+		}
+		return t
+	})
+}
+
+func groupsList(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	limit := fs.Int("limit", 0, "number of groups per page")
+	cursor := fs.String("cursor", "", "cursor of the page to list")
+	all := fs.Bool("all", false, "list all groups, page after page")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	opt := &easemob.ListOptions{Limit: *limit, Cursor: *cursor}
+	if !*all {
+		groups, resp, err := e.client.Groups.ListContext(ctx, opt)
+		if err != nil {
+			return err
+		}
+		if err := printGroups(e, groups); err != nil {
+			return err
+		}
+		if resp.Cursor != "" && !e.json {
+			e.printNext(resp.Cursor)
+		}
+		return nil
+	}
+
+	var groups []*easemob.Group
+	it := e.client.Groups.Iter(ctx, opt)
+	for it.Next() {
+		groups = append(groups, it.Value())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return printGroups(e, groups)
+}
+
+func groupsGet(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("get", flag.ContinueOnError), args, -1)
+	if err != nil {
+		return err
+	}
+	groups, _, err := e.client.Groups.GetContext(ctx, args...)
+	if err != nil {
+		return err
+	}
+	return e.print(groups, func() *table {
+		t := &table{header: []string{"ID", "NAME", "OWNER", "PUBLIC", "MEMBERS", "MAXUSERS", "CREATED", "DESCRIPTION"}}
+		for _, g := range groups {
+			t.add(g.ID, g.Name, orDash(g.Affiliations.Owner()), g.Public, g.AffiliationsCount,
+				g.Maxusers, formatTime(g.Created), orDash(g.Description))
+		}
+		return t
+	})
+}
+
+func groupsCreate(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	desc := fs.String("desc", "", "description of the group")
+	public := fs.Bool("public", false, "let anyone find and join the group")
+	maxusers := fs.Int("maxusers", 0, "maximum number of members")
+	members := fs.String("members", "", "comma-separated initial members")
+	args, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	id, _, err := e.client.Groups.CreateContext(ctx, &easemob.GroupOptions{
+		Groupname:   args[1],
+		Description: *desc,
+		Public:      *public,
+		Maxusers:    *maxusers,
+		Owner:       args[0],
+		Members:     splitList(*members),
+	})
+	if err != nil {
+		return err
+	}
+	return e.print(map[string]string{"groupid": id}, func() *table {
+		t := &table{header: []string{"ID"}}
+		t.add(id)
+		return t
+	})
+}
+
+func groupsUpdate(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	name := fs.String("name", "", "new name")
+	desc := fs.String("desc", "", "new description")
+	maxusers := fs.Int("maxusers", 0, "new maximum number of members")
+	args, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *name == "" && *desc == "" && *maxusers == 0 {
+		return errUsage
+	}
+
+	result, _, err := e.client.Groups.UpdateContext(ctx, args[0], *name, *desc, *maxusers)
+	if err != nil {
+		return err
+	}
+	return printUpdate(e, result)
+}
+
+func groupsDelete(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("delete", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	ok, _, err := e.client.Groups.DeleteContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return e.printOK(ok)
+}
+
+func groupsMembers(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("members", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	var members easemob.MemberList
+	it := e.client.Groups.MembersIter(ctx, args[0], 0)
+	for it.Next() {
+		members = append(members, it.Value())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return printMembers(e, members)
+}
+
+func groupsAddMembers(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("add-members", flag.ContinueOnError), args, -2)
+	if err != nil {
+		return err
+	}
+	added, _, err := e.client.Groups.AddMembersContext(ctx, args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	return e.printList("ADDED", added)
+}
+
+func groupsRemoveMember(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("remove-member", flag.ContinueOnError), args, 2)
+	if err != nil {
+		return err
+	}
+	ok, _, err := e.client.Groups.DeleteMemberContext(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	return e.printOK(ok)
+}
+
+func groupsJoined(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("joined", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	groups, _, err := e.client.Groups.UserGroupsContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return printGroups(e, groups)
+}
@@ -0,0 +1,213 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command easemob operates an Easemob app from the command line.
+//
+// Usage:
+//
+//	easemob [flags] <resource> <command> [arguments]
+//
+// The resources are users, contacts, blocks, groups, chatrooms and
+// messages; run "easemob help <resource>" for their commands.
+//
+// The app and its credentials are read from the profile named by -profile
+// of the config file given by -config, $EASEMOB_CONFIG or, by default,
+// easemob/config.yaml, config.toml or config.json in the user's config
+// directory; see package github.com/weilixu7/easemob/config for its format.
+// The EASEMOB_ORG, EASEMOB_APP, EASEMOB_CLIENT_ID, EASEMOB_CLIENT_SECRET and
+// EASEMOB_BASE_URL environment variables override the profile, and are
+// enough on their own without a config file.
+//
+// Results are printed as tables, or as JSON with -o json.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// errUsage reports a command line error, after which the usage of the
+// command is printed.
+var errUsage = errors.New("usage error")
+
+// A command is a command acting on a resource.
+type command struct {
+	name  string
+	args  string // synopsis of the arguments
+	short string
+	run   func(ctx context.Context, e *env, args []string) error
+}
+
+// A resource is a group of commands.
+type resource struct {
+	name     string
+	short    string
+	commands []*command
+}
+
+var resources = []*resource{
+	usersResource,
+	contactsResource,
+	blocksResource,
+	groupsResource,
+	chatroomsResource,
+	messagesResource,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("easemob", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "", "config `file` (default $EASEMOB_CONFIG or the user config dir)")
+	profile := fs.String("profile", "", "config `profile` to use (default $EASEMOB_PROFILE or the file's default)")
+	format := fs.String("o", "table", "output `format`: table or json")
+	fs.Usage = func() { usage(stderr) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(stderr, "easemob: unknown output format %q\n", *format)
+		return 2
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	if args[0] == "help" {
+		if len(args) > 1 {
+			if r := findResource(args[1]); r != nil {
+				r.usage(stdout)
+				return 0
+			}
+		}
+		usage(stdout)
+		return 0
+	}
+
+	r := findResource(args[0])
+	if r == nil {
+		fmt.Fprintf(stderr, "easemob: unknown resource %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	if len(args) < 2 {
+		r.usage(stderr)
+		return 2
+	}
+	cmd := r.find(args[1])
+	if cmd == nil {
+		fmt.Fprintf(stderr, "easemob: unknown %v command %q\n", r.name, args[1])
+		r.usage(stderr)
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "easemob: %v\n", err)
+		return 1
+	}
+	client, err := newClient(cfg, *profile)
+	if err != nil {
+		fmt.Fprintf(stderr, "easemob: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	e := &env{client: client, out: stdout, errOut: stderr, json: *format == "json"}
+	if err := cmd.run(ctx, e, args[2:]); err != nil {
+		if errors.Is(err, errUsage) {
+			if err != errUsage {
+				fmt.Fprintf(stderr, "easemob: %v\n", err)
+			}
+			fmt.Fprintf(stderr, "usage: easemob %v %v %v\n", r.name, cmd.name, cmd.args)
+			return 2
+		}
+		fmt.Fprintf(stderr, "easemob: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func findResource(name string) *resource {
+	for _, r := range resources {
+		if r.name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func (r *resource) find(name string) *command {
+	for _, c := range r.commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: easemob [-config file] [-profile name] [-o table|json] <resource> <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Resources:")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, r := range resources {
+		fmt.Fprintf(tw, "  %v\t%v\n", r.name, r.short)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "easemob help <resource>" for the commands of a resource.`)
+}
+
+func (r *resource) usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: easemob %v <command> [arguments]\n\nCommands:\n", r.name)
+	cmds := append([]*command(nil), r.commands...)
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].name < cmds[j].name })
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, c := range cmds {
+		fmt.Fprintf(tw, "  %v %v\t%v\n", c.name, c.args, c.short)
+	}
+	tw.Flush()
+}
+
+// parseArgs parses the flags of a command from args and checks that n
+// positional arguments remain, or at least -n if n is negative.
+func parseArgs(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	rest := fs.Args()
+	if (n >= 0 && len(rest) != n) || (n < 0 && len(rest) < -n) {
+		return nil, errUsage
+	}
+	return rest, nil
+}
+
+// splitList splits a comma-separated list, ignoring empty items.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,218 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/weilixu7/easemob/easemobtest"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+// writeConfig writes a config file pointing at srv and returns its path.
+func writeConfig(t *testing.T, srv *easemobtest.Server) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{
+		"org":           srv.Org,
+		"app":           srv.App,
+		"client_id":     srv.ClientID,
+		"client_secret": srv.ClientSecret,
+		"base_url":      srv.BaseURL(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Values that change from run to run, replaced in transcripts by
+// placeholders of the same width, which keep tables aligned. Group and
+// chatroom ids are numbered in order of appearance, so that later commands
+// can refer to them by their placeholder.
+var (
+	idPattern   = regexp.MustCompile(`\b\d{16}\b`)
+	uuidPattern = regexp.MustCompile(`\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	timePattern = regexp.MustCompile(`\b\d{4}-\d\d-\d\dT\d\d:\d\d:\d\dZ\b`)
+	msPattern   = regexp.MustCompile(`\b\d{13}\b`)
+	urlPattern  = regexp.MustCompile(`http://127\.0\.0\.1:\d+/`)
+	refPattern  = regexp.MustCompile(`\{id-\d{11}\}`)
+)
+
+// transcript runs the commands of a script, one per line, and returns
+// them with their output, exit status aside if 0.
+type transcript struct {
+	ids map[string]string // placeholder by id
+	buf bytes.Buffer
+}
+
+func (tr *transcript) normalize(s string) string {
+	s = idPattern.ReplaceAllStringFunc(s, func(id string) string {
+		ref, ok := tr.ids[id]
+		if !ok {
+			ref = fmt.Sprintf("{id-%011d}", len(tr.ids)+1)
+			tr.ids[id] = ref
+		}
+		return ref
+	})
+	s = uuidPattern.ReplaceAllString(s, "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx")
+	s = timePattern.ReplaceAllString(s, "YYYY-MM-DDThh:mm:ssZ")
+	s = msPattern.ReplaceAllString(s, "1000000000000")
+	return urlPattern.ReplaceAllString(s, "http://server/")
+}
+
+// expand replaces the placeholders of ids in an argument.
+func (tr *transcript) expand(arg string) string {
+	return refPattern.ReplaceAllStringFunc(arg, func(ref string) string {
+		for id, r := range tr.ids {
+			if r == ref {
+				return id
+			}
+		}
+		return ref
+	})
+}
+
+func (tr *transcript) run(config string, args ...string) {
+	expanded := []string{"-config", config}
+	for _, a := range args {
+		expanded = append(expanded, tr.expand(a))
+	}
+	var stdout, stderr bytes.Buffer
+	code := run(expanded, &stdout, &stderr)
+
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if strings.ContainsAny(a, " {}\"") && !refPattern.MatchString(a) {
+			a = "'" + a + "'"
+		}
+		quoted[i] = a
+	}
+	fmt.Fprintf(&tr.buf, "$ easemob %v\n", strings.Join(quoted, " "))
+	tr.buf.WriteString(tr.normalize(stdout.String()))
+	if stderr.Len() > 0 {
+		fmt.Fprintf(&tr.buf, "[stderr]\n%s", tr.normalize(stderr.String()))
+	}
+	if code != 0 {
+		fmt.Fprintf(&tr.buf, "[exit %d]\n", code)
+	}
+	tr.buf.WriteString("\n")
+}
+
+func TestGolden(t *testing.T) {
+	const id1 = "{id-00000000001}"
+
+	for _, k := range []string{"EASEMOB_CONFIG", "EASEMOB_ORG", "EASEMOB_APP", "EASEMOB_CLIENT_ID", "EASEMOB_CLIENT_SECRET", "EASEMOB_BASE_URL", "EASEMOB_TOKEN", "EASEMOB_TIMEOUT", "EASEMOB_PROFILE"} {
+		t.Setenv(k, "")
+	}
+
+	scripts := []struct {
+		name string
+		cmds [][]string
+	}{
+		{"help", [][]string{
+			{"help"},
+			{"help", "users"},
+			{"bogus"},
+			{"users", "bogus"},
+			{"users", "get"},
+			{"-o", "yaml", "users", "list"},
+		}},
+		{"users", [][]string{
+			{"users", "register", "-nickname", "Alice", "alice", "secret"},
+			{"users", "register", "bob", "secret"},
+			{"users", "register", "bob", "secret"},
+			{"users", "register", "carol", "secret"},
+			{"users", "get", "alice"},
+			{"users", "get", "nobody"},
+			{"users", "list", "-limit", "2"},
+			{"users", "list", "-all", "-limit", "2"},
+			{"-o", "json", "users", "get", "bob"},
+			{"users", "nickname", "bob", "Bob"},
+			{"users", "reset-password", "bob", "other"},
+			{"-o", "json", "users", "reset-password", "bob", "other"},
+			{"users", "delete", "carol"},
+			{"users", "list"},
+		}},
+		{"groups", [][]string{
+			{"users", "register", "alice", "secret"},
+			{"users", "register", "bob", "secret"},
+			{"users", "register", "carol", "secret"},
+			{"groups", "create", "-desc", "the team", "-public", "-maxusers", "20", "-members", "bob", "alice", "team"},
+			{"groups", "get", id1},
+			{"groups", "add-members", id1, "carol"},
+			{"groups", "members", id1},
+			{"groups", "remove-member", id1, "bob"},
+			{"groups", "update", "-name", "crew", id1},
+			{"groups", "list"},
+			{"-o", "json", "groups", "list"},
+			{"groups", "joined", "carol"},
+			{"groups", "delete", id1},
+			{"groups", "get", id1},
+		}},
+		{"chatrooms", [][]string{
+			{"users", "register", "alice", "secret"},
+			{"users", "register", "bob", "secret"},
+			{"chatrooms", "create", "-desc", "say hi", "-maxusers", "50", "alice", "lobby"},
+			{"chatrooms", "get", id1},
+			{"chatrooms", "add-members", id1, "bob"},
+			{"chatrooms", "members", id1},
+			{"chatrooms", "joined", "bob"},
+			{"chatrooms", "list"},
+			{"-o", "json", "chatrooms", "list"},
+			{"chatrooms", "remove-members", id1, "bob"},
+			{"chatrooms", "delete", id1},
+		}},
+		{"messages", [][]string{
+			{"users", "register", "alice", "secret"},
+			{"users", "register", "bob", "secret"},
+			{"messages", "send", "-from", "alice", "bob", "hello"},
+			{"-o", "json", "messages", "send", "-cmd", "-ext", `{"k":"v"}`, "alice,bob", "refresh"},
+			{"messages", "send", "-to", "planets", "bob", "hello"},
+			{"messages", "send", "-ext", "{", "bob", "hello"},
+		}},
+	}
+
+	for _, s := range scripts {
+		t.Run(s.name, func(t *testing.T) {
+			srv := easemobtest.NewServer()
+			defer srv.Close()
+			config := writeConfig(t, srv)
+
+			tr := &transcript{ids: make(map[string]string)}
+			for _, cmd := range s.cmds {
+				tr.run(config, cmd...)
+			}
+
+			golden := filepath.Join("testdata", s.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, tr.buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tr.buf.String(); got != string(want) {
+				t.Errorf("output differs from %v; run go test -update to see how:\n%s", golden, got)
+			}
+		})
+	}
+}
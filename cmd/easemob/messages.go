@@ -0,0 +1,87 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/weilixu7/easemob"
+)
+
+var messagesResource = &resource{
+	name:  "messages",
+	short: "send messages",
+	commands: []*command{
+		{"send", "[-from user] [-to users|chatgroups|chatrooms] [-cmd] [-ext json] <target,...> <text>", "send a text or command message", messagesSend},
+	},
+}
+
+func messagesSend(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	from := fs.String("from", "", "sender of the message, admin if empty")
+	to := fs.String("to", "users", "kind of targets: users, chatgroups or chatrooms")
+	cmd := fs.Bool("cmd", false, "send the text as the action of a command message")
+	extJSON := fs.String("ext", "", "extension attributes as a JSON object")
+	args, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	ids := splitList(args[0])
+	if len(ids) == 0 {
+		return errUsage
+	}
+	var target easemob.Target
+	switch *to {
+	case "users":
+		target = easemob.ToUsers(ids...)
+	case "chatgroups":
+		target = easemob.ToChatgroups(ids...)
+	case "chatrooms":
+		target = easemob.ToChatrooms(ids...)
+	default:
+		return fmt.Errorf("%w: unknown target kind %q", errUsage, *to)
+	}
+
+	var body easemob.MessageBody = &easemob.TextMessage{Msg: args[1]}
+	if *cmd {
+		body = &easemob.CommandMessage{Action: args[1]}
+	}
+
+	var ext map[string]interface{}
+	if *extJSON != "" {
+		if err := json.Unmarshal([]byte(*extJSON), &ext); err != nil {
+			return fmt.Errorf("parsing -ext: %v", err)
+		}
+	}
+
+	result, _, err := e.client.Messages.SendContext(ctx, *from, target, body, ext)
+	if err != nil {
+		return err
+	}
+	if err := e.print(result, func() *table {
+		t := &table{header: []string{"TARGET", "RESULT"}}
+		ids := make([]string, 0, len(result))
+		for id := range result {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			t.add(id, result[id])
+		}
+		return t
+	}); err != nil {
+		return err
+	}
+	if failed := result.Failed(); len(failed) > 0 {
+		return fmt.Errorf("message not delivered to %d of %d targets", len(failed), len(result))
+	}
+	return nil
+}
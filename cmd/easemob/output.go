@@ -0,0 +1,103 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/weilixu7/easemob"
+)
+
+// env is what commands run with: a client and where to print results.
+type env struct {
+	client *easemob.Client
+	out    io.Writer
+	errOut io.Writer
+	json   bool
+}
+
+// A table is the tabular form of a result.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func (t *table) add(cells ...interface{}) {
+	row := make([]string, len(cells))
+	for i, c := range cells {
+		row[i] = fmt.Sprint(c)
+	}
+	t.rows = append(t.rows, row)
+}
+
+// print prints v as JSON, or as the table t returns.
+func (e *env) print(v interface{}, t func() *table) error {
+	if e.json {
+		enc := json.NewEncoder(e.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tbl := t()
+	tw := tabwriter.NewWriter(e.out, 0, 8, 2, ' ', 0)
+	if len(tbl.header) > 0 {
+		fmt.Fprintln(tw, strings.Join(tbl.header, "\t"))
+	}
+	for _, row := range tbl.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// printList prints a list of names, one per line, or as a JSON array.
+func (e *env) printList(header string, names []string) error {
+	if names == nil {
+		names = []string{}
+	}
+	return e.print(names, func() *table {
+		t := &table{header: []string{header}}
+		for _, n := range names {
+			t.add(n)
+		}
+		return t
+	})
+}
+
+// printOK prints the outcome of a command with no other result.
+func (e *env) printOK(ok bool) error {
+	return e.print(map[string]bool{"ok": ok}, func() *table {
+		t := &table{header: []string{"OK"}}
+		t.add(ok)
+		return t
+	})
+}
+
+// printNext tells where the next page of a listing starts. It goes to the
+// error output so that the table itself stays clean.
+func (e *env) printNext(cursor string) {
+	fmt.Fprintf(e.errOut, "next page: -cursor %v\n", cursor)
+}
+
+// formatTime formats a time in milliseconds since the epoch.
+func formatTime(ms int64) string {
+	if ms == 0 {
+		return "-"
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+}
+
+// orDash returns s, or "-" if it is empty, for table cells.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
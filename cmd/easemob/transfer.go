@@ -0,0 +1,428 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/weilixu7/easemob"
+)
+
+// defaultImportChunk is the number of records registered between two
+// checkpoints of an import.
+const defaultImportChunk = 600
+
+// csvHeader is the header of exported CSV files. Imports read the username,
+// nickname and password columns, in any order; the others are ignored.
+var csvHeader = []string{"username", "nickname", "activated", "created", "modified"}
+
+// exportFormat returns the format of a users file, csv or jsonl, as given
+// by -format or else by the extension of path.
+func exportFormat(format, path string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			format = "csv"
+		case ".jsonl", ".ndjson":
+			format = "jsonl"
+		default:
+			return "", fmt.Errorf("%w: can't tell the format of %q, use -format", errUsage, path)
+		}
+	}
+	if format != "csv" && format != "jsonl" {
+		return "", fmt.Errorf("%w: unknown format %q", errUsage, format)
+	}
+	return format, nil
+}
+
+// A userWriter writes exported users.
+type userWriter interface {
+	write(u *easemob.User) error
+	flush() error
+}
+
+type csvUserWriter struct{ w *csv.Writer }
+
+func (w csvUserWriter) write(u *easemob.User) error {
+	return w.w.Write([]string{
+		u.Username,
+		u.Nickname,
+		strconv.FormatBool(u.Activated),
+		strconv.FormatInt(u.Created, 10),
+		strconv.FormatInt(u.Modified, 10),
+	})
+}
+
+func (w csvUserWriter) flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type jsonlUserWriter struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w jsonlUserWriter) write(u *easemob.User) error {
+	return w.enc.Encode(struct {
+		Username  string `json:"username"`
+		Nickname  string `json:"nickname,omitempty"`
+		Activated bool   `json:"activated"`
+		Created   int64  `json:"created"`
+		Modified  int64  `json:"modified"`
+	}{u.Username, u.Nickname, u.Activated, u.Created, u.Modified})
+}
+
+func (w jsonlUserWriter) flush() error {
+	return w.bw.Flush()
+}
+
+func usersExport(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "", "file format, csv or jsonl (default from the file extension)")
+	pageSize := fs.Int("page-size", 100, "number of users fetched per request")
+	cursor := fs.String("cursor", "", "resume an interrupted export from this cursor, appending to the file")
+	args, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	path := args[0]
+	if *format, err = exportFormat(*format, path); err != nil {
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if *cursor != "" {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w userWriter
+	if *format == "csv" {
+		cw := csv.NewWriter(f)
+		if *cursor == "" {
+			if err := cw.Write(csvHeader); err != nil {
+				return err
+			}
+		}
+		w = csvUserWriter{cw}
+	} else {
+		bw := bufio.NewWriter(f)
+		w = jsonlUserWriter{bw, json.NewEncoder(bw)}
+	}
+
+	n := 0
+	it := e.client.Users.Iter(ctx, &easemob.ListOptions{Limit: *pageSize, Cursor: *cursor})
+	for it.Next() {
+		if err := w.write(it.Value()); err != nil {
+			return err
+		}
+		n++
+	}
+	// Whatever was fetched is written out before reporting an error, so
+	// that the export can be resumed from the iterator's cursor.
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if err := it.Err(); err != nil {
+		if it.Cursor() != "" {
+			fmt.Fprintf(e.errOut, "exported %d users; resume with -cursor %v\n", n, it.Cursor())
+		}
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return e.print(map[string]int{"exported": n}, func() *table {
+		t := &table{header: []string{"EXPORTED"}}
+		t.add(n)
+		return t
+	})
+}
+
+// A userReader reads the users of an import file, one record at a time.
+// next returns io.EOF after the last record.
+type userReader interface {
+	next() (easemob.PutOptions, error)
+}
+
+type csvUserReader struct {
+	r    *csv.Reader
+	cols map[string]int
+}
+
+func newCSVUserReader(r io.Reader) (*csvUserReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.New("empty CSV file")
+		}
+		return nil, err
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := cols["username"]; !ok {
+		return nil, errors.New("CSV file has no username column")
+	}
+	return &csvUserReader{cr, cols}, nil
+}
+
+func (r *csvUserReader) next() (easemob.PutOptions, error) {
+	rec, err := r.r.Read()
+	if err != nil {
+		return easemob.PutOptions{}, err
+	}
+	col := func(name string) string {
+		if i, ok := r.cols[name]; ok && i < len(rec) {
+			return rec[i]
+		}
+		return ""
+	}
+	return easemob.PutOptions{
+		Username: col("username"),
+		Nickname: col("nickname"),
+		Password: col("password"),
+	}, nil
+}
+
+type jsonlUserReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func (r *jsonlUserReader) next() (easemob.PutOptions, error) {
+	for r.s.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.s.Text())
+		if line == "" {
+			continue
+		}
+		var u easemob.PutOptions
+		if err := json.Unmarshal([]byte(line), &u); err != nil {
+			return u, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		return u, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return easemob.PutOptions{}, err
+	}
+	return easemob.PutOptions{}, io.EOF
+}
+
+// importCheckpoint is the progress of an import, saved after each chunk so
+// that an interrupted import resumes where it stopped.
+type importCheckpoint struct {
+	Input   string         `json:"input"`
+	Done    int            `json:"done"` // records processed
+	Created int            `json:"created"`
+	Existed int            `json:"existed"`
+	Failed  []importFailed `json:"failed,omitempty"`
+}
+
+type importFailed struct {
+	Username string `json:"username"`
+	Error    string `json:"error"`
+}
+
+// loadCheckpoint reads the checkpoint at path, or returns a fresh one for
+// input if there is none.
+func loadCheckpoint(path, input string) (*importCheckpoint, error) {
+	cp := &importCheckpoint{Input: input}
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("reading checkpoint %v: %v", path, err)
+	}
+	if cp.Input != input {
+		return nil, fmt.Errorf("checkpoint %v is for %v, not %v; use -restart to discard it", path, cp.Input, input)
+	}
+	return cp, nil
+}
+
+// save writes cp to path atomically, so that a crash leaves either the
+// previous checkpoint or the new one.
+func (cp *importCheckpoint) save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// importSummary is the report printed at the end of an import.
+type importSummary struct {
+	Records int            `json:"records"`
+	Resumed int            `json:"resumed"` // records skipped as done by an earlier run
+	Created int            `json:"created"`
+	Existed int            `json:"existed"`
+	Failed  []importFailed `json:"failed"`
+}
+
+func usersImport(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "file format, csv or jsonl (default from the file extension)")
+	password := fs.String("password", "", "password of the users with none in the file")
+	chunk := fs.Int("chunk", defaultImportChunk, "number of users registered between checkpoints")
+	batch := fs.Int("batch", easemob.MaxRegisterBatch, "number of users registered per request")
+	concurrency := fs.Int("concurrency", 4, "number of requests in flight at once")
+	checkpoint := fs.String("checkpoint", "", "checkpoint `file` (default the input file with .checkpoint appended)")
+	restart := fs.Bool("restart", false, "discard the checkpoint and import from the first record")
+	args, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *chunk <= 0 {
+		return fmt.Errorf("%w: -chunk must be positive", errUsage)
+	}
+	input := args[0]
+	if *format, err = exportFormat(*format, input); err != nil {
+		return err
+	}
+	if input, err = filepath.Abs(input); err != nil {
+		return err
+	}
+	if *checkpoint == "" {
+		*checkpoint = input + ".checkpoint"
+	}
+	if *restart {
+		if err := os.Remove(*checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	cp, err := loadCheckpoint(*checkpoint, input)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r userReader
+	if *format == "csv" {
+		if r, err = newCSVUserReader(f); err != nil {
+			return fmt.Errorf("reading %v: %v", input, err)
+		}
+	} else {
+		r = &jsonlUserReader{s: bufio.NewScanner(f)}
+	}
+
+	summary := &importSummary{Resumed: cp.Done}
+	for seen := 0; ; {
+		// Read the next chunk, skipping the records done by earlier runs.
+		var users []easemob.PutOptions
+		var rerr error
+		for len(users) < *chunk {
+			u, err := r.next()
+			if err != nil {
+				rerr = err
+				break
+			}
+			if seen++; seen <= cp.Done {
+				continue
+			}
+			if u.Password == "" {
+				u.Password = *password
+			}
+			if u.Username == "" {
+				rerr = fmt.Errorf("record %d: no username", seen)
+				break
+			}
+			if u.Password == "" {
+				rerr = fmt.Errorf("record %d: no password for %v; give one with -password", seen, u.Username)
+				break
+			}
+			users = append(users, u)
+		}
+		if rerr != nil && rerr != io.EOF {
+			return fmt.Errorf("reading %v: %v", input, rerr)
+		}
+		summary.Records = seen
+
+		if len(users) > 0 {
+			report, _ := e.client.Users.BulkRegister(ctx, users, &easemob.BulkRegisterOptions{
+				BatchSize:   *batch,
+				Concurrency: *concurrency,
+			})
+			if ctx.Err() != nil {
+				// The chunk is done again on resume; the users it
+				// created by then are reported as existing.
+				fmt.Fprintf(e.errOut, "import interrupted after %d records; run it again to resume\n", cp.Done)
+				return ctx.Err()
+			}
+			for _, res := range report {
+				switch res.Status {
+				case easemob.RegisterCreated:
+					cp.Created++
+				case easemob.RegisterExisted:
+					cp.Existed++
+				default:
+					cp.Failed = append(cp.Failed, importFailed{res.Username, res.Err.Error()})
+				}
+			}
+			cp.Done += len(users)
+			if err := cp.save(*checkpoint); err != nil {
+				return err
+			}
+			fmt.Fprintf(e.errOut, "imported %d records\n", cp.Done)
+		}
+		if rerr == io.EOF {
+			break
+		}
+	}
+
+	if err := os.Remove(*checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	summary.Created, summary.Existed, summary.Failed = cp.Created, cp.Existed, cp.Failed
+	if summary.Failed == nil {
+		summary.Failed = []importFailed{}
+	}
+	if err := e.print(summary, func() *table {
+		for _, f := range summary.Failed {
+			fmt.Fprintf(e.errOut, "failed: %v: %v\n", f.Username, f.Error)
+		}
+		t := &table{header: []string{"RECORDS", "RESUMED", "CREATED", "EXISTED", "FAILED"}}
+		t.add(summary.Records, summary.Resumed, summary.Created, summary.Existed, len(summary.Failed))
+		return t
+	}); err != nil {
+		return err
+	}
+	if len(summary.Failed) > 0 {
+		return fmt.Errorf("%d of %d users failed to import", len(summary.Failed), summary.Records)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/weilixu7/easemob/easemobtest"
+)
+
+func TestUsersExport_resume(t *testing.T) {
+	srv := easemobtest.NewServer()
+	defer srv.Close()
+	var want []string
+	for i := 1; i <= 5; i++ {
+		name := fmt.Sprintf("u%d", i)
+		srv.AddUser(name, "secret")
+		want = append(want, name)
+	}
+	cfg := writeConfig(t, srv)
+	out := filepath.Join(t.TempDir(), "users.csv")
+
+	// The second page fails, once.
+	srv.Inject(easemobtest.Fault{
+		Method: "GET",
+		Path:   "users",
+		After:  1,
+		Times:  1,
+		Status: http.StatusBadRequest,
+	})
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-config", cfg, "users", "export", "-page-size", "2", out}, &stdout, &stderr); code != 1 {
+		t.Fatalf("first export exited with %d, want 1; stderr:\n%s", code, stderr.String())
+	}
+	m := regexp.MustCompile(`exported 2 users; resume with -cursor (\S+)`).FindStringSubmatch(stderr.String())
+	if m == nil {
+		t.Fatalf("no resume hint in stderr:\n%s", stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"-config", cfg, "users", "export", "-page-size", "2", "-cursor", m[1], out}, &stdout, &stderr); code != 0 {
+		t.Fatalf("resumed export exited with %d; stderr:\n%s", code, stderr.String())
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 || !reflect.DeepEqual(records[0], csvHeader) {
+		t.Fatalf("records = %v, want the CSV header first", records)
+	}
+	var got []string
+	for _, r := range records[1:] {
+		got = append(got, r[0])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exported users = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/weilixu7/easemob"
+)
+
+var usersResource = &resource{
+	name:  "users",
+	short: "register, inspect and delete users",
+	commands: []*command{
+		{"register", "[-nickname name] <username> <password>", "register a user", usersRegister},
+		{"get", "<username>", "show a user", usersGet},
+		{"list", "[-limit n] [-cursor c] [-all]", "list users", usersList},
+		{"delete", "<username>", "delete a user", usersDelete},
+		{"reset-password", "<username> <password>", "set the password of a user", usersResetPassword},
+		{"nickname", "<username> <nickname>", "set the nickname of a user", usersNickname},
+		{"export", "[-format csv|jsonl] [-page-size n] [-cursor c] <file>", "write all users to a file", usersExport},
+		{"import", "[-format csv|jsonl] [-password pw] [-chunk n] [-restart] <file>", "register the users of a file, resuming an interrupted import", usersImport},
+	},
+}
+
+func printUsers(e *env, users []*easemob.User) error {
+	if users == nil {
+		users = []*easemob.User{}
+	}
+	return e.print(users, func() *table {
+		t := &table{header: []string{"USERNAME", "NICKNAME", "ACTIVATED", "CREATED", "UUID"}}
+		for _, u := range users {
+			t.add(u.Username, orDash(u.Nickname), u.Activated, formatTime(u.Created), u.Uuid)
+		}
+		return t
+	})
+}
+
+func usersRegister(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("register", flag.ContinueOnError)
+	nickname := fs.String("nickname", "", "nickname of the user")
+	args, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	user, _, err := e.client.Users.RegisterContext(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if *nickname != "" {
+		if user, _, err = e.client.Users.EditNicknameContext(ctx, args[0], *nickname); err != nil {
+			return err
+		}
+	}
+	return printUsers(e, []*easemob.User{user})
+}
+
+func usersGet(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("get", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	user, _, err := e.client.Users.GetContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return printUsers(e, []*easemob.User{user})
+}
+
+func usersList(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	limit := fs.Int("limit", 0, "number of users per page")
+	cursor := fs.String("cursor", "", "cursor of the page to list")
+	all := fs.Bool("all", false, "list all users, page after page")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	opt := &easemob.ListOptions{Limit: *limit, Cursor: *cursor}
+	if !*all {
+		users, resp, err := e.client.Users.ListAllContext(ctx, opt)
+		if err != nil {
+			return err
+		}
+		if err := printUsers(e, users); err != nil {
+			return err
+		}
+		if resp.Cursor != "" && !e.json {
+			e.printNext(resp.Cursor)
+		}
+		return nil
+	}
+
+	var users []*easemob.User
+	it := e.client.Users.Iter(ctx, opt)
+	for it.Next() {
+		users = append(users, it.Value())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return printUsers(e, users)
+}
+
+func usersDelete(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("delete", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	user, _, err := e.client.Users.DeleteContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return printUsers(e, []*easemob.User{user})
+}
+
+func usersResetPassword(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("reset-password", flag.ContinueOnError), args, 2)
+	if err != nil {
+		return err
+	}
+	if _, err := e.client.Users.ResetPasswordContext(ctx, args[0], args[1]); err != nil {
+		return err
+	}
+	return e.printOK(true)
+}
+
+func usersNickname(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("nickname", flag.ContinueOnError), args, 2)
+	if err != nil {
+		return err
+	}
+	user, _, err := e.client.Users.EditNicknameContext(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	return printUsers(e, []*easemob.User{user})
+}
@@ -0,0 +1,353 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package config loads Easemob apps and credentials from a configuration
+// file of named profiles, one per app, and creates clients from them.
+//
+// A configuration file is written in YAML, TOML or JSON:
+//
+//	default: dev
+//	profiles:
+//	  dev:
+//	    org: acme
+//	    app: chat-dev
+//	    client_id: YXA6...
+//	    client_secret: ${EASEMOB_DEV_SECRET}
+//	  prod:
+//	    org: acme
+//	    app: chat
+//	    client_id: YXA6...
+//	    client_secret: ${EASEMOB_PROD_SECRET}
+//	    base_url: https://a1-sgp.easemob.com/
+//	    timeout: 10s
+//
+// References to environment variables, ${NAME} or ${NAME:-default}, are
+// expanded in the string fields of a profile when it is selected, so that
+// secrets need not be written in the file; the profiles not in use may refer
+// to variables that are not set. A file without profiles holds a single
+// profile, named "default".
+//
+// Environment variables override the fields of the profile in use:
+// EASEMOB_ORG, EASEMOB_APP, EASEMOB_CLIENT_ID, EASEMOB_CLIENT_SECRET,
+// EASEMOB_BASE_URL, EASEMOB_TOKEN and EASEMOB_TIMEOUT. EASEMOB_PROFILE names
+// the profile used when none is given.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/weilixu7/easemob"
+)
+
+// DefaultProfile is the name of the single profile of a file without
+// profiles, and of the configuration made by FromEnv.
+const DefaultProfile = "default"
+
+// Format is the format of a configuration file.
+type Format string
+
+// Formats of configuration files.
+const (
+	JSON Format = "json"
+	YAML Format = "yaml"
+	TOML Format = "toml"
+)
+
+// FormatOf returns the format of the configuration file at path, as told
+// by its extension.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".toml":
+		return TOML, nil
+	}
+	return "", fmt.Errorf("config: unknown format of %v", path)
+}
+
+// A Profile holds the app a client works on and how it connects.
+type Profile struct {
+	Org          string   `json:"org"`
+	App          string   `json:"app"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Token        string   `json:"token,omitempty"`
+	BaseURL      string   `json:"base_url,omitempty"`
+	UserAgent    string   `json:"user_agent,omitempty"`
+	Timeout      Duration `json:"timeout,omitempty"`
+
+	// TokenFile, if set, is the path of a file through which clients of
+	// the profile share their token, as with easemob.FileTokenStore.
+	TokenFile string `json:"token_file,omitempty"`
+}
+
+// Options returns the client options configuring a client as p says.
+func (p *Profile) Options() []easemob.Option {
+	opts := []easemob.Option{easemob.WithApp(p.Org, p.App)}
+	if p.ClientID != "" || p.ClientSecret != "" {
+		opts = append(opts, easemob.WithCredentials(p.ClientID, p.ClientSecret))
+	}
+	if p.Token != "" {
+		opts = append(opts, easemob.WithToken(p.Token))
+	}
+	if p.BaseURL != "" {
+		opts = append(opts, easemob.WithBaseURL(p.BaseURL))
+	}
+	if p.UserAgent != "" {
+		opts = append(opts, easemob.WithUserAgent(p.UserAgent))
+	}
+	if p.Timeout > 0 {
+		opts = append(opts, easemob.WithTimeout(time.Duration(p.Timeout)))
+	}
+	if p.TokenFile != "" {
+		opts = append(opts, easemob.WithTokenStore(easemob.NewFileTokenStore(p.TokenFile)))
+	}
+	return opts
+}
+
+// Client returns a client configured by p. opts are applied after the
+// profile's own options.
+func (p *Profile) Client(opts ...easemob.Option) (*easemob.Client, error) {
+	return easemob.NewClient(append(p.Options(), opts...)...)
+}
+
+// Duration is a time.Duration written as a string such as "10s" or "1m30s",
+// or as a number of seconds.
+type Duration time.Duration
+
+// UnmarshalJSON decodes a duration from a string or a number of seconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var secs float64
+		if err := json.Unmarshal(data, &secs); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(secs * float64(time.Second))
+		return nil
+	}
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON encodes a duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Config is the content of a configuration file.
+type Config struct {
+	// Default names the profile used when none is given and
+	// EASEMOB_PROFILE is not set.
+	Default string `json:"default,omitempty"`
+
+	Profiles map[string]*Profile `json:"profiles"`
+
+	// LookupEnv looks up the environment variables overriding profiles
+	// and those their fields refer to. If nil, os.LookupEnv is used.
+	LookupEnv func(name string) (string, bool) `json:"-"`
+}
+
+// Load reads the configuration file at path, in the format its extension
+// tells.
+func Load(path string) (*Config, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("config: reading %v: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse parses a configuration in the given format. References to
+// environment variables are kept as written, to be expanded by Profile.
+func Parse(data []byte, format Format) (*Config, error) {
+	var raw map[string]interface{}
+	switch format {
+	case JSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+	case YAML:
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	case TOML:
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("config: unknown format %q", format)
+	}
+
+	var top interface{} = raw
+	if _, ok := raw["profiles"]; !ok {
+		top = wrapProfile(raw)
+	}
+
+	// The values go through JSON so that a single set of field names and
+	// decoding rules covers all formats.
+	norm, err := json.Marshal(top)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Config)
+	dec := json.NewDecoder(bytes.NewReader(norm))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, err
+	}
+	for name, p := range c.Profiles {
+		if p == nil {
+			c.Profiles[name] = new(Profile)
+		}
+	}
+	return c, nil
+}
+
+// wrapProfile turns the top level of a file without profiles into a single
+// profile named DefaultProfile, leaving the fields of Config, such as
+// default, where they are.
+func wrapProfile(m map[string]interface{}) map[string]interface{} {
+	top := make(map[string]interface{})
+	profile := make(map[string]interface{})
+	for k, v := range m {
+		if k == "default" {
+			top[k] = v
+		} else {
+			profile[k] = v
+		}
+	}
+	if len(profile) > 0 {
+		top["profiles"] = map[string]interface{}{DefaultProfile: profile}
+	}
+	return top
+}
+
+// Names returns the names of the profiles, sorted.
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns a copy of the named profile with its references to
+// environment variables expanded and the environment overrides applied.
+// If name is empty, the profile named by
+// EASEMOB_PROFILE, by c.Default, or the only profile is used, in that order.
+func (c *Config) Profile(name string) (*Profile, error) {
+	lookup := c.lookupEnv()
+	if name == "" {
+		name, _ = lookup("EASEMOB_PROFILE")
+	}
+	if name == "" {
+		name = c.Default
+	}
+	if name == "" {
+		if len(c.Profiles) != 1 {
+			return nil, errors.New("config: no profile given and no default profile")
+		}
+		for n := range c.Profiles {
+			name = n
+		}
+	}
+
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("config: no profile %q", name)
+	}
+	cp := *p
+	if err := expandProfile(&cp, lookup); err != nil {
+		return nil, fmt.Errorf("config: profile %q: %w", name, err)
+	}
+	if err := applyEnv(&cp, lookup); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// Client returns a client configured by the named profile, as for Profile.
+// opts are applied after the profile's own options.
+func (c *Config) Client(name string, opts ...easemob.Option) (*easemob.Client, error) {
+	p, err := c.Profile(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.Client(opts...)
+}
+
+func (c *Config) lookupEnv() func(string) (string, bool) {
+	if c.LookupEnv != nil {
+		return c.LookupEnv
+	}
+	return os.LookupEnv
+}
+
+// FromEnv returns a configuration holding a single default profile made of
+// the environment overrides alone, for when there is no configuration file.
+func FromEnv() *Config {
+	return &Config{Profiles: map[string]*Profile{DefaultProfile: {}}}
+}
+
+// applyEnv applies the environment overrides to p.
+func applyEnv(p *Profile, lookup func(string) (string, bool)) error {
+	for _, v := range []struct {
+		name string
+		dst  *string
+	}{
+		{"EASEMOB_ORG", &p.Org},
+		{"EASEMOB_APP", &p.App},
+		{"EASEMOB_CLIENT_ID", &p.ClientID},
+		{"EASEMOB_CLIENT_SECRET", &p.ClientSecret},
+		{"EASEMOB_TOKEN", &p.Token},
+		{"EASEMOB_BASE_URL", &p.BaseURL},
+	} {
+		if s, ok := lookup(v.name); ok && s != "" {
+			*v.dst = s
+		}
+	}
+	if s, ok := lookup("EASEMOB_TIMEOUT"); ok && s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("config: invalid EASEMOB_TIMEOUT: %v", err)
+		}
+		p.Timeout = Duration(d)
+	}
+	return nil
+}
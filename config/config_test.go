@@ -0,0 +1,212 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// noEnv is a LookupEnv finding no variables.
+func noEnv(string) (string, bool) { return "", false }
+
+// env returns a LookupEnv finding the variables of vars only.
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func TestParse_profiles(t *testing.T) {
+	files := []struct {
+		format Format
+		data   string
+	}{
+		{YAML, `
+default: prod
+profiles:
+  dev:
+    org: acme
+    app: chat-dev
+    client_id: id
+    client_secret: ${CONFIG_TEST_SECRET}
+  prod:
+    org: acme
+    app: chat
+    client_id: id
+    client_secret: ${CONFIG_TEST_UNSET:-fallback}
+    timeout: 10s
+`},
+		{TOML, `
+default = "prod"
+[profiles.dev]
+org = "acme"
+app = "chat-dev"
+client_id = "id"
+client_secret = "${CONFIG_TEST_SECRET}"
+[profiles.prod]
+org = "acme"
+app = "chat"
+client_id = "id"
+client_secret = "${CONFIG_TEST_UNSET:-fallback}"
+timeout = "10s"
+`},
+		{JSON, `{
+	"default": "prod",
+	"profiles": {
+		"dev": {"org": "acme", "app": "chat-dev", "client_id": "id", "client_secret": "${CONFIG_TEST_SECRET}"},
+		"prod": {"org": "acme", "app": "chat", "client_id": "id", "client_secret": "${CONFIG_TEST_UNSET:-fallback}", "timeout": 10}
+	}
+}`},
+	}
+	for _, f := range files {
+		c, err := Parse([]byte(f.data), f.format)
+		if err != nil {
+			t.Fatalf("Parse(%v) returned error: %v", f.format, err)
+		}
+		c.LookupEnv = env(map[string]string{"CONFIG_TEST_SECRET": "s3cret"})
+		if want := []string{"dev", "prod"}; !reflect.DeepEqual(c.Names(), want) {
+			t.Errorf("Parse(%v) profiles = %v, want %v", f.format, c.Names(), want)
+		}
+		p, err := c.Profile("")
+		if err != nil {
+			t.Fatalf("Parse(%v): Profile returned error: %v", f.format, err)
+		}
+		want := &Profile{Org: "acme", App: "chat", ClientID: "id", ClientSecret: "fallback", Timeout: Duration(10 * time.Second)}
+		if !reflect.DeepEqual(p, want) {
+			t.Errorf("Parse(%v): default profile = %+v, want %+v", f.format, p, want)
+		}
+		if p, _ := c.Profile("dev"); p == nil || p.ClientSecret != "s3cret" {
+			t.Errorf("Parse(%v): dev profile = %+v, want the secret expanded", f.format, p)
+		}
+	}
+}
+
+func TestParse_singleProfile(t *testing.T) {
+	tests := []struct {
+		format Format
+		data   string
+	}{
+		{JSON, `{"org": "acme", "app": "chat", "client_id": "id", "client_secret": "s"}`},
+		{YAML, "default: default\norg: acme\napp: chat\nclient_id: id\nclient_secret: s\n"},
+		{TOML, "default = \"default\"\norg = \"acme\"\napp = \"chat\"\nclient_id = \"id\"\nclient_secret = \"s\"\n"},
+	}
+	want := &Profile{Org: "acme", App: "chat", ClientID: "id", ClientSecret: "s"}
+	for _, tt := range tests {
+		c, err := Parse([]byte(tt.data), tt.format)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.data, err)
+		}
+		c.LookupEnv = noEnv
+		if names := c.Names(); !reflect.DeepEqual(names, []string{DefaultProfile}) {
+			t.Errorf("Parse(%q) profiles = %v, want [%v]", tt.data, names, DefaultProfile)
+		}
+		p, err := c.Profile("")
+		if err != nil {
+			t.Fatalf("Parse(%q): Profile returned error: %v", tt.data, err)
+		}
+		if !reflect.DeepEqual(p, want) {
+			t.Errorf("Parse(%q) profile = %+v, want %+v", tt.data, p, want)
+		}
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	tests := []string{
+		`{"org": "acme", "clientid": "id"}`,
+		`{"profiles": {"dev": {"timeout": "soon"}}}`,
+	}
+	for _, data := range tests {
+		if _, err := Parse([]byte(data), JSON); err == nil {
+			t.Errorf("Parse(%q) returned no error", data)
+		}
+	}
+}
+
+func TestConfig_Profile_expand(t *testing.T) {
+	c, err := Parse([]byte(`
+profiles:
+  dev:
+    org: acme
+    app: ${CONFIG_TEST_APP}-dev
+    client_secret: ${CONFIG_TEST_DEV_SECRET}
+    token_file: ${CONFIG_TEST_DIR:-/tmp}/token.json
+  prod:
+    org: acme
+    app: ${CONFIG_TEST_APP}
+    client_secret: $${CONFIG_TEST_PROD_SECRET} ${CONFIG_TEST_UNSET:-}
+`), YAML)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if p := c.Profiles["dev"]; p.ClientSecret != "${CONFIG_TEST_DEV_SECRET}" {
+		t.Errorf("Parse expanded the stored profile: %+v", p)
+	}
+
+	// The prod profile doesn't depend on the variables dev refers to.
+	c.LookupEnv = env(map[string]string{"CONFIG_TEST_APP": "chat"})
+	p, err := c.Profile("prod")
+	if err != nil {
+		t.Fatalf("Profile(prod) returned error: %v", err)
+	}
+	want := &Profile{Org: "acme", App: "chat", ClientSecret: "${CONFIG_TEST_PROD_SECRET} "}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("Profile(prod) = %+v, want %+v", p, want)
+	}
+
+	_, err = c.Profile("dev")
+	if err == nil || !strings.Contains(err.Error(), "client_secret") || !strings.Contains(err.Error(), "CONFIG_TEST_DEV_SECRET") {
+		t.Errorf("Profile(dev) returned %v, want an error naming the unset variable", err)
+	}
+
+	// Variables are looked up through LookupEnv, not the process
+	// environment.
+	t.Setenv("CONFIG_TEST_DEV_SECRET", "from-process")
+	c.LookupEnv = env(map[string]string{"CONFIG_TEST_APP": "chat", "CONFIG_TEST_DEV_SECRET": "s3cret"})
+	p, err = c.Profile("dev")
+	if err != nil {
+		t.Fatalf("Profile(dev) returned error: %v", err)
+	}
+	want = &Profile{Org: "acme", App: "chat-dev", ClientSecret: "s3cret", TokenFile: "/tmp/token.json"}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("Profile(dev) = %+v, want %+v", p, want)
+	}
+}
+
+func TestConfig_Profile_env(t *testing.T) {
+	c := &Config{
+		Default: "dev",
+		Profiles: map[string]*Profile{
+			"dev":  {Org: "acme", App: "chat-dev"},
+			"prod": {Org: "acme", App: "chat"},
+		},
+		LookupEnv: func(name string) (string, bool) {
+			v, ok := map[string]string{
+				"EASEMOB_PROFILE": "prod",
+				"EASEMOB_APP":     "override",
+				"EASEMOB_TIMEOUT": "3s",
+			}[name]
+			return v, ok
+		},
+	}
+	p, err := c.Profile("")
+	if err != nil {
+		t.Fatalf("Profile returned error: %v", err)
+	}
+	want := &Profile{Org: "acme", App: "override", Timeout: Duration(3 * time.Second)}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("Profile = %+v, want %+v", p, want)
+	}
+	if c.Profiles["prod"].App != "chat" {
+		t.Errorf("Profile modified the stored profile: %+v", c.Profiles["prod"])
+	}
+	if _, err := c.Profile("staging"); err == nil {
+		t.Error("Profile(staging) returned no error")
+	}
+}
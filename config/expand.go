@@ -0,0 +1,86 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expandProfile expands the references to environment variables in the
+// string fields of p.
+func expandProfile(p *Profile, lookup func(string) (string, bool)) error {
+	for _, f := range []struct {
+		name string
+		v    *string
+	}{
+		{"org", &p.Org},
+		{"app", &p.App},
+		{"client_id", &p.ClientID},
+		{"client_secret", &p.ClientSecret},
+		{"token", &p.Token},
+		{"base_url", &p.BaseURL},
+		{"user_agent", &p.UserAgent},
+		{"token_file", &p.TokenFile},
+	} {
+		s, err := expand(*f.v, lookup)
+		if err != nil {
+			return fmt.Errorf("%v: %w", f.name, err)
+		}
+		*f.v = s
+	}
+	return nil
+}
+
+// expand replaces the references ${NAME} and ${NAME:-default} in s by the
+// value of the environment variable NAME, or by default if NAME is unset or
+// empty. A reference to an unset variable without a default is an error, so
+// that a missing secret is noticed. $$ stands for a literal $.
+func expand(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '$' || i+1 == len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated reference in %q", s)
+			}
+			ref := s[i+2 : i+2+end]
+			name, def, hasDef := ref, "", false
+			if j := strings.Index(ref, ":-"); j >= 0 {
+				name, def, hasDef = ref[:j], ref[j+2:], true
+			}
+			if name == "" {
+				return "", fmt.Errorf("empty reference in %q", s)
+			}
+			val, ok := lookup(name)
+			switch {
+			case ok && val != "":
+				b.WriteString(val)
+			case hasDef:
+				b.WriteString(def)
+			case !ok:
+				return "", fmt.Errorf("environment variable %v is not set", name)
+			}
+			i += 2 + end
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
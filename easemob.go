@@ -7,6 +7,7 @@ package easemob
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,21 +15,26 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/google/go-querystring/query"
 )
 
 const (
-	debug        = false
-	repeat_times = 3
-	baseURL      = "https://a1.easemob.com/"
-	grantTYPE    = "client_credentials"
-	mediaType    = "application/json"
+	debug     = false
+	grantTYPE = "client_credentials"
+	mediaType = "application/json"
+	userAgent = "go-easemob"
 )
 
-var (
-	repeat = 0
+// Base URLs of the Easemob REST API. Apps outside the default cluster, such
+// as those on regional clusters, must use the base URL shown in their
+// console.
+const (
+	DefaultBaseURL   = "https://a1.easemob.com/"
+	SingaporeBaseURL = "https://a1-sgp.easemob.com/"
+	A61BaseURL       = "https://a61.easemob.com/"
 )
 
 // A Client manages communication with the Easemob API
@@ -46,13 +52,39 @@ type Client struct {
 	// Credential
 	Credentials *Credentials
 
+	// User agent used when communicating with the API.
+	UserAgent string
+
+	// Logger, if set, receives notices about retries and other events
+	// which don't fail a request.
+	Logger Logger
+
 	// Authorization
 	Token   string
 	Expires int64
 
-	Users    *UsersService
-	Messages *MessagesService
-	Groups   *GroupService
+	// RetryPolicy controls how requests failing with a transient error are
+	// retried. If nil, DefaultRetryPolicy is used.
+	RetryPolicy *RetryPolicy
+
+	// RateLimiter, if set, holds requests back to stay within Easemob's
+	// per-endpoint quotas. Every attempt, including retries, counts.
+	RateLimiter *RateLimiter
+
+	// TokenStore, if set, is consulted for a shared token before a new one
+	// is requested, and receives every newly requested token.
+	TokenStore TokenStore
+
+	tokenMu     sync.Mutex
+	tokenExpiry time.Time
+	tokenCall   *tokenCall
+
+	Users     *UsersService
+	Messages  *MessagesService
+	Groups    *GroupService
+	Chatrooms *ChatroomService
+	Files     *FilesService
+	History   *HistoryService
 }
 
 // ListOptions specifies the optional parameters to various list methods that
@@ -64,6 +96,13 @@ type ListOptions struct {
 	QL     string `url:"ql,omitempty"`
 }
 
+// PageOptions specifies the page to retrieve from list methods paginated by
+// page number rather than by cursor.
+type PageOptions struct {
+	PageNum  int `url:"pagenum,omitempty"`
+	PageSize int `url:"pagesize,omitempty"`
+}
+
 // PutOptions specifies the parameters to various put methods.
 type PutOptions struct {
 	Username    string   `json:"username,omitempty"`
@@ -72,6 +111,7 @@ type PutOptions struct {
 	NewPass     string   `json:"newpassword,omitempty"`
 	Usernames   []string `json:"usernames,omitempty"`
 	Groupname   string   `json:"groupname,omitempty"`
+	Name        string   `json:"name,omitempty"`
 	Description string   `json:"description,omitempty"`
 	Maxusers    int      `json:"maxusers,omitempty"`
 }
@@ -96,16 +136,11 @@ type MessagePutOptions struct {
 	 *                值不能是“ext:null“这种形式，否则出错
 	 */
 
-	TargetType string            `json:"target_type"`
-	Target     []string          `json:"target"`
-	Msg        *MessageType      `json:"msg"`
-	From       string            `json:"from,omitempty"`
-	Extend     map[string]string `json:"ext,omitempty"`
-}
-
-type MessageType struct {
-	Type string `json:"type"`
-	Msg  string `json:"msg"`
+	TargetType string                 `json:"target_type"`
+	Target     []string               `json:"target"`
+	Msg        MessageBody            `json:"msg"`
+	From       string                 `json:"from,omitempty"`
+	Extend     map[string]interface{} `json:"ext,omitempty"`
 }
 
 // addOptions adds the parameters in opt as URL query parameters to s.
@@ -135,28 +170,69 @@ type Credentials struct {
 	Secret    string `json:"client_secret"`
 }
 
-// NewClient returns a new Easemob API client
-func NewClient(clientId string, clientSecret string, orgName string, appName string, token string) (*Client, error) {
+// NewClient returns a new Easemob API client configured by opts. An org and
+// app name, and either client credentials or a token, are required.
+//
+//	client, err := easemob.NewClient(
+//		easemob.WithApp("org", "app"),
+//		easemob.WithCredentials(clientID, clientSecret),
+//	)
+func NewClient(opts ...Option) (*Client, error) {
+	cfg := &clientConfig{
+		httpClient: http.DefaultClient,
+		baseURL:    DefaultBaseURL,
+		userAgent:  userAgent,
+	}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	c := new(Client)
-	c.client = http.DefaultClient
-	c.BaseURL, _ = url.Parse(baseURL)
-	c.OrgName = orgName
-	c.AppName = appName
+	c.client = cfg.httpClient
+	if cfg.timeout > 0 {
+		hc := *cfg.httpClient
+		hc.Timeout = cfg.timeout
+		c.client = &hc
+	}
+	c.BaseURL, _ = url.Parse(cfg.baseURL)
+	c.OrgName = cfg.orgName
+	c.AppName = cfg.appName
 	c.Credentials = &Credentials{
 		GrantType: grantTYPE,
-		ClientId:  clientId,
-		Secret:    clientSecret}
+		ClientId:  cfg.clientID,
+		Secret:    cfg.clientSecret}
 
-	c.Token = token
+	c.Token = cfg.token
+	c.UserAgent = cfg.userAgent
+	c.Logger = cfg.logger
+	c.RetryPolicy = cfg.retryPolicy
+	c.RateLimiter = cfg.rateLimiter
+	c.TokenStore = cfg.tokenStore
 
 	c.Users = &UsersService{client: c}
 	c.Messages = &MessagesService{client: c}
 	c.Groups = &GroupService{client: c}
+	c.Chatrooms = &ChatroomService{client: c}
+	c.Files = &FilesService{client: c}
+	c.History = &HistoryService{client: c}
 
 	return c, nil
 }
 
-// Response is a Easemob API response.
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+	}
+}
+
+// Response is a Easemob API response. It carries the HTTP response and the
+// metadata of the JSON envelope; service methods decode the payload found in
+// Entities or Data into typed results.
 type Response struct {
 	*http.Response
 
@@ -169,24 +245,49 @@ type Response struct {
 		Limit  []string
 		Cursor []string
 	} `json:"params,omitempty"`
-	Path             string  `json:"path,omitempty"`
-	URI              string  `json:"uri,omitempty"`
-	Timestamp        int64   `json:"timestamp,omitempty"`
-	Duration         int     `json:"duration,omitempty"`
-	Organization     string  `json:"organization,omitempty"`
-	ApplicationName  string  `json:"applicationName,omitempty"`
-	Cursor           string  `json:"cursor,omitempty"`
-	Count            int     `json:"count,omitempty"`
-	Entities         []*User `json:"entities,omitempty"`
-	Error            string  `json:"error,omitempty"`
-	Exception        string  `json:"exception,omitempty"`
-	ErrorDescription string  `json:"error_description,omitempty"`
-	// Data             []string `json:"data,omitempty"`
+	Path             string          `json:"path,omitempty"`
+	URI              string          `json:"uri,omitempty"`
+	Timestamp        int64           `json:"timestamp,omitempty"`
+	Duration         int             `json:"duration,omitempty"`
+	Organization     string          `json:"organization,omitempty"`
+	ApplicationName  string          `json:"applicationName,omitempty"`
+	Cursor           string          `json:"cursor,omitempty"`
+	Count            int             `json:"count,omitempty"`
+	Entities         json.RawMessage `json:"entities,omitempty"`
+	Data             json.RawMessage `json:"data,omitempty"`
+	Error            string          `json:"error,omitempty"`
+	Exception        string          `json:"exception,omitempty"`
+	ErrorDescription string          `json:"error_description,omitempty"`
+}
+
+// decodeEntities decodes the entities of r into v.
+func (r *Response) decodeEntities(v interface{}) error {
+	if len(r.Entities) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Entities, v)
+}
+
+// decodeData decodes the data of r into v.
+func (r *Response) decodeData(v interface{}) error {
+	if len(r.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Data, v)
+}
+
+// firstUser returns the user entity of r, or nil if it has none.
+func (r *Response) firstUser() (*User, error) {
+	var users []*User
+	if err := r.decodeEntities(&users); err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return users[0], nil
 }
 
 type User struct {
 	Uuid                     string `json:"uuid"`
-	Type                     string `json:"user"`
+	Type                     string `json:"type"`
 	Created                  int64  `json:"created"`
 	Modified                 int64  `json:"modified"`
 	Username                 string `json:"username"`
@@ -198,24 +299,40 @@ type User struct {
 }
 
 // NewRequest creates an API request. A relative URL can be provided in urlStr
-// in which case it is resolved relative to the BaesURL of the Client.
+// in which case it is resolved relative to the BaesURL of the Client. The
+// Authorization header is filled in with a valid token when the request is
+// sent with Do.
 func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Request, error) {
-	req, err := c.buildRequest(method, urlStr, body)
-	req.Header.Add("Authorization", fmt.Sprintf("%s %s", "Bearer", c.Token))
-	return req, err
+	return c.NewRequestContext(context.Background(), method, urlStr, body)
+}
+
+// NewRequestContext is like NewRequest but the request carries ctx, which
+// bounds the request as well as any token refresh and retry it triggers.
+func (c *Client) NewRequestContext(ctx context.Context, method, urlStr string, body interface{}) (*http.Request, error) {
+	req, err := c.buildRequest(ctx, method, urlStr, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "")
+	return req, nil
 }
 
 func (c *Client) NewRequestWithoutAuth(method, urlStr string, body interface{}) (*http.Request, error) {
-	return c.buildRequest(method, urlStr, body)
+	return c.NewRequestWithoutAuthContext(context.Background(), method, urlStr, body)
 }
 
-func (c *Client) buildRequest(method, urlStr string, body interface{}) (*http.Request, error) {
-	rel, err := url.Parse(fmt.Sprintf("%v/%v/%v", c.OrgName, c.AppName, urlStr))
+// NewRequestWithoutAuthContext is like NewRequestWithoutAuth but the request
+// carries ctx.
+func (c *Client) NewRequestWithoutAuthContext(ctx context.Context, method, urlStr string, body interface{}) (*http.Request, error) {
+	return c.buildRequest(ctx, method, urlStr, body)
+}
+
+func (c *Client) buildRequest(ctx context.Context, method, urlStr string, body interface{}) (*http.Request, error) {
+	u, err := c.resolve(urlStr)
 	if err != nil {
 		return nil, err
 	}
 
-	u := c.BaseURL.ResolveReference(rel)
 	var buf io.ReadWriter
 	if body != nil {
 		buf = new(bytes.Buffer)
@@ -225,90 +342,173 @@ func (c *Client) buildRequest(method, urlStr string, body interface{}) (*http.Re
 		}
 	}
 
-	req, err := http.NewRequest(method, u.String(), buf)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), buf)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Add("Content-Type", mediaType)
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
 	return req, nil
 }
 
-// Do sends an API request and returns the API response.
-func (c *Client) Do(req *http.Request) (*Response, error) {
-
-	resp, err := c.client.Do(req)
-	body, _ := ioutil.ReadAll(resp.Body)
-	defer resp.Body.Close()
+// newStreamRequest creates an authorized API request whose body, if any, is
+// read from r and sent as is. Such requests can't be retried unless r is a
+// *bytes.Buffer, *bytes.Reader or *strings.Reader.
+func (c *Client) newStreamRequest(ctx context.Context, method, urlStr string, r io.Reader, contentType string) (*http.Request, error) {
+	u, err := c.resolve(urlStr)
+	if err != nil {
+		return nil, err
+	}
 
-	code := resp.StatusCode
-	if code == 408 {
-		//timeout repeat req
-		if repeat < repeat_times {
-			repeat++
-			return c.Do(req)
-		}
-	} else if code == 503 {
-		//limit req
-		time.Sleep(500 * time.Millisecond)
-		if repeat < repeat_times {
-			repeat++
-			return c.Do(req)
-		}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, err
 	}
-	err = CheckResponse(resp)
 
-	response := new(Response)
-	json.Unmarshal(body, response)
-	repeat = 0
-	response.Response = resp
-	return response, err
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	req.Header.Set("Authorization", "")
+	return req, nil
 }
 
-// Token get auth token
-func (c *Client) GetToken() error {
-	var u string
-	u = "token"
-
-	req, err := c.NewRequestWithoutAuth("POST", u, c.Credentials)
+// resolve returns the URL of the app endpoint urlStr.
+func (c *Client) resolve(urlStr string) (*url.URL, error) {
+	rel, err := url.Parse(fmt.Sprintf("%v/%v/%v", c.OrgName, c.AppName, urlStr))
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return c.BaseURL.ResolveReference(rel), nil
+}
 
-	resp, err := c.Do(req)
-	if err != nil {
-		return err
+// Do sends an API request and returns the API response. Requests built with
+// NewRequest are authorized with a valid token, fetching or refreshing it as
+// needed, and are retried once with a fresh token if the API answers 401.
+// Requests failing with a transient error are retried according to the
+// client's RetryPolicy.
+func (c *Client) Do(req *http.Request) (*Response, error) {
+	return c.do(req, nil)
+}
+
+// DoContext is like Do but sends req with ctx in place of its own context.
+func (c *Client) DoContext(ctx context.Context, req *http.Request) (*Response, error) {
+	return c.do(req.WithContext(ctx), nil)
+}
+
+// do sends req as described for Do. If w is not nil, the body of a
+// successful response is streamed to w rather than decoded.
+func (c *Client) do(req *http.Request, w io.Writer) (*Response, error) {
+	ctx := req.Context()
+	policy := c.RetryPolicy
+	if policy == nil {
+		policy = &DefaultRetryPolicy
 	}
 
-	c.Token = resp.AccessToken
-	c.Expires = resp.Expires
+	orig := req
+	reauthed := false
+	for attempt := 1; ; {
+		token, err := c.authorize(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.RateLimiter != nil {
+			if err := c.RateLimiter.Wait(ctx, c.endpointCategory(req)); err != nil {
+				return nil, err
+			}
+		}
 
-	return nil
-}
+		resp, body, err := c.send(req, w)
+		switch {
+		case err != nil && resp != nil:
+			// Part of the body may have reached w already.
+			return nil, err
+		case err != nil:
+			if ctx.Err() != nil || attempt >= policy.attempts() || !policy.retryError(req) || !rewindable(orig) {
+				return nil, err
+			}
+		case resp.StatusCode == http.StatusUnauthorized && token != "" && !reauthed && rewindable(orig):
+			reauthed = true
+			if err := c.refreshToken(ctx, token); err != nil {
+				return nil, err
+			}
+			if req, err = rewindRequest(orig); err != nil {
+				return nil, err
+			}
+			continue
+		case attempt < policy.attempts() && policy.retryStatus(req, resp.StatusCode) && rewindable(orig):
+		default:
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			err = CheckResponse(resp)
+
+			response := new(Response)
+			response.Response = resp
+			if len(bytes.TrimSpace(body)) > 0 {
+				if jerr := json.Unmarshal(body, response); jerr != nil && err == nil {
+					err = fmt.Errorf("easemob: decoding response to %v %v: %w", req.Method, req.URL, jerr)
+				}
+			}
+			return response, err
+		}
 
-// An ErrorResponse reports one or more errors caused by an API request.
-// Easemob docs: http://www.easemob.com/docs/helps/errorcodes/
-type ErrorResponse struct {
-	Response *http.Response // HTTP response that caused this error
-	Message  string         `json:"message"` // error message
+		delay := policy.backoff(attempt, resp)
+		if err != nil {
+			c.logf("easemob: %v %v failed, retrying in %v: %v", req.Method, req.URL, delay, err)
+		} else {
+			c.logf("easemob: %v %v answered %d, retrying in %v", req.Method, req.URL, resp.StatusCode, delay)
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+		attempt++
+		if req, err = rewindRequest(orig); err != nil {
+			return nil, err
+		}
+	}
 }
 
-func (r *ErrorResponse) Error() string {
-	return fmt.Sprintf("%v %v: %d %v",
-		r.Response.Request.Method, r.Response.Request.URL,
-		r.Response.StatusCode, r.Message)
+// authorize sets the Authorization header of a request built with
+// NewRequest to a valid token and returns that token. Other requests are
+// left alone.
+func (c *Client) authorize(req *http.Request) (string, error) {
+	if _, ok := req.Header["Authorization"]; !ok {
+		return "", nil
+	}
+	token, err := c.accessToken(req.Context())
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("%s %s", "Bearer", token))
+	return token, nil
 }
 
-// CheckResponse checks the API response for errors, and returns them if
-// present.
-func CheckResponse(r *http.Response) error {
-	if c := r.StatusCode; 200 <= c && c <= 299 {
-		return nil
+// send makes a single attempt at req and returns the response along with its
+// drained body. If w is not nil, the body of a successful response is copied
+// to w instead, and an error doing so is returned along with the response.
+func (c *Client) send(req *http.Request, w io.Writer) (*http.Response, []byte, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
 	}
-	errorResponse := &ErrorResponse{Response: r}
-	data, err := ioutil.ReadAll(r.Body)
-	if err == nil && data != nil {
-		json.Unmarshal(data, errorResponse)
+	defer resp.Body.Close()
+
+	if w != nil && 200 <= resp.StatusCode && resp.StatusCode <= 299 {
+		if b, ok := w.(interface{ begin(*http.Response) }); ok {
+			b.begin(resp)
+		}
+		_, err := io.Copy(w, resp.Body)
+		return resp, nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
 	}
-	return errorResponse
+	return resp, body, nil
 }
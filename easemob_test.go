@@ -0,0 +1,63 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// setup starts a test HTTP server and returns a client of org/app talking
+// to it, holding a token without expiry, along with the mux on which tests
+// register the API endpoints they use. The server is closed when the test
+// ends.
+func setup(t *testing.T) (*Client, *http.ServeMux) {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(
+		WithApp("org", "app"),
+		WithCredentials("id", "secret"),
+		WithToken("test-token"),
+		WithBaseURL(server.URL+"/"),
+	)
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+	return client, mux
+}
+
+func testMethod(t *testing.T, r *http.Request, want string) {
+	t.Helper()
+	if got := r.Method; got != want {
+		t.Errorf("Request method: %v, want %v", got, want)
+	}
+}
+
+// testJSONBody checks that the body of r holds the same JSON value as want.
+func testJSONBody(t *testing.T, r *http.Request, want string) {
+	t.Helper()
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("reading request body: %v", err)
+	}
+	var got, w interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("request body %s: %v", data, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("bad test JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(got, w) {
+		t.Errorf("Request body: %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,381 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemobtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Redacted replaces secrets in recorded interactions.
+const Redacted = "REDACTED"
+
+// redactedHeaders are the headers whose values are secrets.
+var redactedHeaders = []string{"Authorization", "Share-Secret"}
+
+// redactedFields are the JSON fields whose values are secrets, wherever
+// they appear in a request or response body.
+var redactedFields = map[string]bool{
+	"client_secret": true,
+	"password":      true,
+	"newpassword":   true,
+	"access_token":  true,
+	"share-secret":  true,
+	"secret":        true,
+	"thumb_secret":  true,
+}
+
+// Mode is the mode of a Recorder.
+type Mode int
+
+const (
+	// ModeRecord sends requests on and records them with their responses.
+	ModeRecord Mode = iota
+
+	// ModeReplay answers requests with recorded responses, without
+	// sending them.
+	ModeReplay
+)
+
+// A Cassette is a sequence of recorded HTTP interactions, as saved to a
+// cassette file.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// An Interaction is a request and the response it got.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded HTTP request.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// RecordedResponse is a recorded HTTP response.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body is a recorded message body. It is saved as a string if it is valid
+// UTF-8, as most API bodies are, and base64 encoded otherwise.
+type Body []byte
+
+// MarshalJSON encodes b as a string, or as {"base64": "..."} if it is not
+// valid UTF-8.
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON decodes a body encoded by MarshalJSON.
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	var v struct {
+		Base64 []byte `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*b = v.Base64
+	return nil
+}
+
+// A Recorder is an http.RoundTripper recording interactions to, or
+// replaying them from, a cassette file. Install it as the transport of the
+// HTTP client of an easemob.Client:
+//
+//	rec, err := easemobtest.NewRecorder("testdata/users.json", easemobtest.ModeReplay)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	client, err := easemob.NewClient(..., easemob.WithHTTPClient(&http.Client{Transport: rec}))
+//
+// Secrets are redacted from the recorded interactions: the Authorization
+// and Share-Secret headers, and credentials, passwords, tokens and file
+// secrets in JSON bodies. Replayed tokens are therefore Redacted.
+//
+// In replay mode, a request is answered by the first interaction not used
+// yet with the same method, URL and redacted body, multipart bodies aside.
+type Recorder struct {
+	// Transport sends requests in record mode. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Redact, if set, is called on each interaction after the built-in
+	// redaction and before it is kept, to remove secrets of its own.
+	Redact func(*Interaction)
+
+	path string
+	mode Mode
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder returns a Recorder for the cassette file at path. In replay
+// mode, the cassette is loaded from the file; in record mode, it is written
+// to the file by Save.
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode}
+	if mode == ModeReplay {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("easemobtest: reading cassette %v: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given, so the body
+	// is read into a copy.
+	orig := req
+	req = req.Clone(req.Context())
+	reqBody, err := requestBody(orig)
+	if err != nil {
+		return nil, err
+	}
+	if reqBody != nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(reqBody)), nil
+		}
+	}
+	recorded := RecordedRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: redactHeader(req.Header),
+		Body:   redactBody(reqBody),
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(orig, recorded)
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	it := &Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeader(resp.Header),
+			Body:       redactBody(respBody),
+		},
+	}
+	if r.Redact != nil {
+		r.Redact(it)
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, it)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, it := range r.cassette.Interactions {
+		if r.used[i] || it.Request.Method != recorded.Method || it.Request.URL != recorded.URL {
+			continue
+		}
+		// Multipart boundaries are random, so uploads can't be told
+		// apart by their bodies.
+		multipart := strings.HasPrefix(recorded.Header.Get("Content-Type"), "multipart/")
+		if !multipart && !sameBody(it.Request.Body, recorded.Body) {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", it.Response.StatusCode, http.StatusText(it.Response.StatusCode)),
+			StatusCode:    it.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        it.Response.Header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(it.Response.Body)),
+			ContentLength: int64(len(it.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("easemobtest: no recorded interaction left for %v %v", recorded.Method, recorded.URL)
+}
+
+// Interactions returns the interactions recorded or loaded so far.
+func (r *Recorder) Interactions() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Interaction(nil), r.cassette.Interactions...)
+}
+
+// Unused returns the number of loaded interactions not replayed yet, which
+// a test replaying a complete flow expects to be zero.
+func (r *Recorder) Unused() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, used := range r.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+// Save writes the recorded interactions to the cassette file. It is an
+// error to call it in replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return errors.New("easemobtest: Save called on a replaying Recorder")
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(&r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, append(data, '\n'), 0644)
+}
+
+// requestBody returns the content of the body of req, from a fresh copy of
+// it if req has GetBody, and closes the body as a RoundTripper must.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	body := req.Body
+	if req.GetBody != nil {
+		b, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer b.Close()
+		body = b
+	}
+	return ioutil.ReadAll(body)
+}
+
+// readBody reads all of *body and replaces it with a reader of the same
+// content.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range redactedHeaders {
+		if _, ok := h[k]; ok {
+			h.Set(k, Redacted)
+		}
+	}
+	return h
+}
+
+// redactBody returns body with the values of secret fields replaced, if it
+// is JSON, or body itself otherwise.
+func redactBody(body []byte) Body {
+	var v interface{}
+	if len(body) == 0 || json.Unmarshal(body, &v) != nil {
+		return body
+	}
+	if !redactValue(v) {
+		return body
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return data
+}
+
+// redactValue redacts the secret fields within v, a decoded JSON value,
+// and reports whether it found any.
+func redactValue(v interface{}) bool {
+	found := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if redactedFields[k] {
+				v[k] = Redacted
+				found = true
+			} else if redactValue(e) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if redactValue(e) {
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// sameBody reports whether two recorded bodies are equal, comparing JSON
+// bodies by value.
+func sameBody(a, b Body) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
@@ -0,0 +1,131 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemobtest_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weilixu7/easemob"
+	"github.com/weilixu7/easemob/easemobtest"
+)
+
+func TestRecorder_replay(t *testing.T) {
+	const password = "pa55-alice"
+	path := filepath.Join(t.TempDir(), "users.json")
+	srv := easemobtest.NewServer()
+	base := []easemob.Option{
+		easemob.WithApp(srv.Org, srv.App),
+		easemob.WithCredentials(srv.ClientID, srv.ClientSecret),
+		easemob.WithBaseURL(srv.BaseURL()),
+	}
+	flow := func(client *easemob.Client) {
+		t.Helper()
+		if _, _, err := client.Users.Register("alice", password); err != nil {
+			t.Fatalf("Register returned error: %v", err)
+		}
+		if _, _, err := client.Users.Register("alice", password); !errors.Is(err, easemob.ErrDuplicateUser) {
+			t.Fatalf("second Register returned %v, want ErrDuplicateUser", err)
+		}
+		user, _, err := client.Users.Get("alice")
+		if err != nil || user.Username != "alice" {
+			t.Fatalf("Get returned %+v, %v", user, err)
+		}
+	}
+
+	rec, err := easemobtest.NewRecorder(path, easemobtest.ModeRecord)
+	if err != nil {
+		t.Fatalf("NewRecorder returned error: %v", err)
+	}
+	client, _ := easemob.NewClient(append(base, easemob.WithHTTPClient(&http.Client{Transport: rec}))...)
+	flow(client)
+	if err := rec.Save(); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	token := client.Token
+	srv.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, secret := range map[string]string{
+		"client_secret": srv.ClientSecret,
+		"password":      password,
+		"access_token":  token,
+	} {
+		if secret == "" {
+			t.Fatalf("no %v to look for", name)
+		}
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("cassette holds the %v %q", name, secret)
+		}
+	}
+	if !bytes.Contains(data, []byte(easemobtest.Redacted)) {
+		t.Errorf("cassette holds no redacted values")
+	}
+
+	rep, err := easemobtest.NewRecorder(path, easemobtest.ModeReplay)
+	if err != nil {
+		t.Fatalf("NewRecorder returned error: %v", err)
+	}
+	client, _ = easemob.NewClient(append(base, easemob.WithHTTPClient(&http.Client{Transport: rep}))...)
+	flow(client)
+	if n := rep.Unused(); n != 0 {
+		t.Errorf("%d recorded interactions left unused", n)
+	}
+}
+
+// TestRecorder_request checks that recording leaves the request given to
+// RoundTrip as it was.
+func TestRecorder_request(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got = string(b)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	rec, _ := easemobtest.NewRecorder(filepath.Join(t.TempDir(), "c.json"), easemobtest.ModeRecord)
+
+	const body = `{"username":"alice","password":"secret"}`
+	for _, getBody := range []bool{true, false} {
+		req, _ := http.NewRequest("POST", srv.URL+"/org/app/users", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		if !getBody {
+			req.GetBody = nil
+		}
+		reqBody := req.Body
+		resp, err := rec.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip returned error: %v", err)
+		}
+		resp.Body.Close()
+		if got != body {
+			t.Errorf("sent body %q, want %q", got, body)
+		}
+		if req.Body != reqBody || req.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("RoundTrip modified the request")
+		}
+		if getBody {
+			b, _ := req.GetBody()
+			if data, _ := ioutil.ReadAll(b); string(data) != body {
+				t.Errorf("GetBody returns %q after RoundTrip", data)
+			}
+		}
+	}
+	for _, it := range rec.Interactions() {
+		if strings.Contains(string(it.Request.Body), `"secret"`) || it.Request.Header.Get("Authorization") != easemobtest.Redacted {
+			t.Errorf("recorded request %+v holds secrets", it.Request)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemobtest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/weilixu7/easemob"
+)
+
+// chatroom is a chatroom, its members and admins. It shares the fields of
+// a group that make sense for chatrooms.
+type chatroom struct {
+	group
+	admins []string
+}
+
+// sortedChatrooms returns the chatrooms in order of creation.
+func (s *Server) sortedChatrooms() []*chatroom {
+	chatrooms := make([]*chatroom, 0, len(s.chatrooms))
+	for _, c := range s.chatrooms {
+		chatrooms = append(chatrooms, c)
+	}
+	sort.Slice(chatrooms, func(i, j int) bool { return chatrooms[i].created < chatrooms[j].created })
+	return chatrooms
+}
+
+// lookupChatroom returns the chatroom with id, replying with an error if
+// there is none.
+func (s *Server) lookupChatroom(w http.ResponseWriter, r *http.Request, id string) (*chatroom, bool) {
+	c, ok := s.chatrooms[id]
+	if !ok {
+		s.fail(w, r, http.StatusNotFound, "service_resource_not_found", fmt.Sprintf("do not find this group:%v", id))
+	}
+	return c, ok
+}
+
+func (s *Server) listChatrooms(w http.ResponseWriter, r *http.Request, _ []string) {
+	chatrooms := s.sortedChatrooms()
+	start, end := numberedPage(r, len(chatrooms))
+	list := []*easemob.Chatroom{}
+	for _, c := range chatrooms[start:end] {
+		list = append(list, &easemob.Chatroom{
+			ID:                c.id,
+			Name:              c.name,
+			Owner:             c.owner,
+			AffiliationsCount: 1 + len(c.members),
+		})
+	}
+	s.data(w, r, list, map[string]interface{}{"count": len(list)})
+}
+
+func (s *Server) createChatroom(w http.ResponseWriter, r *http.Request, _ []string) {
+	var opt easemob.ChatroomOptions
+	if !s.decode(w, r, &opt) {
+		return
+	}
+	if opt.Name == "" || opt.Owner == "" {
+		s.fail(w, r, http.StatusBadRequest, "illegal_argument", "name and owner are required")
+		return
+	}
+	if _, ok := s.lookupUser(w, r, opt.Owner); !ok {
+		return
+	}
+	for _, m := range opt.Members {
+		if _, ok := s.lookupUser(w, r, m); !ok {
+			return
+		}
+	}
+
+	now := s.now()
+	c := &chatroom{group: group{
+		id:          s.nextID(),
+		name:        opt.Name,
+		description: opt.Description,
+		maxusers:    opt.Maxusers,
+		created:     now,
+		modified:    now,
+		owner:       opt.Owner,
+	}}
+	if c.maxusers == 0 {
+		c.maxusers = defaultMaxusers
+	}
+	for _, m := range opt.Members {
+		if m != c.owner {
+			c.members = with(c.members, m)
+		}
+	}
+	s.chatrooms[c.id] = c
+	s.data(w, r, map[string]string{"id": c.id}, nil)
+}
+
+func (s *Server) getChatrooms(w http.ResponseWriter, r *http.Request, args []string) {
+	var details []*easemob.ChatroomDetail
+	for _, id := range strings.Split(args[0], ",") {
+		c, ok := s.lookupChatroom(w, r, id)
+		if !ok {
+			return
+		}
+		details = append(details, &easemob.ChatroomDetail{
+			ID:                c.id,
+			Name:              c.name,
+			Description:       c.description,
+			MembersOnly:       c.membersOnly,
+			AllowInvites:      c.allowInvites,
+			Maxusers:          c.maxusers,
+			Owner:             c.owner,
+			Created:           c.created,
+			AffiliationsCount: 1 + len(c.members),
+			Affiliations:      c.affiliations(),
+		})
+	}
+	s.data(w, r, details, map[string]interface{}{"count": len(details)})
+}
+
+func (s *Server) updateChatroom(w http.ResponseWriter, r *http.Request, args []string) {
+	c, ok := s.lookupChatroom(w, r, args[0])
+	if !ok {
+		return
+	}
+	var put easemob.PutOptions
+	if !s.decode(w, r, &put) {
+		return
+	}
+
+	result := easemob.UpdateResult{}
+	if put.Name != "" {
+		c.name = put.Name
+		result["name"] = true
+	}
+	if put.Description != "" {
+		c.description = put.Description
+		result["description"] = true
+	}
+	if put.Maxusers > 0 {
+		c.maxusers = put.Maxusers
+		result["maxusers"] = true
+	}
+	c.modified = s.now()
+	s.data(w, r, result, nil)
+}
+
+func (s *Server) deleteChatroom(w http.ResponseWriter, r *http.Request, args []string) {
+	c, ok := s.lookupChatroom(w, r, args[0])
+	if !ok {
+		return
+	}
+	delete(s.chatrooms, c.id)
+	s.data(w, r, map[string]interface{}{"success": true, "id": c.id}, nil)
+}
+
+func (s *Server) chatroomMembers(w http.ResponseWriter, r *http.Request, args []string) {
+	c, ok := s.lookupChatroom(w, r, args[0])
+	if !ok {
+		return
+	}
+	list := c.affiliations()
+	start, end := numberedPage(r, len(list))
+	s.data(w, r, list[start:end], map[string]interface{}{"count": end - start})
+}
+
+func (s *Server) addChatroomMember(w http.ResponseWriter, r *http.Request, args []string) {
+	c, ok := s.lookupChatroom(w, r, args[0])
+	if !ok {
+		return
+	}
+	u, ok := s.lookupUser(w, r, args[1])
+	if !ok {
+		return
+	}
+	if 1+len(c.members) >= c.maxusers && !c.has(u.Username) {
+		s.fail(w, r, http.StatusForbidden, "forbidden_op", fmt.Sprintf("chatroom %v is full", c.id))
+		return
+	}
+	if !c.has(u.Username) {
+		c.members = append(c.members, u.Username)
+	}
+	s.data(w, r, map[string]interface{}{"result": true, "id": c.id, "action": "add_member", "user": u.Username}, nil)
+}
+
+func (s *Server) addChatroomMembers(w http.ResponseWriter, r *http.Request, args []string) {
+	c, ok := s.lookupChatroom(w, r, args[0])
+	if !ok {
+		return
+	}
+	var put easemob.PutOptions
+	if !s.decode(w, r, &put) {
+		return
+	}
+	for _, name := range put.Usernames {
+		if _, ok := s.lookupUser(w, r, name); !ok {
+			return
+		}
+	}
+
+	added := []string{}
+	for _, name := range put.Usernames {
+		if !c.has(name) && 1+len(c.members) < c.maxusers {
+			c.members = append(c.members, name)
+			added = append(added, name)
+		}
+	}
+	s.data(w, r, map[string]interface{}{"newmembers": added, "id": c.id, "action": "add_member"}, nil)
+}
+
+// deleteChatroomMembers removes one member, answering with an object, or
+// several comma-separated ones, answering with a list.
+func (s *Server) deleteChatroomMembers(w http.ResponseWriter, r *http.Request, args []string) {
+	c, ok := s.lookupChatroom(w, r, args[0])
+	if !ok {
+		return
+	}
+
+	var results []map[string]interface{}
+	for _, name := range strings.Split(args[1], ",") {
+		res := map[string]interface{}{"result": false, "action": "remove_member", "user": name, "id": c.id}
+		if name != c.owner && c.has(name) {
+			c.members = without(c.members, name)
+			c.admins = without(c.admins, name)
+			res["result"] = true
+		} else {
+			res["reason"] = "user " + name + " doesn't exist in chatroom " + c.id
+		}
+		results = append(results, res)
+	}
+	if !strings.Contains(args[1], ",") {
+		s.data(w, r, results[0], nil)
+		return
+	}
+	s.data(w, r, results, nil)
+}
+
+func (s *Server) joinedChatrooms(w http.ResponseWriter, r *http.Request, args []string) {
+	u, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	list := []*easemob.Chatroom{}
+	for _, c := range s.sortedChatrooms() {
+		if c.has(u.Username) {
+			list = append(list, &easemob.Chatroom{ID: c.id, Name: c.name})
+		}
+	}
+	s.data(w, r, list, map[string]interface{}{"count": len(list)})
+}
+
+func (s *Server) listSuperAdmins(w http.ResponseWriter, r *http.Request, _ []string) {
+	s.data(w, r, nonNil(s.superAdmins), map[string]interface{}{"count": len(s.superAdmins)})
+}
+
+func (s *Server) addSuperAdmin(w http.ResponseWriter, r *http.Request, _ []string) {
+	var put struct {
+		SuperAdmin string `json:"superadmin"`
+	}
+	if !s.decode(w, r, &put) {
+		return
+	}
+	if _, ok := s.lookupUser(w, r, put.SuperAdmin); !ok {
+		return
+	}
+	s.superAdmins = with(s.superAdmins, put.SuperAdmin)
+	s.data(w, r, map[string]string{"result": "success", "resource": ""}, nil)
+}
+
+func (s *Server) deleteSuperAdmin(w http.ResponseWriter, r *http.Request, args []string) {
+	name := args[0]
+	if !contains(s.superAdmins, name) {
+		s.fail(w, r, http.StatusBadRequest, "illegal_argument", name+" is not a super admin")
+		return
+	}
+	s.superAdmins = without(s.superAdmins, name)
+	s.data(w, r, map[string]string{"newSuperAdmin": name, "resource": ""}, nil)
+}
+
+func (s *Server) listAdmins(w http.ResponseWriter, r *http.Request, args []string) {
+	c, ok := s.lookupChatroom(w, r, args[0])
+	if !ok {
+		return
+	}
+	s.data(w, r, nonNil(c.admins), map[string]interface{}{"count": len(c.admins)})
+}
+
+func (s *Server) addAdmin(w http.ResponseWriter, r *http.Request, args []string) {
+	c, ok := s.lookupChatroom(w, r, args[0])
+	if !ok {
+		return
+	}
+	var put struct {
+		NewAdmin string `json:"newadmin"`
+	}
+	if !s.decode(w, r, &put) {
+		return
+	}
+	if !c.has(put.NewAdmin) {
+		s.fail(w, r, http.StatusForbidden, "forbidden_op",
+			fmt.Sprintf("user %v is not a member of chatroom %v", put.NewAdmin, c.id))
+		return
+	}
+	c.admins = with(c.admins, put.NewAdmin)
+	s.data(w, r, map[string]string{"result": "success", "newadmin": put.NewAdmin}, nil)
+}
+
+func (s *Server) deleteAdmin(w http.ResponseWriter, r *http.Request, args []string) {
+	c, ok := s.lookupChatroom(w, r, args[0])
+	if !ok {
+		return
+	}
+	name := args[1]
+	if !contains(c.admins, name) {
+		s.fail(w, r, http.StatusForbidden, "forbidden_op",
+			fmt.Sprintf("user %v is not an admin of chatroom %v", name, c.id))
+		return
+	}
+	c.admins = without(c.admins, name)
+	s.data(w, r, map[string]string{"result": "success", "oldadmin": name}, nil)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
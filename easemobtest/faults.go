@@ -0,0 +1,240 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemobtest
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Fault is a failure the server injects into the requests it selects,
+// in place of or on top of their normal handling.
+//
+// Requests are selected by Method and Path, then counted: the first After
+// of them are let through, and of the rest every Every-th one is hit, up to
+// Times. For instance, to throttle the third call to the users endpoint:
+//
+//	srv.Inject(easemobtest.Fault{
+//		Method:     "GET",
+//		Path:       "users/*",
+//		After:      2,
+//		Times:      1,
+//		Status:     http.StatusTooManyRequests,
+//		RetryAfter: time.Second,
+//	})
+type Fault struct {
+	// Method selects requests by HTTP method. Empty selects any.
+	Method string
+
+	// Path selects requests by path relative to the app, such as
+	// "users/*/contacts/users", where "*" matches any one segment. Empty
+	// selects any.
+	Path string
+
+	After int // number of selected requests let through first
+	Every int // hit every Every-th request only; 0 hits all of them
+	Times int // number of requests hit at most; 0 means no limit
+
+	// Latency delays the response, or the failure if there is one.
+	Latency time.Duration
+
+	// ExpireToken revokes every token issued so far before the request is
+	// handled, so that it fails with 401 as if its token had expired.
+	ExpireToken bool
+
+	// Status, if set, answers the request with an API error of that status
+	// instead of handling it, such as 401, 408, 429 or 503.
+	Status int
+
+	// RetryAfter sets the Retry-After header of a Status failure.
+	RetryAfter time.Duration
+
+	// Malformed answers the request with 200 and a truncated JSON body.
+	Malformed bool
+
+	// Reset closes the connection without answering.
+	Reset bool
+}
+
+// faultRule is an injected fault and its counters.
+type faultRule struct {
+	Fault
+	pattern  route
+	selected int
+	hit      int
+}
+
+// call is a request received by the server.
+type call struct {
+	method string
+	path   []string
+}
+
+// Inject adds a fault to the server. Faults are tried in the order they
+// were added; a request is hit by the first one that applies to it. The
+// returned function removes the fault.
+func (s *Server) Inject(f Fault) (remove func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule := &faultRule{Fault: f, pattern: route{method: f.Method}}
+	if f.Path != "" {
+		rule.pattern.pattern = strings.Split(strings.Trim(f.Path, "/"), "/")
+	}
+	s.faults = append(s.faults, rule)
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, r := range s.faults {
+			if r == rule {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// ClearFaults removes every fault injected.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Calls returns the number of requests received so far that match method
+// and path, selected as by the fields of a Fault. Requests hit by a fault
+// are counted, so that retries can be checked.
+func (s *Server) Calls(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := route{method: method}
+	if path != "" {
+		p.pattern = strings.Split(strings.Trim(path, "/"), "/")
+	}
+	n := 0
+	for _, c := range s.calls {
+		if p.selects(c.method, c.path) {
+			n++
+		}
+	}
+	return n
+}
+
+// selects reports whether a request for method and path is selected by p,
+// whose empty method and pattern select any.
+func (p *route) selects(method string, path []string) bool {
+	pattern := *p
+	if pattern.method == "" {
+		pattern.method = method
+	}
+	if pattern.pattern == nil {
+		return method == pattern.method
+	}
+	_, ok := pattern.match(method, path)
+	return ok
+}
+
+// hits counts a request selected by r and reports whether it is hit.
+func (r *faultRule) hits() bool {
+	r.selected++
+	n := r.selected - r.After
+	if n <= 0 || (r.Times > 0 && r.hit >= r.Times) {
+		return false
+	}
+	if r.Every > 1 && n%r.Every != 0 {
+		return false
+	}
+	r.hit++
+	return true
+}
+
+// nextFault records a request for method and path and returns the fault
+// hitting it, if any.
+func (s *Server) nextFault(method string, path []string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, call{method, path})
+	for _, r := range s.faults {
+		if r.pattern.selects(method, path) && r.hits() {
+			f := r.Fault
+			return &f
+		}
+	}
+	return nil
+}
+
+// inject applies f to r and reports whether it answered r, which is then
+// not to be handled.
+func (s *Server) inject(w http.ResponseWriter, r *http.Request, f *Fault) bool {
+	if f.Latency > 0 {
+		t := time.NewTimer(f.Latency)
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			return true
+		}
+	}
+
+	// Tokens expire whatever else the fault does, so that a request
+	// failing with another status still finds its token gone on retry.
+	if f.ExpireToken {
+		s.ExpireTokens()
+	}
+
+	switch {
+	case f.Reset:
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				if tcp, ok := conn.(*net.TCPConn); ok {
+					tcp.SetLinger(0)
+				}
+				conn.Close()
+				return true
+			}
+		}
+		panic(http.ErrAbortHandler)
+
+	case f.Malformed:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"action":"` + strings.ToLower(r.Method) + `","entities":[{"uuid":`))
+		return true
+
+	case f.Status != 0:
+		if f.RetryAfter > 0 {
+			secs := int((f.RetryAfter + time.Second - 1) / time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		code, desc := faultError(f.Status)
+		s.fail(w, r, f.Status, code, desc)
+		return true
+	}
+	return false
+}
+
+// faultError returns the error code and description the API reports with
+// status.
+func faultError(status int) (string, string) {
+	switch status {
+	case http.StatusUnauthorized:
+		return "unauthorized", "Unable to authenticate due to expired access token"
+	case http.StatusRequestTimeout:
+		return "request_timeout", "Request timed out"
+	case http.StatusTooManyRequests:
+		return "reach_limit", "This request has reached api limit"
+	case http.StatusServiceUnavailable:
+		return "service_unavailable", "Service is unavailable, please try again later"
+	}
+	return "internal_server_error", http.StatusText(status)
+}
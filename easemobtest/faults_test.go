@@ -0,0 +1,202 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemobtest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weilixu7/easemob"
+	"github.com/weilixu7/easemob/easemobtest"
+)
+
+// fastRetries retries like the default policy, without the wait.
+func fastRetries() easemob.Option {
+	p := easemob.DefaultRetryPolicy
+	p.MinBackoff, p.MaxBackoff, p.Jitter = time.Millisecond, time.Millisecond, 0
+	return easemob.WithRetryPolicy(&p)
+}
+
+func noRetries() easemob.Option {
+	return easemob.WithRetryPolicy(&easemob.RetryPolicy{})
+}
+
+func TestClient_token_concurrent(t *testing.T) {
+	client, srv := newTestClient(t)
+	srv.AddUser("alice", "secret")
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := client.Users.Get("alice")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Get returned error: %v", err)
+		}
+	}
+	if calls := srv.Calls("POST", "token"); calls != 1 {
+		t.Errorf("%d token requests for %d concurrent calls, want 1", calls, n)
+	}
+}
+
+func TestClient_token_expired(t *testing.T) {
+	client, srv := newTestClient(t)
+	srv.AddUser("alice", "secret")
+	if _, _, err := client.Users.Get("alice"); err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+
+	srv.Inject(easemobtest.Fault{Method: "GET", Path: "users/*", Times: 1, ExpireToken: true})
+	user, _, err := client.Users.Get("alice")
+	if err != nil {
+		t.Fatalf("Get with an expired token returned error: %v", err)
+	}
+	if user.Username != "alice" {
+		t.Errorf("Get returned %+v", user)
+	}
+	if calls := srv.Calls("GET", "users/alice"); calls != 3 {
+		t.Errorf("%d requests for alice, want 3: the first Get, the rejected one and its retry", calls)
+	}
+	if calls := srv.Calls("POST", "token"); calls != 2 {
+		t.Errorf("%d token requests, want 2", calls)
+	}
+}
+
+// TestFault_expireTokenAndStatus checks that a fault both expiring tokens
+// and failing the request leaves the token expired for the retry.
+func TestFault_expireTokenAndStatus(t *testing.T) {
+	client, srv := newTestClient(t, fastRetries())
+	srv.AddUser("alice", "secret")
+
+	srv.Inject(easemobtest.Fault{
+		Method:      "GET",
+		Path:        "users/*",
+		Times:       1,
+		ExpireToken: true,
+		Status:      http.StatusServiceUnavailable,
+	})
+	if _, _, err := client.Users.Get("alice"); err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	// The 503, the retry rejected with 401, and the retry with a new token.
+	if calls := srv.Calls("GET", "users/alice"); calls != 3 {
+		t.Errorf("%d requests for alice, want 3", calls)
+	}
+	if calls := srv.Calls("POST", "token"); calls != 2 {
+		t.Errorf("%d token requests, want 2", calls)
+	}
+}
+
+func TestFault_selection(t *testing.T) {
+	client, srv := newTestClient(t, noRetries())
+	srv.AddUser("alice", "secret")
+	srv.AddUser("bob", "secret")
+
+	remove := srv.Inject(easemobtest.Fault{
+		Method: "GET",
+		Path:   "users/alice",
+		After:  2,
+		Every:  2,
+		Times:  2,
+		Status: http.StatusInternalServerError,
+	})
+
+	var failed []int
+	for i := 1; i <= 8; i++ {
+		if _, _, err := client.Users.Get("alice"); err != nil {
+			failed = append(failed, i)
+		}
+		if _, _, err := client.Users.Get("bob"); err != nil {
+			t.Errorf("Get of an unselected path returned error: %v", err)
+		}
+	}
+	if len(failed) != 2 || failed[0] != 4 || failed[1] != 6 {
+		t.Errorf("failed calls %v, want [4 6]", failed)
+	}
+	if calls := srv.Calls("", "users/*"); calls != 16 {
+		t.Errorf("Calls counted %d user requests, want 16", calls)
+	}
+
+	remove()
+	srv.Inject(easemobtest.Fault{Status: http.StatusInternalServerError})
+	if _, _, err := client.Users.Get("alice"); err == nil {
+		t.Errorf("Get returned no error under a fault selecting every request")
+	}
+	srv.ClearFaults()
+	if _, _, err := client.Users.Get("alice"); err != nil {
+		t.Errorf("Get returned error after ClearFaults: %v", err)
+	}
+}
+
+func TestFault_status(t *testing.T) {
+	client, srv := newTestClient(t, noRetries())
+	srv.AddUser("alice", "secret")
+	srv.Inject(easemobtest.Fault{Path: "users/*", Status: http.StatusTooManyRequests, RetryAfter: 1500 * time.Millisecond})
+
+	_, resp, err := client.Users.Get("alice")
+	if !errors.Is(err, easemob.ErrRateLimited) {
+		t.Fatalf("Get returned %v, want ErrRateLimited", err)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want %q", got, "2")
+	}
+	var apiErr *easemob.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "reach_limit" {
+		t.Errorf("Get error = %#v, want a reach_limit APIError", err)
+	}
+}
+
+func TestFault_malformed(t *testing.T) {
+	client, srv := newTestClient(t, noRetries())
+	srv.AddUser("alice", "secret")
+	srv.Inject(easemobtest.Fault{Path: "users/*", Malformed: true})
+
+	if _, _, err := client.Users.Get("alice"); err == nil {
+		t.Errorf("Get of a malformed response returned no error")
+	}
+}
+
+func TestFault_reset(t *testing.T) {
+	client, srv := newTestClient(t, noRetries())
+	srv.AddUser("alice", "secret")
+	srv.Inject(easemobtest.Fault{Path: "users/*", Reset: true})
+
+	_, _, err := client.Users.Get("alice")
+	var apiErr *easemob.APIError
+	if err == nil || errors.As(err, &apiErr) {
+		t.Errorf("Get of a reset connection returned %v, want a transport error", err)
+	}
+}
+
+func TestFault_latency(t *testing.T) {
+	client, srv := newTestClient(t, noRetries())
+	srv.AddUser("alice", "secret")
+	srv.Inject(easemobtest.Fault{Path: "users/*", Latency: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := client.Users.GetContext(ctx, "alice")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get returned %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("Get took %v", d)
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemobtest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/weilixu7/easemob"
+)
+
+// defaultMaxusers is the member cap of groups and chatrooms created without
+// one.
+const defaultMaxusers = 200
+
+// group is a group and its members, the owner excluded.
+type group struct {
+	id           string
+	name         string
+	description  string
+	public       bool
+	membersOnly  bool
+	allowInvites bool
+	maxusers     int
+	created      int64
+	modified     int64
+	owner        string
+	members      []string
+}
+
+func (g *group) affiliations() easemob.MemberList {
+	list := easemob.MemberList{{Username: g.owner, Role: "owner"}}
+	for _, m := range g.members {
+		list = append(list, &easemob.Member{Username: m, Role: "member"})
+	}
+	return list
+}
+
+func (g *group) has(username string) bool {
+	return username == g.owner || contains(g.members, username)
+}
+
+// appKey returns the qualified form of a username found in group listings.
+func (s *Server) appKey(username string) string {
+	return s.Org + "#" + s.App + "_" + username
+}
+
+// sortedGroups returns the groups in order of creation.
+func (s *Server) sortedGroups() []*group {
+	groups := make([]*group, 0, len(s.groups))
+	for _, g := range s.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].created < groups[j].created })
+	return groups
+}
+
+func (s *Server) listing(g *group) *easemob.Group {
+	return &easemob.Group{
+		Groupid:      g.id,
+		Groupname:    g.name,
+		Owner:        s.appKey(g.owner),
+		Affiliations: 1 + len(g.members),
+		Type:         "group",
+		LastModified: fmt.Sprint(g.modified),
+	}
+}
+
+// lookupGroup returns the group with id, replying with an error if there
+// is none.
+func (s *Server) lookupGroup(w http.ResponseWriter, r *http.Request, id string) (*group, bool) {
+	g, ok := s.groups[id]
+	if !ok {
+		s.fail(w, r, http.StatusNotFound, "resource_not_found", fmt.Sprintf("grpID %v does not exist!", id))
+	}
+	return g, ok
+}
+
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request, _ []string) {
+	limit, offset, ok := s.cursorPage(w, r, "limit")
+	if !ok {
+		return
+	}
+	groups := s.sortedGroups()
+	if r.URL.Query().Get("limit") == "" {
+		limit = len(groups)
+	}
+
+	end := offset + limit
+	if end > len(groups) {
+		end = len(groups)
+	}
+	list := []*easemob.Group{}
+	if offset < end {
+		for _, g := range groups[offset:end] {
+			list = append(list, s.listing(g))
+		}
+	}
+	extra := map[string]interface{}{"count": len(list)}
+	if end < len(groups) {
+		extra["cursor"] = encodeCursor(end)
+	}
+	s.data(w, r, list, extra)
+}
+
+func (s *Server) createGroup(w http.ResponseWriter, r *http.Request, _ []string) {
+	var opt easemob.GroupOptions
+	if !s.decode(w, r, &opt) {
+		return
+	}
+	if opt.Groupname == "" || opt.Owner == "" {
+		s.fail(w, r, http.StatusBadRequest, "illegal_argument", "groupname and owner are required")
+		return
+	}
+	if _, ok := s.lookupUser(w, r, opt.Owner); !ok {
+		return
+	}
+	for _, m := range opt.Members {
+		if _, ok := s.lookupUser(w, r, m); !ok {
+			return
+		}
+	}
+
+	now := s.now()
+	g := &group{
+		id:           s.nextID(),
+		name:         opt.Groupname,
+		description:  opt.Description,
+		public:       opt.Public,
+		membersOnly:  opt.MembersOnly,
+		allowInvites: opt.AllowInvites,
+		maxusers:     opt.Maxusers,
+		created:      now,
+		modified:     now,
+		owner:        opt.Owner,
+	}
+	if g.maxusers == 0 {
+		g.maxusers = defaultMaxusers
+	}
+	for _, m := range opt.Members {
+		if m != g.owner {
+			g.members = with(g.members, m)
+		}
+	}
+	s.groups[g.id] = g
+	s.data(w, r, map[string]string{"groupid": g.id}, nil)
+}
+
+func (s *Server) getGroups(w http.ResponseWriter, r *http.Request, args []string) {
+	var details []*easemob.GroupDetail
+	for _, id := range strings.Split(args[0], ",") {
+		g, ok := s.lookupGroup(w, r, id)
+		if !ok {
+			return
+		}
+		details = append(details, &easemob.GroupDetail{
+			ID:                g.id,
+			Name:              g.name,
+			Description:       g.description,
+			Public:            g.public,
+			MembersOnly:       g.membersOnly,
+			AllowInvites:      g.allowInvites,
+			Maxusers:          g.maxusers,
+			Created:           g.created,
+			AffiliationsCount: 1 + len(g.members),
+			Affiliations:      g.affiliations(),
+		})
+	}
+	s.data(w, r, details, map[string]interface{}{"count": len(details)})
+}
+
+func (s *Server) updateGroup(w http.ResponseWriter, r *http.Request, args []string) {
+	g, ok := s.lookupGroup(w, r, args[0])
+	if !ok {
+		return
+	}
+	var put easemob.PutOptions
+	if !s.decode(w, r, &put) {
+		return
+	}
+
+	result := easemob.UpdateResult{}
+	if put.Groupname != "" {
+		g.name = put.Groupname
+		result["groupname"] = true
+	}
+	if put.Description != "" {
+		g.description = put.Description
+		result["description"] = true
+	}
+	if put.Maxusers > 0 {
+		g.maxusers = put.Maxusers
+		result["maxusers"] = true
+	}
+	g.modified = s.now()
+	s.data(w, r, result, nil)
+}
+
+func (s *Server) deleteGroup(w http.ResponseWriter, r *http.Request, args []string) {
+	g, ok := s.lookupGroup(w, r, args[0])
+	if !ok {
+		return
+	}
+	delete(s.groups, g.id)
+	s.data(w, r, map[string]interface{}{"success": true, "groupid": g.id}, nil)
+}
+
+func (s *Server) groupMembers(w http.ResponseWriter, r *http.Request, args []string) {
+	g, ok := s.lookupGroup(w, r, args[0])
+	if !ok {
+		return
+	}
+	list := g.affiliations()
+	start, end := numberedPage(r, len(list))
+	s.data(w, r, list[start:end], map[string]interface{}{"count": end - start})
+}
+
+func (s *Server) addGroupMember(w http.ResponseWriter, r *http.Request, args []string) {
+	g, ok := s.lookupGroup(w, r, args[0])
+	if !ok {
+		return
+	}
+	u, ok := s.lookupUser(w, r, args[1])
+	if !ok {
+		return
+	}
+	if g.has(u.Username) {
+		s.fail(w, r, http.StatusForbidden, "forbidden_op",
+			fmt.Sprintf("user %v is already a member of group %v", u.Username, g.id))
+		return
+	}
+	if 1+len(g.members) >= g.maxusers {
+		s.fail(w, r, http.StatusForbidden, "forbidden_op", fmt.Sprintf("group %v is full", g.id))
+		return
+	}
+	g.members = append(g.members, u.Username)
+	s.data(w, r, map[string]interface{}{"result": true, "groupid": g.id, "action": "add_member", "user": u.Username}, nil)
+}
+
+func (s *Server) addGroupMembers(w http.ResponseWriter, r *http.Request, args []string) {
+	g, ok := s.lookupGroup(w, r, args[0])
+	if !ok {
+		return
+	}
+	var put easemob.PutOptions
+	if !s.decode(w, r, &put) {
+		return
+	}
+	for _, name := range put.Usernames {
+		if _, ok := s.lookupUser(w, r, name); !ok {
+			return
+		}
+	}
+
+	added := []string{}
+	for _, name := range put.Usernames {
+		if !g.has(name) && 1+len(g.members) < g.maxusers {
+			g.members = append(g.members, name)
+			added = append(added, name)
+		}
+	}
+	s.data(w, r, map[string]interface{}{"newmembers": added, "groupid": g.id, "action": "add_member"}, nil)
+}
+
+func (s *Server) deleteGroupMember(w http.ResponseWriter, r *http.Request, args []string) {
+	g, ok := s.lookupGroup(w, r, args[0])
+	if !ok {
+		return
+	}
+	name := args[1]
+	if name == g.owner {
+		s.fail(w, r, http.StatusForbidden, "forbidden_op", "the owner can't be removed from group "+g.id)
+		return
+	}
+	if !g.has(name) {
+		s.fail(w, r, http.StatusForbidden, "forbidden_op",
+			fmt.Sprintf("user %v is not a member of group %v", name, g.id))
+		return
+	}
+	g.members = without(g.members, name)
+	s.data(w, r, map[string]interface{}{"result": true, "groupid": g.id, "action": "remove_member", "user": name}, nil)
+}
+
+func (s *Server) joinedGroups(w http.ResponseWriter, r *http.Request, args []string) {
+	u, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	list := []*easemob.Group{}
+	for _, g := range s.sortedGroups() {
+		if g.has(u.Username) {
+			list = append(list, &easemob.Group{Groupid: g.id, Groupname: g.name})
+		}
+	}
+	s.data(w, r, list, map[string]interface{}{"count": len(list)})
+}
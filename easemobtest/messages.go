@@ -0,0 +1,90 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemobtest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/weilixu7/easemob"
+)
+
+// Message is a message sent through the server.
+type Message struct {
+	From       string
+	TargetType string // "users", "chatgroups" or "chatrooms"
+	Target     []string
+	Body       easemob.MessageBody
+	Ext        map[string]interface{}
+	Timestamp  int64 // milliseconds since the epoch
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (s *Server) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Message(nil), s.messages...)
+}
+
+func (s *Server) sendMessage(w http.ResponseWriter, r *http.Request, _ []string) {
+	var put struct {
+		TargetType string                 `json:"target_type"`
+		Target     []string               `json:"target"`
+		Msg        json.RawMessage        `json:"msg"`
+		From       string                 `json:"from"`
+		Ext        map[string]interface{} `json:"ext"`
+	}
+	if !s.decode(w, r, &put) {
+		return
+	}
+	if len(put.Target) == 0 || len(put.Msg) == 0 {
+		s.fail(w, r, http.StatusBadRequest, "illegal_argument", "target and msg are required")
+		return
+	}
+	body, err := easemob.DecodeMessageBody(put.Msg)
+	if err != nil {
+		s.fail(w, r, http.StatusBadRequest, "json_parse", err.Error())
+		return
+	}
+
+	var exists func(id string) bool
+	switch put.TargetType {
+	case "users":
+		exists = func(id string) bool { _, ok := s.users[id]; return ok }
+	case "chatgroups":
+		exists = func(id string) bool { _, ok := s.groups[id]; return ok }
+	case "chatrooms":
+		exists = func(id string) bool { _, ok := s.chatrooms[id]; return ok }
+	default:
+		s.fail(w, r, http.StatusBadRequest, "illegal_argument", "target_type must be users, chatgroups or chatrooms")
+		return
+	}
+
+	result := easemob.SendResult{}
+	var delivered []string
+	for _, id := range put.Target {
+		if !exists(id) {
+			result[id] = "target does not exist"
+			continue
+		}
+		result[id] = "success"
+		delivered = append(delivered, id)
+		if u, ok := s.users[id]; ok && put.TargetType == "users" && !u.online {
+			u.offline++
+		}
+	}
+	if len(delivered) > 0 {
+		s.messages = append(s.messages, &Message{
+			From:       put.From,
+			TargetType: put.TargetType,
+			Target:     delivered,
+			Body:       body,
+			Ext:        put.Ext,
+			Timestamp:  s.now(),
+		})
+	}
+	s.data(w, r, result, nil)
+}
@@ -0,0 +1,380 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package easemobtest provides an in-memory fake of the Easemob REST API for
+// testing code built on package easemob end to end, without reaching the
+// real service.
+//
+//	srv := easemobtest.NewServer()
+//	defer srv.Close()
+//	client, err := srv.NewClient()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	client.Users.Register("alice", "secret")
+//
+// The server implements the token, users, contacts, blocks, groups,
+// chatrooms and messages endpoints, answering with the JSON envelopes and
+// error codes of the real API.
+package easemobtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weilixu7/easemob"
+)
+
+// Credentials the server is set up with by NewServer.
+const (
+	DefaultOrg          = "easemob-test"
+	DefaultApp          = "app"
+	DefaultClientID     = "YXA6test-client-id"
+	DefaultClientSecret = "YXA6test-client-secret"
+)
+
+// tokenTTL is the lifetime of the tokens the server issues, in seconds.
+const tokenTTL = 86400
+
+// A Server is a fake Easemob API listening on a system-chosen port on the
+// local loopback interface. Its state starts empty and lives in memory; it
+// is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	Org          string
+	App          string
+	ClientID     string
+	ClientSecret string
+
+	// OpenRegistration lets users be registered without a token, as apps
+	// in open registration mode allow.
+	OpenRegistration bool
+
+	mu          sync.Mutex
+	routes      []route
+	appUUID     string
+	tokens      map[string]time.Time
+	users       map[string]*user
+	groups      map[string]*group
+	chatrooms   map[string]*chatroom
+	superAdmins []string
+	messages    []*Message
+	faults      []*faultRule
+	calls       []call
+	lastTime    int64
+	lastID      int64
+}
+
+// NewServer starts and returns a new Server with the default credentials.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		Org:          DefaultOrg,
+		App:          DefaultApp,
+		ClientID:     DefaultClientID,
+		ClientSecret: DefaultClientSecret,
+		appUUID:      newUUID(),
+		tokens:       make(map[string]time.Time),
+		users:        make(map[string]*user),
+		groups:       make(map[string]*group),
+		chatrooms:    make(map[string]*chatroom),
+	}
+	s.routes = s.buildRoutes()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveAPI))
+	return s
+}
+
+// BaseURL returns the URL to set as the BaseURL of a client.
+func (s *Server) BaseURL() string {
+	return s.URL + "/"
+}
+
+// NewClient returns a client of the server, authenticated with its
+// credentials. opts are applied after the ones pointing the client at the
+// server.
+func (s *Server) NewClient(opts ...easemob.Option) (*easemob.Client, error) {
+	base := []easemob.Option{
+		easemob.WithApp(s.Org, s.App),
+		easemob.WithCredentials(s.ClientID, s.ClientSecret),
+		easemob.WithBaseURL(s.BaseURL()),
+	}
+	return easemob.NewClient(append(base, opts...)...)
+}
+
+// ExpireTokens revokes every token issued so far, so that clients have to
+// fetch a new one.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]time.Time)
+}
+
+// A route maps requests for a method and path, relative to the app, to
+// their handler. A "*" segment in pattern matches any segment, which is
+// passed to the handler.
+type route struct {
+	method  string
+	pattern []string
+	handle  func(w http.ResponseWriter, r *http.Request, args []string)
+}
+
+func (s *Server) buildRoutes() []route {
+	var routes []route
+	add := func(method, pattern string, handle func(http.ResponseWriter, *http.Request, []string)) {
+		routes = append(routes, route{method, strings.Split(pattern, "/"), handle})
+	}
+
+	add("POST", "users", s.registerUsers)
+	add("GET", "users", s.listUsers)
+	add("DELETE", "users", s.deleteUsers)
+	add("GET", "users/*", s.getUser)
+	add("PUT", "users/*", s.updateUser)
+	add("DELETE", "users/*", s.deleteUser)
+	add("PUT", "users/*/password", s.resetPassword)
+	add("GET", "users/*/status", s.userStatus)
+	add("GET", "users/*/disconnect", s.disconnectUser)
+	add("GET", "users/*/offline_msg_count", s.offlineMsgCount)
+	add("GET", "users/*/contacts/users", s.listContacts)
+	add("POST", "users/*/contacts/users/*", s.addContact)
+	add("DELETE", "users/*/contacts/users/*", s.deleteContact)
+	add("GET", "users/*/blocks/users", s.listBlocks)
+	add("POST", "users/*/blocks/users", s.addBlocks)
+	add("DELETE", "users/*/blocks/users/*", s.deleteBlock)
+	add("GET", "users/*/joined_chatgroups", s.joinedGroups)
+	add("GET", "users/*/joined_chatrooms", s.joinedChatrooms)
+
+	add("GET", "chatgroups", s.listGroups)
+	add("POST", "chatgroups", s.createGroup)
+	add("GET", "chatgroups/*", s.getGroups)
+	add("PUT", "chatgroups/*", s.updateGroup)
+	add("DELETE", "chatgroups/*", s.deleteGroup)
+	add("GET", "chatgroups/*/users", s.groupMembers)
+	add("POST", "chatgroups/*/users", s.addGroupMembers)
+	add("POST", "chatgroups/*/users/*", s.addGroupMember)
+	add("DELETE", "chatgroups/*/users/*", s.deleteGroupMember)
+
+	add("GET", "chatrooms/super_admin", s.listSuperAdmins)
+	add("POST", "chatrooms/super_admin", s.addSuperAdmin)
+	add("DELETE", "chatrooms/super_admin/*", s.deleteSuperAdmin)
+	add("GET", "chatrooms", s.listChatrooms)
+	add("POST", "chatrooms", s.createChatroom)
+	add("GET", "chatrooms/*", s.getChatrooms)
+	add("PUT", "chatrooms/*", s.updateChatroom)
+	add("DELETE", "chatrooms/*", s.deleteChatroom)
+	add("GET", "chatrooms/*/users", s.chatroomMembers)
+	add("POST", "chatrooms/*/users", s.addChatroomMembers)
+	add("POST", "chatrooms/*/users/*", s.addChatroomMember)
+	add("DELETE", "chatrooms/*/users/*", s.deleteChatroomMembers)
+	add("GET", "chatrooms/*/admin", s.listAdmins)
+	add("POST", "chatrooms/*/admin", s.addAdmin)
+	add("DELETE", "chatrooms/*/admin/*", s.deleteAdmin)
+
+	add("POST", "messages", s.sendMessage)
+	return routes
+}
+
+// match returns the args of path for r, or false if r doesn't apply.
+func (r *route) match(method string, path []string) ([]string, bool) {
+	if method != r.method || len(path) != len(r.pattern) {
+		return nil, false
+	}
+	var args []string
+	for i, seg := range r.pattern {
+		switch seg {
+		case "*":
+			args = append(args, path[i])
+		case path[i]:
+		default:
+			return nil, false
+		}
+	}
+	return args, true
+}
+
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	segs := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segs) >= 3 && segs[0] == s.Org && segs[1] == s.App {
+		if f := s.nextFault(r.Method, segs[2:]); f != nil && s.inject(w, r, f) {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(segs) < 3 || segs[0] != s.Org || segs[1] != s.App {
+		s.fail(w, r, http.StatusNotFound, "organization_application_not_found",
+			"Could not find application from URI: "+r.URL.Path)
+		return
+	}
+	path := segs[2:]
+
+	if r.Method == "POST" && len(path) == 1 && path[0] == "token" {
+		s.issueToken(w, r)
+		return
+	}
+	if !(s.OpenRegistration && r.Method == "POST" && len(path) == 1 && path[0] == "users") && !s.authorized(r) {
+		s.fail(w, r, http.StatusUnauthorized, "unauthorized", "Unable to authenticate due to corrupt access token")
+		return
+	}
+
+	found := false
+	for _, rt := range s.routes {
+		if args, ok := rt.match(r.Method, path); ok {
+			rt.handle(w, r, args)
+			return
+		}
+		if _, ok := rt.match(rt.method, path); ok {
+			found = true
+		}
+	}
+	if found {
+		s.fail(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "Method "+r.Method+" not allowed")
+		return
+	}
+	s.fail(w, r, http.StatusNotFound, "service_resource_not_found", "Service resource not found")
+}
+
+// issueToken implements the token endpoint.
+func (s *Server) issueToken(w http.ResponseWriter, r *http.Request) {
+	var cred easemob.Credentials
+	if !s.decode(w, r, &cred) {
+		return
+	}
+	if cred.GrantType != "client_credentials" {
+		s.fail(w, r, http.StatusBadRequest, "unsupported_grant_type", "unsupported grant type: "+cred.GrantType)
+		return
+	}
+	if cred.ClientId != s.ClientID || cred.Secret != s.ClientSecret {
+		s.fail(w, r, http.StatusUnauthorized, "invalid_grant", "client_id does not match")
+		return
+	}
+
+	token := "YWMt" + newUUID()
+	s.tokens[token] = time.Now().Add(tokenTTL * time.Second)
+	s.reply(w, r, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"expires_in":   tokenTTL,
+		"application":  s.appUUID,
+	})
+}
+
+// authorized reports whether r carries a live token.
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	expiry, ok := s.tokens[token]
+	return ok && time.Now().Before(expiry)
+}
+
+// decode decodes the JSON body of r into v, replying with an error if it
+// isn't valid.
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		s.fail(w, r, http.StatusBadRequest, "json_parse", "Unexpected character: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// envelope returns the fields every response of the API carries.
+func (s *Server) envelope(r *http.Request) map[string]interface{} {
+	return map[string]interface{}{
+		"action":          strings.ToLower(r.Method),
+		"application":     s.appUUID,
+		"path":            "/" + strings.Join(strings.Split(strings.Trim(r.URL.Path, "/"), "/")[2:], "/"),
+		"uri":             s.URL + r.URL.Path,
+		"timestamp":       s.now(),
+		"duration":        0,
+		"organization":    s.Org,
+		"applicationName": s.App,
+	}
+}
+
+// entities replies with ents as the entities of the envelope.
+func (s *Server) entities(w http.ResponseWriter, r *http.Request, ents interface{}, extra map[string]interface{}) {
+	v := s.envelope(r)
+	v["entities"] = ents
+	for k, e := range extra {
+		v[k] = e
+	}
+	s.reply(w, r, http.StatusOK, v)
+}
+
+// data replies with data as the data of the envelope.
+func (s *Server) data(w http.ResponseWriter, r *http.Request, data interface{}, extra map[string]interface{}) {
+	v := s.envelope(r)
+	v["data"] = data
+	for k, e := range extra {
+		v[k] = e
+	}
+	s.reply(w, r, http.StatusOK, v)
+}
+
+// fail replies with an API error.
+func (s *Server) fail(w http.ResponseWriter, r *http.Request, status int, code, description string) {
+	s.reply(w, r, status, map[string]interface{}{
+		"error":             code,
+		"exception":         exceptions[code],
+		"timestamp":         s.now(),
+		"duration":          0,
+		"error_description": description,
+	})
+}
+
+// exceptions maps error codes to the server-side exceptions reported with
+// them.
+var exceptions = map[string]string{
+	"duplicate_unique_property_exists": "org.apache.usergrid.persistence.DuplicateUniquePropertyExistsException",
+	"service_resource_not_found":       "org.apache.usergrid.services.exceptions.ServiceResourceNotFoundException",
+	"resource_not_found":               "EasemobResourceNotFoundException",
+	"illegal_argument":                 "java.lang.IllegalArgumentException",
+	"json_parse":                       "org.codehaus.jackson.JsonParseException",
+	"forbidden_op":                     "EasemobForbiddenOpException",
+	"unauthorized":                     "EasemobSecurityException",
+	"invalid_grant":                    "EasemobSecurityException",
+}
+
+func (s *Server) reply(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// now returns the current time in milliseconds since the epoch, strictly
+// later than any time returned before so that entities keep their order.
+func (s *Server) now() int64 {
+	t := time.Now().UnixNano() / int64(time.Millisecond)
+	if t <= s.lastTime {
+		t = s.lastTime + 1
+	}
+	s.lastTime = t
+	return t
+}
+
+// nextID returns a new numeric id, as used for groups and chatrooms.
+func (s *Server) nextID() string {
+	if s.lastID == 0 {
+		s.lastID = time.Now().UnixNano() / int64(time.Millisecond) * 1000
+	}
+	s.lastID++
+	return fmt.Sprint(s.lastID)
+}
+
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
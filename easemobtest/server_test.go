@@ -0,0 +1,266 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemobtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/weilixu7/easemob"
+	"github.com/weilixu7/easemob/easemobtest"
+)
+
+// newTestClient starts a fake server and returns a client of it. The
+// server is closed when the test ends.
+func newTestClient(t *testing.T, opts ...easemob.Option) (*easemob.Client, *easemobtest.Server) {
+	t.Helper()
+	srv := easemobtest.NewServer()
+	t.Cleanup(srv.Close)
+	client, err := srv.NewClient(opts...)
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+	return client, srv
+}
+
+func TestUsersService_Register(t *testing.T) {
+	client, srv := newTestClient(t)
+
+	user, _, err := client.Users.Register("alice", "secret")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if user.Username != "alice" || user.Uuid == "" {
+		t.Errorf("Register returned %+v", user)
+	}
+	if pw, _ := srv.Password("alice"); pw != "secret" {
+		t.Errorf("server password = %q, want %q", pw, "secret")
+	}
+
+	got, _, err := client.Users.Get("alice")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got.Uuid != user.Uuid {
+		t.Errorf("Get returned %+v, want %+v", got, user)
+	}
+
+	if _, _, err := client.Users.Get("nobody"); !errors.Is(err, easemob.ErrNotFound) {
+		t.Errorf("Get of a missing user returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestUsersService_Register_duplicate(t *testing.T) {
+	client, srv := newTestClient(t)
+	srv.AddUser("alice", "secret")
+
+	_, _, err := client.Users.Register("alice", "other")
+	if !errors.Is(err, easemob.ErrDuplicateUser) {
+		t.Fatalf("Register of an existing user returned %v, want ErrDuplicateUser", err)
+	}
+	var apiErr *easemob.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != "duplicate_unique_property_exists" {
+		t.Errorf("Register error = %#v, want a 400 duplicate_unique_property_exists APIError", err)
+	}
+	if pw, _ := srv.Password("alice"); pw != "secret" {
+		t.Errorf("password changed to %q", pw)
+	}
+}
+
+func TestUsersService_ListAll_cursor(t *testing.T) {
+	client, srv := newTestClient(t)
+	var want []string
+	for i := 1; i <= 5; i++ {
+		name := fmt.Sprintf("u%d", i)
+		srv.AddUser(name, "secret")
+		want = append(want, name)
+	}
+
+	var got []string
+	opt := &easemob.ListOptions{Limit: 2}
+	for pages := 1; ; pages++ {
+		users, resp, err := client.Users.ListAll(opt)
+		if err != nil {
+			t.Fatalf("ListAll returned error: %v", err)
+		}
+		for _, u := range users {
+			got = append(got, u.Username)
+		}
+		if resp.Cursor == "" {
+			if pages != 3 {
+				t.Errorf("listed %d pages, want 3", pages)
+			}
+			break
+		}
+		opt.Cursor = resp.Cursor
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listed users %v, want %v", got, want)
+	}
+}
+
+func TestUsersService_DeleteAll(t *testing.T) {
+	client, srv := newTestClient(t)
+	var names []string
+	var after int64
+	for i := 0; i < 25; i++ {
+		u := srv.AddUser(fmt.Sprintf("u%02d", i), "secret")
+		if i == 2 {
+			after = u.Created
+		}
+		names = append(names, u.Username)
+	}
+	filter := easemob.Query{}.CreatedAfter(time.Unix(0, after*int64(time.Millisecond)))
+	ctx := context.Background()
+
+	var dry [][]string
+	listed, err := client.Users.DeleteAll(ctx, filter, &easemob.DeleteAllOptions{
+		BatchSize: 10,
+		DryRun:    true,
+		Progress:  func(batch []*easemob.User, total int) { dry = append(dry, usernames(batch)) },
+	})
+	if err != nil {
+		t.Fatalf("DeleteAll dry run returned error: %v", err)
+	}
+	if got, want := usernames(listed), names[3:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("dry run listed %v, want %v", got, want)
+	}
+	if want := [][]string{names[3:13], names[13:23], names[23:]}; !reflect.DeepEqual(dry, want) {
+		t.Errorf("dry run Progress saw %v, want %v", dry, want)
+	}
+	if _, ok := srv.Password("u24"); !ok {
+		t.Fatalf("dry run deleted users")
+	}
+
+	var batches []int
+	var total int
+	deleted, err := client.Users.DeleteAll(ctx, filter, &easemob.DeleteAllOptions{
+		BatchSize: 10,
+		Progress: func(batch []*easemob.User, n int) {
+			batches = append(batches, len(batch))
+			total = n
+		},
+	})
+	if err != nil {
+		t.Fatalf("DeleteAll returned error: %v", err)
+	}
+	if got, want := usernames(deleted), names[3:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("deleted %v, want %v", got, want)
+	}
+	if want := []int{10, 10, 2}; !reflect.DeepEqual(batches, want) || total != 22 {
+		t.Errorf("Progress saw batches %v and total %d, want %v and 22", batches, total, want)
+	}
+	left, _, err := client.Users.ListAll(nil)
+	if err != nil {
+		t.Fatalf("ListAll returned error: %v", err)
+	}
+	if got, want := usernames(left), names[:3]; !reflect.DeepEqual(got, want) {
+		t.Errorf("users left %v, want %v", got, want)
+	}
+}
+
+func TestUsersService_BulkRegister(t *testing.T) {
+	client, srv := newTestClient(t)
+	srv.AddUser("u061", "old")
+
+	users := make([]easemob.PutOptions, 130)
+	for i := range users {
+		users[i] = easemob.PutOptions{Username: fmt.Sprintf("u%03d", i), Password: "secret"}
+	}
+	report, err := client.Users.BulkRegister(context.Background(), users, nil)
+	if err != nil {
+		t.Fatalf("BulkRegister returned error: %v", err)
+	}
+	if got := report.Usernames(easemob.RegisterExisted); !reflect.DeepEqual(got, []string{"u061"}) {
+		t.Errorf("existed %v, want [u061]", got)
+	}
+	if got := len(report.Usernames(easemob.RegisterCreated)); got != 129 {
+		t.Errorf("created %d users, want 129", got)
+	}
+	if pw, _ := srv.Password("u061"); pw != "old" {
+		t.Errorf("existing user's password changed to %q", pw)
+	}
+	if pw, _ := srv.Password("u129"); pw != "secret" {
+		t.Errorf("u129 password = %q, want %q", pw, "secret")
+	}
+}
+
+func TestChatroomService(t *testing.T) {
+	client, srv := newTestClient(t)
+	for _, name := range []string{"owner", "alice", "bob", "carol"} {
+		srv.AddUser(name, "secret")
+	}
+
+	id, _, err := client.Chatrooms.Create(&easemob.ChatroomOptions{
+		Name:        "lobby",
+		Description: "welcome",
+		Maxusers:    50,
+		Owner:       "owner",
+		Members:     []string{"alice"},
+	})
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	rooms, _, err := client.Chatrooms.Get(id)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if len(rooms) != 1 {
+		t.Fatalf("Get returned %d chatrooms, want 1", len(rooms))
+	}
+	room := rooms[0]
+	if room.ID != id || room.Name != "lobby" || room.Description != "welcome" || room.Maxusers != 50 || room.Affiliations.Owner() != "owner" {
+		t.Errorf("Get returned %+v", room)
+	}
+
+	if _, _, err := client.Chatrooms.AddMembers(id, "bob", "carol"); err != nil {
+		t.Fatalf("AddMembers returned error: %v", err)
+	}
+	if _, _, err := client.Chatrooms.DeleteMember(id, "alice"); err != nil {
+		t.Fatalf("DeleteMember returned error: %v", err)
+	}
+	members, _, err := client.Chatrooms.Members(id)
+	if err != nil {
+		t.Fatalf("Members returned error: %v", err)
+	}
+	var got []string
+	for _, m := range members {
+		got = append(got, m.Username)
+	}
+	sort.Strings(got)
+	if want := []string{"bob", "carol", "owner"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("members %v, want %v", got, want)
+	}
+
+	if _, _, err := client.Chatrooms.AddMember(id, "nobody"); err == nil {
+		t.Errorf("AddMember of a missing user returned no error")
+	}
+
+	if ok, _, err := client.Chatrooms.Delete(id); err != nil || !ok {
+		t.Fatalf("Delete returned %v, %v", ok, err)
+	}
+	if _, _, err := client.Chatrooms.Get(id); !errors.Is(err, easemob.ErrNotFound) {
+		t.Errorf("Get of a deleted chatroom returned %v, want ErrNotFound", err)
+	}
+	if _, _, err := client.Chatrooms.Delete(id); !errors.Is(err, easemob.ErrNotFound) {
+		t.Errorf("second Delete returned %v, want ErrNotFound", err)
+	}
+}
+
+func usernames(users []*easemob.User) []string {
+	var names []string
+	for _, u := range users {
+		names = append(names, u.Username)
+	}
+	return names
+}
@@ -0,0 +1,605 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemobtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/weilixu7/easemob"
+)
+
+// Paging defaults and limits of the users endpoints.
+const (
+	defaultLimit = 10
+	maxLimit     = 1000
+	maxRegister  = easemob.MaxRegisterBatch
+)
+
+// user is a registered user and the state attached to it.
+type user struct {
+	easemob.User
+	password string
+	contacts []string
+	blocks   []string
+	online   bool
+	offline  int
+}
+
+// AddUser registers a user directly, as if through the API.
+func (s *Server) AddUser(username, password string) *easemob.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.newUser(easemob.PutOptions{Username: username, Password: password})
+	s.users[username] = u
+	return s.entity(u)
+}
+
+// SetOnline sets whether a user is reported online by the status endpoints.
+// It does nothing if the user doesn't exist.
+func (s *Server) SetOnline(username string, online bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[username]; ok {
+		u.online = online
+	}
+}
+
+// Password returns the password of a user and whether the user exists.
+func (s *Server) Password(username string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[username]
+	if !ok {
+		return "", false
+	}
+	return u.password, true
+}
+
+func (s *Server) newUser(put easemob.PutOptions) *user {
+	now := s.now()
+	return &user{
+		User: easemob.User{
+			Uuid:      newUUID(),
+			Type:      "user",
+			Created:   now,
+			Modified:  now,
+			Username:  put.Username,
+			Activated: true,
+			Nickname:  put.Nickname,
+		},
+		password: put.Password,
+	}
+}
+
+// entity returns a copy of the API representation of u.
+func (s *Server) entity(u *user) *easemob.User {
+	e := u.User
+	return &e
+}
+
+// lookupUser returns the user named username, replying with an error if
+// there is none.
+func (s *Server) lookupUser(w http.ResponseWriter, r *http.Request, username string) (*user, bool) {
+	u, ok := s.users[username]
+	if !ok {
+		s.fail(w, r, http.StatusNotFound, "service_resource_not_found",
+			fmt.Sprintf("Service resource not found: user %v", username))
+	}
+	return u, ok
+}
+
+func (s *Server) registerUsers(w http.ResponseWriter, r *http.Request, _ []string) {
+	var raw json.RawMessage
+	if !s.decode(w, r, &raw) {
+		return
+	}
+	var puts []easemob.PutOptions
+	if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+		if err := json.Unmarshal(raw, &puts); err != nil {
+			s.fail(w, r, http.StatusBadRequest, "json_parse", err.Error())
+			return
+		}
+	} else {
+		var put easemob.PutOptions
+		if err := json.Unmarshal(raw, &put); err != nil {
+			s.fail(w, r, http.StatusBadRequest, "json_parse", err.Error())
+			return
+		}
+		puts = append(puts, put)
+	}
+
+	if len(puts) == 0 || len(puts) > maxRegister {
+		s.fail(w, r, http.StatusBadRequest, "illegal_argument",
+			fmt.Sprintf("the number of users to register must be between 1 and %d", maxRegister))
+		return
+	}
+	seen := make(map[string]bool)
+	for _, put := range puts {
+		if put.Username == "" || put.Password == "" {
+			s.fail(w, r, http.StatusBadRequest, "illegal_argument", "username and password are required")
+			return
+		}
+		if _, ok := s.users[put.Username]; ok || seen[put.Username] {
+			s.fail(w, r, http.StatusBadRequest, "duplicate_unique_property_exists",
+				fmt.Sprintf("Application %v Entity user requires that property named username be unique, value of %v exists", s.appUUID, put.Username))
+			return
+		}
+		seen[put.Username] = true
+	}
+
+	ents := make([]*easemob.User, len(puts))
+	for i, put := range puts {
+		u := s.newUser(put)
+		s.users[put.Username] = u
+		ents[i] = s.entity(u)
+	}
+	s.entities(w, r, ents, nil)
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request, _ []string) {
+	users, ok := s.queryUsers(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, ok := s.cursorPage(w, r, "limit")
+	if !ok {
+		return
+	}
+
+	end := offset + limit
+	if end > len(users) {
+		end = len(users)
+	}
+	var ents []*easemob.User
+	if offset < end {
+		for _, u := range users[offset:end] {
+			ents = append(ents, s.entity(u))
+		}
+	}
+	extra := map[string]interface{}{"count": len(ents)}
+	if end < len(users) {
+		extra["cursor"] = encodeCursor(end)
+	}
+	s.entities(w, r, ents, extra)
+}
+
+func (s *Server) deleteUsers(w http.ResponseWriter, r *http.Request, _ []string) {
+	users, ok := s.queryUsers(w, r)
+	if !ok {
+		return
+	}
+	limit, _, ok := s.cursorPage(w, r, "limit")
+	if !ok {
+		return
+	}
+
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	ents := make([]*easemob.User, len(users))
+	for i, u := range users {
+		ents[i] = s.entity(u)
+		s.removeUser(u.Username)
+	}
+	s.entities(w, r, ents, map[string]interface{}{"count": len(ents)})
+}
+
+// queryUsers returns the users selected by the ql parameter of r, in order.
+func (s *Server) queryUsers(w http.ResponseWriter, r *http.Request) ([]*user, bool) {
+	q, err := parseQL(r.URL.Query().Get("ql"))
+	if err != nil {
+		s.fail(w, r, http.StatusBadRequest, "illegal_argument", err.Error())
+		return nil, false
+	}
+
+	var users []*user
+	for _, u := range s.users {
+		if q.match(u) {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		a, b := q.key(users[i]), q.key(users[j])
+		if a == b {
+			return users[i].Created < users[j].Created
+		}
+		return (a < b) != q.desc
+	})
+	return users, true
+}
+
+// removeUser deletes a user along with its relationships.
+func (s *Server) removeUser(username string) {
+	delete(s.users, username)
+	for _, u := range s.users {
+		u.contacts = without(u.contacts, username)
+		u.blocks = without(u.blocks, username)
+	}
+	for _, g := range s.groups {
+		g.members = without(g.members, username)
+	}
+	for _, c := range s.chatrooms {
+		c.members = without(c.members, username)
+		c.admins = without(c.admins, username)
+	}
+	s.superAdmins = without(s.superAdmins, username)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request, args []string) {
+	u, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	s.entities(w, r, []*easemob.User{s.entity(u)}, map[string]interface{}{"count": 1})
+}
+
+func (s *Server) updateUser(w http.ResponseWriter, r *http.Request, args []string) {
+	u, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	var put easemob.PutOptions
+	if !s.decode(w, r, &put) {
+		return
+	}
+	if put.Nickname != "" {
+		u.Nickname = put.Nickname
+	}
+	u.Modified = s.now()
+	s.entities(w, r, []*easemob.User{s.entity(u)}, nil)
+}
+
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request, args []string) {
+	u, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	s.removeUser(u.Username)
+	s.entities(w, r, []*easemob.User{s.entity(u)}, nil)
+}
+
+func (s *Server) resetPassword(w http.ResponseWriter, r *http.Request, args []string) {
+	u, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	var put easemob.PutOptions
+	if !s.decode(w, r, &put) {
+		return
+	}
+	if put.NewPass == "" {
+		s.fail(w, r, http.StatusBadRequest, "illegal_argument", "newpassword is required")
+		return
+	}
+	u.password = put.NewPass
+	u.Modified = s.now()
+	v := s.envelope(r)
+	v["action"] = "set user password"
+	s.reply(w, r, http.StatusOK, v)
+}
+
+func (s *Server) userStatus(w http.ResponseWriter, r *http.Request, args []string) {
+	u, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	status := "offline"
+	if u.online {
+		status = "online"
+	}
+	s.data(w, r, map[string]string{u.Username: status}, nil)
+}
+
+func (s *Server) disconnectUser(w http.ResponseWriter, r *http.Request, args []string) {
+	u, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	u.online = false
+	s.data(w, r, map[string]bool{"result": true}, nil)
+}
+
+func (s *Server) offlineMsgCount(w http.ResponseWriter, r *http.Request, args []string) {
+	u, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	s.data(w, r, map[string]int{u.Username: u.offline}, nil)
+}
+
+func (s *Server) listContacts(w http.ResponseWriter, r *http.Request, args []string) {
+	u, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	s.data(w, r, nonNil(u.contacts), map[string]interface{}{"count": len(u.contacts)})
+}
+
+func (s *Server) addContact(w http.ResponseWriter, r *http.Request, args []string) {
+	owner, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	friend, ok := s.lookupUser(w, r, args[1])
+	if !ok {
+		return
+	}
+	owner.contacts = with(owner.contacts, friend.Username)
+	friend.contacts = with(friend.contacts, owner.Username)
+	s.entities(w, r, []*easemob.User{s.entity(friend)}, nil)
+}
+
+func (s *Server) deleteContact(w http.ResponseWriter, r *http.Request, args []string) {
+	owner, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	friend, ok := s.lookupUser(w, r, args[1])
+	if !ok {
+		return
+	}
+	owner.contacts = without(owner.contacts, friend.Username)
+	friend.contacts = without(friend.contacts, owner.Username)
+	s.entities(w, r, []*easemob.User{s.entity(friend)}, nil)
+}
+
+func (s *Server) listBlocks(w http.ResponseWriter, r *http.Request, args []string) {
+	u, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	size, offset, ok := s.cursorPage(w, r, "pageSize")
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("pageSize") == "" {
+		size = len(u.blocks)
+	}
+
+	end := offset + size
+	if end > len(u.blocks) {
+		end = len(u.blocks)
+	}
+	page := []string{}
+	if offset < end {
+		page = u.blocks[offset:end]
+	}
+	extra := map[string]interface{}{"count": len(page)}
+	if end < len(u.blocks) {
+		extra["cursor"] = encodeCursor(end)
+	}
+	s.data(w, r, page, extra)
+}
+
+func (s *Server) addBlocks(w http.ResponseWriter, r *http.Request, args []string) {
+	owner, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	var put easemob.PutOptions
+	if !s.decode(w, r, &put) {
+		return
+	}
+	for _, name := range put.Usernames {
+		if _, ok := s.lookupUser(w, r, name); !ok {
+			return
+		}
+	}
+	for _, name := range put.Usernames {
+		owner.blocks = with(owner.blocks, name)
+	}
+	s.data(w, r, nonNil(put.Usernames), nil)
+}
+
+func (s *Server) deleteBlock(w http.ResponseWriter, r *http.Request, args []string) {
+	owner, ok := s.lookupUser(w, r, args[0])
+	if !ok {
+		return
+	}
+	blocked, ok := s.lookupUser(w, r, args[1])
+	if !ok {
+		return
+	}
+	owner.blocks = without(owner.blocks, blocked.Username)
+	s.entities(w, r, []*easemob.User{s.entity(blocked)}, nil)
+}
+
+// cursorPage returns the page size, read from the param named sizeParam,
+// and offset, decoded from the cursor param, of a cursor paginated request.
+func (s *Server) cursorPage(w http.ResponseWriter, r *http.Request, sizeParam string) (int, int, bool) {
+	q := r.URL.Query()
+	size := defaultLimit
+	if v := q.Get(sizeParam); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxLimit {
+			s.fail(w, r, http.StatusBadRequest, "illegal_argument", "invalid "+sizeParam+": "+v)
+			return 0, 0, false
+		}
+		size = n
+	}
+	offset := 0
+	if c := q.Get("cursor"); c != "" {
+		n, err := decodeCursor(c)
+		if err != nil {
+			s.fail(w, r, http.StatusBadRequest, "illegal_argument", "invalid cursor: "+c)
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return size, offset, true
+}
+
+// numberedPage returns the page of items selected by the pagenum and
+// pagesize params of r, or all of them if r has neither.
+func numberedPage(r *http.Request, n int) (int, int) {
+	q := r.URL.Query()
+	num, _ := strconv.Atoi(q.Get("pagenum"))
+	size, _ := strconv.Atoi(q.Get("pagesize"))
+	if num <= 0 || size <= 0 {
+		return 0, n
+	}
+	start := (num - 1) * size
+	if start > n {
+		start = n
+	}
+	end := start + size
+	if end > n {
+		end = n
+	}
+	return start, end
+}
+
+// Cursors are opaque to clients; the server encodes offsets in them.
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(c string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil || !strings.HasPrefix(string(b), "offset:") {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return strconv.Atoi(strings.TrimPrefix(string(b), "offset:"))
+}
+
+// A query is a parsed QL expression: conditions on the created and modified
+// fields of users, and an order.
+type query struct {
+	conds []cond
+	order string
+	desc  bool
+}
+
+type cond struct {
+	field string
+	op    string
+	value int64
+}
+
+// parseQL parses the subset of QL the users endpoints support, such as
+// "select * where created > 1400000000000 order by created desc".
+func parseQL(ql string) (*query, error) {
+	q := &query{order: "created"}
+	s := strings.ToLower(strings.Join(strings.Fields(ql), " "))
+	s = strings.TrimSpace(strings.TrimPrefix(s, "select *"))
+	if s == "" {
+		return q, nil
+	}
+
+	if i := strings.Index(s, "order by "); i >= 0 {
+		order := strings.Fields(s[i+len("order by "):])
+		s = strings.TrimSpace(s[:i])
+		if len(order) == 0 || len(order) > 2 || !validField(order[0]) {
+			return nil, fmt.Errorf("unsupported order in ql: %q", ql)
+		}
+		q.order = order[0]
+		if len(order) == 2 {
+			switch order[1] {
+			case "asc":
+			case "desc":
+				q.desc = true
+			default:
+				return nil, fmt.Errorf("unsupported order in ql: %q", ql)
+			}
+		}
+	}
+
+	if s == "" {
+		return q, nil
+	}
+	if !strings.HasPrefix(s, "where ") {
+		return nil, fmt.Errorf("unsupported ql: %q", ql)
+	}
+	for _, c := range strings.Split(strings.TrimPrefix(s, "where "), " and ") {
+		f := strings.Fields(c)
+		if len(f) != 3 || !validField(f[0]) {
+			return nil, fmt.Errorf("unsupported condition in ql: %q", c)
+		}
+		switch f[1] {
+		case "<", "<=", "=", ">=", ">":
+		default:
+			return nil, fmt.Errorf("unsupported operator in ql: %q", c)
+		}
+		v, err := strconv.ParseInt(f[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported value in ql: %q", c)
+		}
+		q.conds = append(q.conds, cond{f[0], f[1], v})
+	}
+	return q, nil
+}
+
+func validField(f string) bool {
+	return f == "created" || f == "modified"
+}
+
+func (q *query) key(u *user) int64 {
+	if q.order == "modified" {
+		return u.Modified
+	}
+	return u.Created
+}
+
+func (q *query) match(u *user) bool {
+	for _, c := range q.conds {
+		v := u.Created
+		if c.field == "modified" {
+			v = u.Modified
+		}
+		var ok bool
+		switch c.op {
+		case "<":
+			ok = v < c.value
+		case "<=":
+			ok = v <= c.value
+		case "=":
+			ok = v == c.value
+		case ">=":
+			ok = v >= c.value
+		case ">":
+			ok = v > c.value
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// with returns list with s appended, unless already present.
+func with(list []string, s string) []string {
+	if contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
+
+// without returns list with s removed.
+func without(list []string, s string) []string {
+	out := list[:0]
+	for _, e := range list {
+		if e != s {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// nonNil returns list, or an empty list if it is nil, so that it encodes
+// as [] rather than null.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
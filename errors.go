@@ -0,0 +1,136 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors matched by an APIError with errors.Is, according to its status and
+// Easemob error string. ErrRateLimited also matches requests turned away by
+// the client's own RateLimiter.
+var (
+	ErrNotFound      = errors.New("easemob: resource not found")
+	ErrDuplicateUser = errors.New("easemob: user already exists")
+	ErrUnauthorized  = errors.New("easemob: unauthorized")
+)
+
+// An APIError reports an error response from the Easemob API.
+//
+// Easemob docs: http://www.easemob.com/docs/helps/errorcodes/
+type APIError struct {
+	Response   *http.Response // HTTP response that caused this error
+	StatusCode int
+
+	// Code is Easemob's error string, such as
+	// "duplicate_unique_property_exists".
+	Code string `json:"error"`
+
+	// Exception is the class of the server-side exception, such as
+	// "org.apache.usergrid.persistence.DuplicateUniquePropertyExistsException".
+	Exception   string `json:"exception"`
+	Description string `json:"error_description"`
+	Message     string `json:"message"`
+
+	// RequestID identifies the request to Easemob support, if the server
+	// sent one.
+	RequestID string `json:"-"`
+
+	// Duration is the time the server spent on the request.
+	Duration  time.Duration `json:"-"`
+	Timestamp int64         `json:"timestamp"`
+}
+
+// An ErrorResponse reports one or more errors caused by an API request.
+//
+// Deprecated: use APIError.
+type ErrorResponse = APIError
+
+func (e *APIError) Error() string {
+	msg := e.Code
+	if e.Description != "" {
+		msg = fmt.Sprintf("%v: %v", msg, e.Description)
+	} else if e.Message != "" {
+		msg = fmt.Sprintf("%v: %v", msg, e.Message)
+	}
+	if e.Response == nil || e.Response.Request == nil {
+		return fmt.Sprintf("easemob: %d %v", e.StatusCode, msg)
+	}
+	return fmt.Sprintf("%v %v: %d %v",
+		e.Response.Request.Method, e.Response.Request.URL,
+		e.StatusCode, msg)
+}
+
+// Is reports whether e is an instance of one of the sentinel errors of this
+// package, making errors.Is(err, ErrNotFound) and the like work.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || strings.HasSuffix(e.Code, "_not_found")
+	case ErrDuplicateUser:
+		return e.Code == "duplicate_unique_property_exists"
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.Code == "unauthorized" || e.Code == "auth_bad_access_token"
+	}
+	return false
+}
+
+// IsNotFound reports whether err means the user, group or other resource
+// requested does not exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsDuplicateUser reports whether err means a user being registered already
+// exists.
+func IsDuplicateUser(err error) bool {
+	return errors.Is(err, ErrDuplicateUser)
+}
+
+// IsRateLimited reports whether err means a request was throttled, by the
+// server or by the client's RateLimiter.
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsUnauthorized reports whether err means the token or credentials were
+// rejected.
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// CheckResponse checks the API response for errors, and returns them if
+// present.
+func CheckResponse(r *http.Response) error {
+	if c := r.StatusCode; 200 <= c && c <= 299 {
+		return nil
+	}
+	apiError := &APIError{
+		Response:   r,
+		StatusCode: r.StatusCode,
+		RequestID:  r.Header.Get("X-Request-Id"),
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err == nil && len(data) > 0 {
+		var body struct {
+			*APIError
+			Duration int64 `json:"duration"`
+		}
+		body.APIError = apiError
+		if json.Unmarshal(data, &body) == nil {
+			apiError.Duration = time.Duration(body.Duration) * time.Millisecond
+		}
+	}
+	return apiError
+}
@@ -0,0 +1,116 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIError_Is(t *testing.T) {
+	sentinels := []error{ErrNotFound, ErrDuplicateUser, ErrRateLimited, ErrUnauthorized}
+	tests := []struct {
+		status int
+		code   string
+		want   error // the only sentinel matched, or nil
+	}{
+		{http.StatusNotFound, "", ErrNotFound},
+		{http.StatusBadRequest, "service_resource_not_found", ErrNotFound},
+		{http.StatusNotFound, "organization_application_not_found", ErrNotFound},
+		{http.StatusBadRequest, "duplicate_unique_property_exists", ErrDuplicateUser},
+		{http.StatusTooManyRequests, "reach_limit", ErrRateLimited},
+		{http.StatusServiceUnavailable, "service_unavailable", ErrRateLimited},
+		{http.StatusUnauthorized, "", ErrUnauthorized},
+		{http.StatusBadRequest, "auth_bad_access_token", ErrUnauthorized},
+		{http.StatusForbidden, "unauthorized", ErrUnauthorized},
+		{http.StatusBadRequest, "illegal_argument", nil},
+		{http.StatusInternalServerError, "internal_server_error", nil},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("wrapped: %w", &APIError{StatusCode: tt.status, Code: tt.code})
+		for _, s := range sentinels {
+			if got := errors.Is(err, s); got != (s == tt.want) {
+				t.Errorf("errors.Is(%d %q, %v) = %v, want %v", tt.status, tt.code, s, got, !got)
+			}
+		}
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	resp := &http.Response{
+		Request:    &http.Request{Method: "POST"},
+		StatusCode: http.StatusBadRequest,
+		Header:     http.Header{"X-Request-Id": {"req-42"}},
+		Body: ioutil.NopCloser(strings.NewReader(`{
+			"error": "duplicate_unique_property_exists",
+			"exception": "org.apache.usergrid.persistence.DuplicateUniquePropertyExistsException",
+			"error_description": "Entity user requires that property named username be unique",
+			"timestamp": 1542600000000,
+			"duration": 12
+		}`)),
+	}
+	err := CheckResponse(resp)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("CheckResponse returned %T, want *APIError", err)
+	}
+	want := &APIError{
+		Response:    resp,
+		StatusCode:  http.StatusBadRequest,
+		Code:        "duplicate_unique_property_exists",
+		Exception:   "org.apache.usergrid.persistence.DuplicateUniquePropertyExistsException",
+		Description: "Entity user requires that property named username be unique",
+		RequestID:   "req-42",
+		Duration:    12 * time.Millisecond,
+		Timestamp:   1542600000000,
+	}
+	if *apiErr != *want {
+		t.Errorf("CheckResponse returned %+v, want %+v", apiErr, want)
+	}
+	if !IsDuplicateUser(err) {
+		t.Error("IsDuplicateUser returned false")
+	}
+
+	for _, body := range []string{"", "<html>Bad Gateway</html>"} {
+		resp := &http.Response{
+			StatusCode: http.StatusBadGateway,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}
+		err := CheckResponse(resp)
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Code != "" {
+			t.Errorf("CheckResponse with body %q returned %#v", body, err)
+		}
+	}
+
+	resp = &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}
+	if err := CheckResponse(resp); err != nil {
+		t.Errorf("CheckResponse of a 200 returned %v", err)
+	}
+}
+
+func TestClient_Do_malformed(t *testing.T) {
+	client, mux := setup(t)
+	mux.HandleFunc("/org/app/users/alice", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"entities":[{"username":`)
+	})
+	mux.HandleFunc("/org/app/users/bob", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<html>`)
+	})
+
+	if _, _, err := client.Users.Get("alice"); err == nil || !strings.Contains(err.Error(), "decoding response") {
+		t.Errorf("Get with a truncated body returned %v, want a decoding error", err)
+	}
+	if _, _, err := client.Users.Get("bob"); !IsNotFound(err) {
+		t.Errorf("Get with an undecodable 404 returned %v, want the API error", err)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+)
+
+// FilesService handles communication with the chatfiles methods of the
+// Easemob API, used to upload the media of image, voice, video and file
+// messages and to download them.
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/fileoperation
+type FilesService struct {
+	client *Client
+}
+
+// A ProgressFunc is called as a transfer proceeds with the number of bytes
+// transferred so far and the total, or -1 if the total is unknown.
+type ProgressFunc func(transferred, total int64)
+
+// UploadOptions specifies the optional parameters of an upload.
+type UploadOptions struct {
+	// RestrictAccess requires the share secret returned by the upload to
+	// download the file.
+	RestrictAccess bool
+
+	// Size is the number of bytes the reader will yield, if known. It is
+	// only used to report progress.
+	Size int64
+
+	Progress ProgressFunc
+}
+
+// DownloadOptions specifies the optional parameters of a download.
+type DownloadOptions struct {
+	// Thumbnail downloads the thumbnail of an image or video rather than
+	// the file itself.
+	Thumbnail bool
+
+	Progress ProgressFunc
+}
+
+// FileUpload describes an uploaded file.
+type FileUpload struct {
+	UUID        string `json:"uuid"`
+	Type        string `json:"type"`
+	ShareSecret string `json:"share-secret"`
+}
+
+// Upload streams the content of r to the chatfiles endpoint as filename.
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/fileoperation#上传文件
+func (s *FilesService) Upload(r io.Reader, filename string, opt *UploadOptions) (*FileUpload, *Response, error) {
+	return s.UploadContext(context.Background(), r, filename, opt)
+}
+
+// UploadContext is like Upload but sends the request with ctx.
+func (s *FilesService) UploadContext(ctx context.Context, r io.Reader, filename string, opt *UploadOptions) (*FileUpload, *Response, error) {
+	if opt == nil {
+		opt = new(UploadOptions)
+	}
+	if opt.Progress != nil {
+		total := opt.Size
+		if total <= 0 {
+			total = -1
+		}
+		r = &progressReader{r: r, total: total, fn: opt.Progress}
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeMultipartFile(mw, r, filename))
+	}()
+
+	var u string
+	u = "chatfiles"
+
+	req, err := s.client.newStreamRequest(ctx, "POST", u, pr, mw.FormDataContentType())
+	if err != nil {
+		pr.Close()
+		return nil, nil, err
+	}
+	if opt.RestrictAccess {
+		req.Header.Set("restrict-access", "true")
+	}
+
+	resp, err := s.client.Do(req)
+	// Stop the writer if the request ended before consuming the body.
+	pr.Close()
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var uploads []*FileUpload
+	if err := resp.decodeEntities(&uploads); err != nil {
+		return nil, resp, err
+	}
+	if len(uploads) == 0 {
+		return nil, resp, fmt.Errorf("easemob: upload of %v returned no file", filename)
+	}
+	return uploads[0], resp, nil
+}
+
+// writeMultipartFile writes a form with r as its file field to mw.
+func writeMultipartFile(mw *multipart.Writer, r io.Reader, filename string) error {
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename)))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+		"name":     "file",
+		"filename": filepath.Base(filename),
+	}))
+	h.Set("Content-Type", contentType)
+
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// Download writes the file uuid to w, returning the number of bytes written.
+// secret is the share secret returned by the upload, required for files
+// uploaded with restricted access.
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/fileoperation#下载文件
+func (s *FilesService) Download(uuid string, secret string, w io.Writer, opt *DownloadOptions) (int64, *Response, error) {
+	return s.DownloadContext(context.Background(), uuid, secret, w, opt)
+}
+
+// DownloadContext is like Download but sends the request with ctx.
+func (s *FilesService) DownloadContext(ctx context.Context, uuid string, secret string, w io.Writer, opt *DownloadOptions) (int64, *Response, error) {
+	if opt == nil {
+		opt = new(DownloadOptions)
+	}
+
+	var u string
+	u = fmt.Sprintf("chatfiles/%v", uuid)
+
+	req, err := s.client.newStreamRequest(ctx, "GET", u, nil, "")
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	if secret != "" {
+		req.Header.Set("share-secret", secret)
+	}
+	if opt.Thumbnail {
+		req.Header.Set("thumbnail", "true")
+	}
+
+	pw := &progressWriter{w: w, total: -1, fn: opt.Progress}
+	resp, err := s.client.do(req, pw)
+	return pw.n, resp, err
+}
+
+// URL returns the address of the file uuid, as used in the url field of
+// media messages.
+func (s *FilesService) URL(uuid string) string {
+	u, err := s.client.resolve(fmt.Sprintf("chatfiles/%v", uuid))
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+// progressReader reports the bytes read through it.
+type progressReader struct {
+	r     io.Reader
+	n     int64
+	total int64
+	fn    ProgressFunc
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.n += int64(n)
+		r.fn(r.n, r.total)
+	}
+	return n, err
+}
+
+// progressWriter counts the bytes written through it, and reports them if
+// fn is set.
+type progressWriter struct {
+	w     io.Writer
+	n     int64
+	total int64
+	fn    ProgressFunc
+}
+
+// begin learns the total from the response about to be written.
+func (w *progressWriter) begin(resp *http.Response) {
+	w.total = resp.ContentLength
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	if w.fn != nil && n > 0 {
+		w.fn(w.n, w.total)
+	}
+	return n, err
+}
@@ -0,0 +1,206 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fileServer is a chatfiles endpoint keeping uploads in memory.
+type fileServer struct {
+	mu      sync.Mutex
+	files   map[string][]byte
+	types   map[string]string
+	secrets map[string]string
+}
+
+func handleFiles(t *testing.T, mux *http.ServeMux) *fileServer {
+	s := &fileServer{
+		files:   make(map[string][]byte),
+		types:   make(map[string]string),
+		secrets: make(map[string]string),
+	}
+	mux.HandleFunc("/org/app/chatfiles", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		f, h, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":"illegal_argument","error_description":%q}`, err.Error())
+			return
+		}
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		uuid := fmt.Sprintf("file-%d", len(s.files)+1)
+		s.files[uuid] = data
+		s.types[uuid] = h.Header.Get("Content-Type")
+		secret := ""
+		if r.Header.Get("restrict-access") == "true" {
+			secret = "secret-" + uuid
+			s.secrets[uuid] = secret
+		}
+		fmt.Fprintf(w, `{"entities":[{"uuid":%q,"type":"chatfile","share-secret":%q}]}`, uuid, secret)
+	})
+	mux.HandleFunc("/org/app/chatfiles/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		uuid := strings.TrimPrefix(r.URL.Path, "/org/app/chatfiles/")
+		s.mu.Lock()
+		data, ok := s.files[uuid]
+		secret := s.secrets[uuid]
+		s.mu.Unlock()
+		switch {
+		case !ok:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"file_not_found"}`)
+		case secret != "" && r.Header.Get("share-secret") != secret:
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"unauthorized"}`)
+		default:
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data)
+		}
+	})
+	return s
+}
+
+func TestFilesService_roundTrip(t *testing.T) {
+	client, mux := setup(t)
+	files := handleFiles(t, mux)
+	// A download refused with 401 is retried once with a new token.
+	var tokens int32
+	handleToken(t, mux, "test-token", 7200, &tokens)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 16<<10)
+
+	var uploaded, uploadTotal int64
+	up, _, err := client.Files.Upload(bytes.NewReader(data), "photos/cat.png", &UploadOptions{
+		RestrictAccess: true,
+		Size:           int64(len(data)),
+		Progress:       func(n, total int64) { uploaded, uploadTotal = n, total },
+	})
+	if err != nil {
+		t.Fatalf("Upload returned error: %v", err)
+	}
+	if up.UUID == "" || up.ShareSecret == "" {
+		t.Fatalf("Upload returned %+v, want a uuid and share secret", up)
+	}
+	if ct := files.types[up.UUID]; ct != "image/png" {
+		t.Errorf("uploaded as %q, want image/png", ct)
+	}
+	if uploaded != int64(len(data)) || uploadTotal != int64(len(data)) {
+		t.Errorf("upload progress ended at %d/%d, want %d/%d", uploaded, uploadTotal, len(data), len(data))
+	}
+
+	if _, _, err := client.Files.Download(up.UUID, "", ioutil.Discard, nil); !IsUnauthorized(err) {
+		t.Errorf("Download without the share secret returned %v, want ErrUnauthorized", err)
+	}
+
+	var buf bytes.Buffer
+	var calls int
+	var downloaded, downloadTotal int64
+	n, _, err := client.Files.Download(up.UUID, up.ShareSecret, &buf, &DownloadOptions{
+		Progress: func(n, total int64) { calls, downloaded, downloadTotal = calls+1, n, total },
+	})
+	if err != nil {
+		t.Fatalf("Download returned error: %v", err)
+	}
+	if n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("Download wrote %d bytes, want the %d uploaded", n, len(data))
+	}
+	if calls == 0 || downloaded != n || downloadTotal != n {
+		t.Errorf("download progress ended at %d/%d after %d calls, want %d/%d", downloaded, downloadTotal, calls, n, n)
+	}
+
+	if _, _, err := client.Files.Download("nothing", "", ioutil.Discard, nil); !IsNotFound(err) {
+		t.Errorf("Download of a missing file returned %v, want ErrNotFound", err)
+	}
+}
+
+// failingReader yields n bytes and then fails.
+type failingReader struct {
+	n int
+}
+
+var errRead = errors.New("read failed")
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, errRead
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	for i := range p {
+		p[i] = 'x'
+	}
+	r.n -= len(p)
+	return len(p), nil
+}
+
+// endlessReader never runs out of bytes.
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) { return len(p), nil }
+
+// checkWriterDone fails t if a multipart writer goroutine of an upload is
+// still running after a short grace period.
+func checkWriterDone(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		buf := make([]byte, 1<<20)
+		buf = buf[:runtime.Stack(buf, true)]
+		if !bytes.Contains(buf, []byte("writeMultipartFile")) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("upload writer goroutine leaked:\n%s", buf)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFilesService_Upload_readError(t *testing.T) {
+	client, mux := setup(t)
+	handleFiles(t, mux)
+
+	_, _, err := client.Files.Upload(&failingReader{n: 100 << 10}, "cat.png", nil)
+	if err == nil {
+		t.Fatal("Upload of a failing reader returned no error")
+	}
+	checkWriterDone(t)
+}
+
+func TestFilesService_Upload_rejected(t *testing.T) {
+	client, mux := setup(t)
+	mux.HandleFunc("/org/app/chatfiles", func(w http.ResponseWriter, r *http.Request) {
+		// Answer without reading the body, as for a file too large.
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		fmt.Fprint(w, `{"error":"file_too_large"}`)
+	})
+
+	_, _, err := client.Files.Upload(io.LimitReader(endlessReader{}, 64<<20), "cat.png", nil)
+	if err == nil {
+		t.Fatal("rejected Upload returned no error")
+	}
+	checkWriterDone(t)
+}
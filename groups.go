@@ -1,7 +1,10 @@
 package easemob
 
 import (
+  "context"
+  "encoding/json"
   "fmt"
+  "strings"
 )
 
 // GroupService handles communication with the group related
@@ -12,66 +15,230 @@ type GroupService struct {
   client *Client
 }
 
+// Group is a group as listed by ListAll and UserGroups.
+type Group struct {
+  Groupid      string `json:"groupid"`
+  Groupname    string `json:"groupname"`
+  Owner        string `json:"owner,omitempty"`
+  Affiliations int    `json:"affiliations,omitempty"`
+  Type         string `json:"type,omitempty"`
+  LastModified string `json:"last_modified,omitempty"`
+}
+
+// GroupDetail is the full description of a group returned by Get.
+type GroupDetail struct {
+  ID                string     `json:"id"`
+  Name              string     `json:"name"`
+  Description       string     `json:"description"`
+  Public            bool       `json:"public"`
+  MembersOnly       bool       `json:"membersonly"`
+  AllowInvites      bool       `json:"allowinvites"`
+  Maxusers          int        `json:"maxusers"`
+  Created           int64      `json:"created"`
+  Custom            string     `json:"custom,omitempty"`
+  AffiliationsCount int        `json:"affiliations_count"`
+  Affiliations      MemberList `json:"affiliations"`
+}
+
+// GroupOptions specifies the parameters of a new group.
+type GroupOptions struct {
+  Groupname    string   `json:"groupname"`
+  Description  string   `json:"desc"`
+  Public       bool     `json:"public"`
+  Maxusers     int      `json:"maxusers,omitempty"`
+  MembersOnly  bool     `json:"members_only"`
+  AllowInvites bool     `json:"allowinvites"`
+  Owner        string   `json:"owner"`
+  Members      []string `json:"members,omitempty"`
+}
+
+// Member is a user belonging to a group or chatroom along with its role,
+// "owner" or "member".
+type Member struct {
+  Username string
+  Role     string
+}
+
+// UnmarshalJSON decodes a member from Easemob's {"<role>": "<username>"}
+// form.
+func (m *Member) UnmarshalJSON(data []byte) error {
+  var v map[string]string
+  if err := json.Unmarshal(data, &v); err != nil {
+    return err
+  }
+  for role, username := range v {
+    m.Role, m.Username = role, username
+  }
+  return nil
+}
+
+// MarshalJSON encodes a member in Easemob's {"<role>": "<username>"} form.
+func (m Member) MarshalJSON() ([]byte, error) {
+  return json.Marshal(map[string]string{m.Role: m.Username})
+}
+
+// MemberList is the list of members of a group or chatroom.
+type MemberList []*Member
+
+// Owner returns the username of the owner, if listed.
+func (l MemberList) Owner() string {
+  for _, m := range l {
+    if m.Role == "owner" {
+      return m.Username
+    }
+  }
+  return ""
+}
+
+// Usernames returns the usernames of all members, including the owner.
+func (l MemberList) Usernames() []string {
+  names := make([]string, len(l))
+  for i, m := range l {
+    names[i] = m.Username
+  }
+  return names
+}
+
+// UpdateResult reports which of the fields passed to an update were
+// changed.
+type UpdateResult map[string]bool
+
+// successResult is the data of requests reporting success as either
+// "success" or "result".
+type successResult struct {
+  Success bool `json:"success"`
+  Result  bool `json:"result"`
+}
+
+func (r successResult) ok() bool {
+  return r.Success || r.Result
+}
+
 // ListAll list all groups
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/groups/#getallgroups
-func (s *GroupService) ListAll() (*Response, error) {
+func (s *GroupService) ListAll() ([]*Group, *Response, error) {
+  return s.ListAllContext(context.Background())
+}
+
+// ListAllContext is like ListAll but sends the request with ctx.
+func (s *GroupService) ListAllContext(ctx context.Context) ([]*Group, *Response, error) {
   var u string
   u = "chatgroups"
-  req, err := s.client.NewRequest("GET", u, nil)
+  req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
   if err != nil {
-    return nil, err
+    return nil, nil, err
   }
 
   resp, err := s.client.Do(req)
-  return resp, err
+  if err != nil {
+    return nil, resp, err
+  }
+
+  var groups []*Group
+  err = resp.decodeData(&groups)
+  return groups, resp, err
 }
 
-func generalizeStringList(strs []string) string {
-  var strings string
-  strings = ""
-  for i := range strs {
-    strings = fmt.Sprintf("%v,%v", strings, strs[i])
+// List lists a page of groups. The cursor of the next page is returned in
+// Response.Cursor.
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/group#分页获取_app_下的群组
+func (s *GroupService) List(opt *ListOptions) ([]*Group, *Response, error) {
+  return s.ListContext(context.Background(), opt)
+}
+
+// ListContext is like List but sends the request with ctx.
+func (s *GroupService) ListContext(ctx context.Context, opt *ListOptions) ([]*Group, *Response, error) {
+  u, err := addOptions("chatgroups", opt)
+  if err != nil {
+    return nil, nil, err
+  }
+
+  req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+  if err != nil {
+    return nil, nil, err
+  }
+
+  resp, err := s.client.Do(req)
+  if err != nil {
+    return nil, resp, err
   }
-  return strings
+
+  var groups []*Group
+  err = resp.decodeData(&groups)
+  return groups, resp, err
+}
+
+func generalizeStringList(strs []string) string {
+  return strings.Join(strs, ",")
 }
 
 // Get fetch group details
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/groups/#getgroups
-func (s *GroupService) Get(groups ...string) (*Response, error) {
+func (s *GroupService) Get(groups ...string) ([]*GroupDetail, *Response, error) {
+  return s.GetContext(context.Background(), groups...)
+}
+
+// GetContext is like Get but sends the request with ctx.
+func (s *GroupService) GetContext(ctx context.Context, groups ...string) ([]*GroupDetail, *Response, error) {
   var u string
   u = fmt.Sprintf("chatgroups/%v", generalizeStringList(groups))
 
-  req, err := s.client.NewRequest("GET", u, nil)
+  req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
   if err != nil {
-    return nil, err
+    return nil, nil, err
   }
 
   resp, err := s.client.Do(req)
-  return resp, err
+  if err != nil {
+    return nil, resp, err
+  }
+
+  var details []*GroupDetail
+  err = resp.decodeData(&details)
+  return details, resp, err
 }
 
-// Create create a new group
+// Create create a new group and returns its id
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/groups/#create
-func (s *GroupService) Create() (*Response, error) {
+func (s *GroupService) Create(opt *GroupOptions) (string, *Response, error) {
+  return s.CreateContext(context.Background(), opt)
+}
+
+// CreateContext is like Create but sends the request with ctx.
+func (s *GroupService) CreateContext(ctx context.Context, opt *GroupOptions) (string, *Response, error) {
   var u string
   u = "chatgroups"
 
-  req, err := s.client.NewRequest("POST", u, nil)
+  req, err := s.client.NewRequestContext(ctx, "POST", u, opt)
   if err != nil {
-    return nil, err
+    return "", nil, err
   }
 
   resp, err := s.client.Do(req)
-  return resp, err
+  if err != nil {
+    return "", resp, err
+  }
+
+  var data struct {
+    Groupid string `json:"groupid"`
+  }
+  err = resp.decodeData(&data)
+  return data.Groupid, resp, err
 }
 
 // Update edit a group infomation
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/groups/#update
-func (s *GroupService) Update(groupid string, name string, description string, maxusers int) (*Response, error) {
+func (s *GroupService) Update(groupid string, name string, description string, maxusers int) (UpdateResult, *Response, error) {
+  return s.UpdateContext(context.Background(), groupid, name, description, maxusers)
+}
+
+// UpdateContext is like Update but sends the request with ctx.
+func (s *GroupService) UpdateContext(ctx context.Context, groupid string, name string, description string, maxusers int) (UpdateResult, *Response, error) {
   var u string
   u = fmt.Sprintf("chatgroups/%v", groupid)
 
@@ -86,92 +253,184 @@ func (s *GroupService) Update(groupid string, name string, description string, m
     put.Maxusers = maxusers
   }
 
-  req, err := s.client.NewRequest("PUT", u, put)
+  req, err := s.client.NewRequestContext(ctx, "PUT", u, put)
   if err != nil {
-    return nil, err
+    return nil, nil, err
   }
 
   resp, err := s.client.Do(req)
-  return resp, err
+  if err != nil {
+    return nil, resp, err
+  }
+
+  var result UpdateResult
+  err = resp.decodeData(&result)
+  return result, resp, err
 }
 
 // Delete remove a group
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/groups/#delete
-func (s *GroupService) Delete(groupid string) (*Response, error) {
+func (s *GroupService) Delete(groupid string) (bool, *Response, error) {
+  return s.DeleteContext(context.Background(), groupid)
+}
+
+// DeleteContext is like Delete but sends the request with ctx.
+func (s *GroupService) DeleteContext(ctx context.Context, groupid string) (bool, *Response, error) {
   var u string
   u = fmt.Sprintf("chatgroups/%v", groupid)
 
-  req, err := s.client.NewRequest("DELETE", u, nil)
+  req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
   if err != nil {
-    return nil, err
+    return false, nil, err
   }
 
   resp, err := s.client.Do(req)
-  return resp, err
+  if err != nil {
+    return false, resp, err
+  }
+
+  var result successResult
+  err = resp.decodeData(&result)
+  return result.ok(), resp, err
 }
 
 // Members
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/groups/#users
-func (s *GroupService) Members(groupid string) (*Response, error) {
+func (s *GroupService) Members(groupid string) (MemberList, *Response, error) {
+  return s.MembersContext(context.Background(), groupid)
+}
+
+// MembersContext is like Members but sends the request with ctx.
+func (s *GroupService) MembersContext(ctx context.Context, groupid string) (MemberList, *Response, error) {
   var u string
   u = fmt.Sprintf("chatgroups/%v/users", groupid)
 
-  req, err := s.client.NewRequest("GET", u, nil)
+  req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+  if err != nil {
+    return nil, nil, err
+  }
+
+  resp, err := s.client.Do(req)
+  if err != nil {
+    return nil, resp, err
+  }
+
+  var members MemberList
+  err = resp.decodeData(&members)
+  return members, resp, err
+}
+
+// MembersPage lists a page of the members of a group.
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/group#分页获取群组成员
+func (s *GroupService) MembersPage(groupid string, opt *PageOptions) (MemberList, *Response, error) {
+  return s.MembersPageContext(context.Background(), groupid, opt)
+}
+
+// MembersPageContext is like MembersPage but sends the request with ctx.
+func (s *GroupService) MembersPageContext(ctx context.Context, groupid string, opt *PageOptions) (MemberList, *Response, error) {
+  u, err := addOptions(fmt.Sprintf("chatgroups/%v/users", groupid), opt)
   if err != nil {
-    return nil, err
+    return nil, nil, err
+  }
+
+  req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+  if err != nil {
+    return nil, nil, err
   }
 
   resp, err := s.client.Do(req)
-  return resp, err
+  if err != nil {
+    return nil, resp, err
+  }
+
+  var members MemberList
+  err = resp.decodeData(&members)
+  return members, resp, err
 }
 
 // AddMember
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/groups/#addmember
-func (s *GroupService) AddMember(groupid string, user string) (*Response, error) {
+func (s *GroupService) AddMember(groupid string, user string) (bool, *Response, error) {
+  return s.AddMemberContext(context.Background(), groupid, user)
+}
+
+// AddMemberContext is like AddMember but sends the request with ctx.
+func (s *GroupService) AddMemberContext(ctx context.Context, groupid string, user string) (bool, *Response, error) {
   var u string
   u = fmt.Sprintf("chatgroups/%v/users/%v", groupid, user)
 
-  req, err := s.client.NewRequest("POST", u, nil)
+  req, err := s.client.NewRequestContext(ctx, "POST", u, nil)
   if err != nil {
-    return nil, err
+    return false, nil, err
   }
 
   resp, err := s.client.Do(req)
-  return resp, err
+  if err != nil {
+    return false, resp, err
+  }
+
+  var result successResult
+  err = resp.decodeData(&result)
+  return result.ok(), resp, err
 }
 
 // DeleteMember
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/groups/#deletemember
-func (s *GroupService) DeleteMember(groupid string, user string) (*Response, error) {
+func (s *GroupService) DeleteMember(groupid string, user string) (bool, *Response, error) {
+  return s.DeleteMemberContext(context.Background(), groupid, user)
+}
+
+// DeleteMemberContext is like DeleteMember but sends the request with ctx.
+func (s *GroupService) DeleteMemberContext(ctx context.Context, groupid string, user string) (bool, *Response, error) {
   var u string
   u = fmt.Sprintf("chatgroups/%v/users/%v", groupid, user)
 
-  req, err := s.client.NewRequest("DELETE", u, nil)
+  req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
   if err != nil {
-    return nil, err
+    return false, nil, err
   }
 
   resp, err := s.client.Do(req)
-  return resp, err
+  if err != nil {
+    return false, resp, err
+  }
+
+  var result successResult
+  err = resp.decodeData(&result)
+  return result.ok(), resp, err
 }
 
 // AddMembers
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/groups/#addmemberbatch
-func (s *GroupService) AddMembers(users ...string) (*Response, error) {
+func (s *GroupService) AddMembers(groupid string, users ...string) ([]string, *Response, error) {
+  return s.AddMembersContext(context.Background(), groupid, users...)
+}
+
+// AddMembersContext is like AddMembers but sends the request with ctx.
+func (s *GroupService) AddMembersContext(ctx context.Context, groupid string, users ...string) ([]string, *Response, error) {
   var u string
-  u = fmt.Sprintf("chatgroups/%v/users")
+  u = fmt.Sprintf("chatgroups/%v/users", groupid)
 
   put := &PutOptions{Usernames: users}
-  req, err := s.client.NewRequest("POST", u, put)
+  req, err := s.client.NewRequestContext(ctx, "POST", u, put)
   if err != nil {
-    return nil, err
+    return nil, nil, err
   }
 
   resp, err := s.client.Do(req)
-  return resp, err
+  if err != nil {
+    return nil, resp, err
+  }
+
+  var data struct {
+    Newmembers []string `json:"newmembers"`
+  }
+  err = resp.decodeData(&data)
+  return data.Newmembers, resp, err
 }
@@ -0,0 +1,214 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HistoryService handles communication with the chat history methods of
+// the Easemob API. Easemob archives the messages of an app hour by hour, as
+// gzip'd files of JSON records.
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatrecord
+type HistoryService struct {
+	client *Client
+}
+
+// HistoryRecord is a message from a chat history archive.
+type HistoryRecord struct {
+	MsgID     string         `json:"msg_id"`
+	Timestamp int64          `json:"timestamp"` // milliseconds since the epoch
+	Direction string         `json:"direction"`
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	ChatType  string         `json:"chat_type"` // "chat", "groupchat" or "chatroom"
+	Payload   MessagePayload `json:"payload"`
+}
+
+// Time returns the time the message was sent.
+func (r *HistoryRecord) Time() time.Time {
+	return time.Unix(0, r.Timestamp*int64(time.Millisecond))
+}
+
+// archiveHour formats the hour of t as the archive endpoints expect it.
+func archiveHour(t time.Time) string {
+	return t.UTC().Format("2006010215")
+}
+
+// ArchiveURL returns the download address of the archive of the hour
+// containing t.
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/chatrecord#获取聊天记录文件
+func (s *HistoryService) ArchiveURL(t time.Time) (string, *Response, error) {
+	return s.ArchiveURLContext(context.Background(), t)
+}
+
+// ArchiveURLContext is like ArchiveURL but sends the request with ctx.
+func (s *HistoryService) ArchiveURLContext(ctx context.Context, t time.Time) (string, *Response, error) {
+	var u string
+	u = fmt.Sprintf("chatmessages/%v", archiveHour(t))
+
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", resp, err
+	}
+
+	var data []struct {
+		URL string `json:"url"`
+	}
+	if err := resp.decodeData(&data); err != nil {
+		return "", resp, err
+	}
+	if len(data) == 0 || data[0].URL == "" {
+		return "", resp, fmt.Errorf("%w: no chat history archive for %v", ErrNotFound, archiveHour(t))
+	}
+	return data[0].URL, resp, nil
+}
+
+// Archive downloads the archive of the hour containing t and returns an
+// iterator over its records. The archive is decompressed and decoded as the
+// iterator advances; the caller must Close it.
+//
+//	it, err := client.History.Archive(ctx, hour)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		record := it.Value()
+//		...
+//	}
+//	return it.Err()
+func (s *HistoryService) Archive(ctx context.Context, t time.Time) (*HistoryIterator, error) {
+	u, _, err := s.ArchiveURLContext(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	// The archive is served from storage outside the API, through a
+	// presigned URL.
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	r, err := decompress(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &HistoryIterator{dec: json.NewDecoder(r), body: resp.Body}, nil
+}
+
+// decompress returns the content of a gzip stream, or the stream itself if
+// it isn't compressed, which is the case when the server compressed it in
+// transit and the transport already decompressed it.
+func decompress(body io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(body)
+	magic, err := br.Peek(2)
+	if err == io.EOF {
+		return br, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if magic[0] != 0x1f || magic[1] != 0x8b {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
+
+// Backfill walks the archives of every hour from start up to end, calling
+// fn with each record. Hours without an archive are skipped. It stops at the
+// first error, including one returned by fn.
+func (s *HistoryService) Backfill(ctx context.Context, start, end time.Time, fn func(*HistoryRecord) error) error {
+	for hour := start.Truncate(time.Hour); hour.Before(end); hour = hour.Add(time.Hour) {
+		if err := s.backfillHour(ctx, hour, fn); err != nil {
+			return fmt.Errorf("easemob: backfilling %v: %w", archiveHour(hour), err)
+		}
+	}
+	return nil
+}
+
+func (s *HistoryService) backfillHour(ctx context.Context, hour time.Time, fn func(*HistoryRecord) error) error {
+	it, err := s.Archive(ctx, hour)
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		if err := fn(it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// A HistoryIterator steps through the records of a chat history archive.
+type HistoryIterator struct {
+	dec  *json.Decoder
+	body io.Closer
+	cur  *HistoryRecord
+	err  error
+}
+
+// Next advances to the next record, returning false when there are no more
+// records or an error occurred.
+func (it *HistoryIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	r := new(HistoryRecord)
+	if err := it.dec.Decode(r); err != nil {
+		if err != io.EOF {
+			it.err = err
+		}
+		it.cur = nil
+		return false
+	}
+	it.cur = r
+	return true
+}
+
+// Value returns the current record.
+func (it *HistoryIterator) Value() *HistoryRecord {
+	return it.cur
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *HistoryIterator) Err() error {
+	return it.err
+}
+
+// Close releases the archive download.
+func (it *HistoryIterator) Close() error {
+	return it.body.Close()
+}
@@ -0,0 +1,180 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const historyLines = `{"msg_id":"1","timestamp":1500000000000,"direction":"outgoing","from":"alice","to":"bob","chat_type":"chat","payload":{"bodies":[{"type":"txt","msg":"hello"}]}}
+{"msg_id":"2","timestamp":1500000001000,"direction":"outgoing","from":"bob","to":"g1","chat_type":"groupchat","payload":{"bodies":[{"type":"txt","msg":"hi"}],"ext":{"k":"v"}}}
+`
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.Bytes()
+}
+
+// handleArchives serves the archive of each hour in archives, as returned
+// by archive, from a path of its own the way Easemob's presigned URLs do.
+// Other hours have no archive.
+func handleArchives(t *testing.T, client *Client, mux *http.ServeMux, archives map[string]func(http.ResponseWriter)) {
+	mux.HandleFunc("/org/app/chatmessages/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		hour := r.URL.Path[len("/org/app/chatmessages/"):]
+		if _, ok := archives[hour]; !ok {
+			fmt.Fprint(w, `{"data":[]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"data":[{"url":"%varchives/%v.gz?signature=x"}]}`, client.BaseURL, hour)
+	})
+	mux.HandleFunc("/archives/", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("archive download sent Authorization %q", got)
+		}
+		hour := r.URL.Path[len("/archives/") : len(r.URL.Path)-len(".gz")]
+		archives[hour](w)
+	})
+}
+
+func serveBytes(b []byte) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) { w.Write(b) }
+}
+
+func TestHistoryService_ArchiveURL(t *testing.T) {
+	client, mux := setup(t)
+	hour := time.Date(2017, 7, 14, 2, 40, 0, 0, time.UTC)
+	handleArchives(t, client, mux, map[string]func(http.ResponseWriter){"2017071402": nil})
+
+	u, _, err := client.History.ArchiveURL(hour.In(time.FixedZone("CST", 8*3600)))
+	if err != nil {
+		t.Fatalf("ArchiveURL returned error: %v", err)
+	}
+	if want := client.BaseURL.String() + "archives/2017071402.gz?signature=x"; u != want {
+		t.Errorf("ArchiveURL = %q, want %q", u, want)
+	}
+
+	_, _, err = client.History.ArchiveURL(hour.Add(time.Hour))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("ArchiveURL of an hour without archive returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestHistoryService_Archive(t *testing.T) {
+	want := []*HistoryRecord{
+		{
+			MsgID: "1", Timestamp: 1500000000000, Direction: "outgoing",
+			From: "alice", To: "bob", ChatType: "chat",
+			Payload: MessagePayload{Bodies: []MessageBody{&TextMessage{Msg: "hello"}}},
+		},
+		{
+			MsgID: "2", Timestamp: 1500000001000, Direction: "outgoing",
+			From: "bob", To: "g1", ChatType: "groupchat",
+			Payload: MessagePayload{
+				Bodies: []MessageBody{&TextMessage{Msg: "hi"}},
+				Ext:    map[string]interface{}{"k": "v"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		serve func(http.ResponseWriter)
+	}{
+		{"gzip", serveBytes(gzipped(historyLines))},
+		{"plain", serveBytes([]byte(historyLines))},
+		{"gzip in transit", func(w http.ResponseWriter) {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped(historyLines))
+		}},
+	}
+	for _, tt := range tests {
+		client, mux := setup(t)
+		handleArchives(t, client, mux, map[string]func(http.ResponseWriter){"2017071402": tt.serve})
+
+		it, err := client.History.Archive(context.Background(), time.Date(2017, 7, 14, 2, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("%v: Archive returned error: %v", tt.name, err)
+		}
+		var got []*HistoryRecord
+		for it.Next() {
+			got = append(got, it.Value())
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("%v: iterating returned error: %v", tt.name, err)
+		}
+		it.Close()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: records = %+v, want %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestHistoryService_Archive_malformed(t *testing.T) {
+	client, mux := setup(t)
+	lines := historyLines + "{\"msg_id\":\"3\",\"timestamp\":\n"
+	handleArchives(t, client, mux, map[string]func(http.ResponseWriter){"2017071402": serveBytes(gzipped(lines))})
+
+	it, err := client.History.Archive(context.Background(), time.Date(2017, 7, 14, 2, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Archive returned error: %v", err)
+	}
+	defer it.Close()
+	var n int
+	for it.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("read %d records before the malformed one, want 2", n)
+	}
+	if it.Err() == nil {
+		t.Errorf("iterating a malformed archive returned no error")
+	}
+	if it.Next() {
+		t.Errorf("Next returned true after an error")
+	}
+}
+
+func TestHistoryService_Backfill(t *testing.T) {
+	client, mux := setup(t)
+	handleArchives(t, client, mux, map[string]func(http.ResponseWriter){
+		"2017071401": serveBytes(gzipped(historyLines)),
+		"2017071403": serveBytes([]byte(historyLines)),
+	})
+
+	start := time.Date(2017, 7, 14, 1, 30, 0, 0, time.UTC)
+	end := time.Date(2017, 7, 14, 4, 0, 0, 0, time.UTC)
+	var ids []string
+	err := client.History.Backfill(context.Background(), start, end, func(r *HistoryRecord) error {
+		ids = append(ids, r.MsgID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Backfill returned error: %v", err)
+	}
+	if want := []string{"1", "2", "1", "2"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Backfill visited %v, want %v", ids, want)
+	}
+
+	stop := errors.New("stop")
+	err = client.History.Backfill(context.Background(), start, end, func(r *HistoryRecord) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Backfill returned %v, want the callback's error", err)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"context"
+	"strconv"
+)
+
+// An Iterator steps through the items of a paginated list, fetching pages
+// as it goes.
+//
+//	it := client.Users.Iter(ctx, &easemob.ListOptions{Limit: 100})
+//	for it.Next() {
+//		user := it.Value()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	ctx   context.Context
+	fetch pageFunc[T]
+
+	page   []T
+	cursor string
+	last   bool
+	cur    T
+	err    error
+}
+
+// A pageFunc fetches the page at cursor, the empty string standing for the
+// first page, and returns its items along with the cursor of the next page,
+// or the empty string if it is the last one.
+type pageFunc[T any] func(ctx context.Context, cursor string) ([]T, string, error)
+
+func newIterator[T any](ctx context.Context, cursor string, fetch pageFunc[T]) *Iterator[T] {
+	return &Iterator[T]{ctx: ctx, fetch: fetch, cursor: cursor}
+}
+
+// Next advances to the next item, fetching the next page if needed. It
+// returns false when there are no more items or an error occurred.
+func (it *Iterator[T]) Next() bool {
+	var zero T
+	for len(it.page) == 0 {
+		if it.last || it.err != nil {
+			it.cur = zero
+			return false
+		}
+		page, cursor, err := it.fetch(it.ctx, it.cursor)
+		if err != nil {
+			// Keep the cursor of the failed page so the iteration can be
+			// resumed from it.
+			it.err = err
+			continue
+		}
+		it.page, it.cursor, it.last = page, cursor, cursor == ""
+	}
+	it.cur, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Value returns the current item.
+func (it *Iterator[T]) Value() T {
+	return it.cur
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Cursor returns the cursor of the page following the items fetched so far,
+// from which an interrupted iteration can be resumed.
+func (it *Iterator[T]) Cursor() string {
+	return it.cursor
+}
+
+// defaultPageSize is the page size of lists paginated by page number when
+// none is given, Easemob's own default.
+const defaultPageSize = 10
+
+// numberedPages adapts a fetch function for a list paginated by page number
+// to a pageFunc. A page shorter than size is the last one. A size of zero or
+// less means defaultPageSize.
+func numberedPages[T any](size int, fetch func(ctx context.Context, opt *PageOptions) ([]T, error)) pageFunc[T] {
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	return func(ctx context.Context, cursor string) ([]T, string, error) {
+		num := 1
+		if cursor != "" {
+			var err error
+			if num, err = strconv.Atoi(cursor); err != nil {
+				return nil, "", err
+			}
+		}
+		items, err := fetch(ctx, &PageOptions{PageNum: num, PageSize: size})
+		if err != nil || len(items) < size {
+			return items, "", err
+		}
+		return items, strconv.Itoa(num + 1), nil
+	}
+}
+
+// Iter returns an iterator over all users matching opt, following cursors
+// from opt.Cursor on. opt.Limit sets the page size.
+func (s *UsersService) Iter(ctx context.Context, opt *ListOptions) *Iterator[*User] {
+	base := ListOptions{}
+	if opt != nil {
+		base = *opt
+	}
+	return newIterator(ctx, base.Cursor, func(ctx context.Context, cursor string) ([]*User, string, error) {
+		o := base
+		o.Cursor = cursor
+		users, resp, err := s.ListAllContext(ctx, &o)
+		if err != nil {
+			return nil, "", err
+		}
+		return users, resp.Cursor, nil
+	})
+}
+
+// BlocksIter returns an iterator over the users blocked by owner, fetched
+// pageSize at a time.
+func (s *UsersService) BlocksIter(ctx context.Context, owner string, pageSize int) *Iterator[string] {
+	return newIterator(ctx, "", func(ctx context.Context, cursor string) ([]string, string, error) {
+		blocks, resp, err := s.GetBlocksPageContext(ctx, owner, &BlockListOptions{PageSize: pageSize, Cursor: cursor})
+		if err != nil {
+			return nil, "", err
+		}
+		return blocks, resp.Cursor, nil
+	})
+}
+
+// Iter returns an iterator over all groups of the app, following cursors
+// from opt.Cursor on. opt.Limit sets the page size.
+func (s *GroupService) Iter(ctx context.Context, opt *ListOptions) *Iterator[*Group] {
+	base := ListOptions{}
+	if opt != nil {
+		base = *opt
+	}
+	return newIterator(ctx, base.Cursor, func(ctx context.Context, cursor string) ([]*Group, string, error) {
+		o := base
+		o.Cursor = cursor
+		groups, resp, err := s.ListContext(ctx, &o)
+		if err != nil {
+			return nil, "", err
+		}
+		return groups, resp.Cursor, nil
+	})
+}
+
+// MembersIter returns an iterator over the members of a group, fetched
+// pageSize at a time.
+func (s *GroupService) MembersIter(ctx context.Context, groupid string, pageSize int) *Iterator[*Member] {
+	return newIterator(ctx, "", numberedPages(pageSize, func(ctx context.Context, opt *PageOptions) ([]*Member, error) {
+		members, _, err := s.MembersPageContext(ctx, groupid, opt)
+		return members, err
+	}))
+}
+
+// Iter returns an iterator over all chatrooms of the app, fetched pageSize
+// at a time.
+func (s *ChatroomService) Iter(ctx context.Context, pageSize int) *Iterator[*Chatroom] {
+	return newIterator(ctx, "", numberedPages(pageSize, func(ctx context.Context, opt *PageOptions) ([]*Chatroom, error) {
+		chatrooms, _, err := s.ListContext(ctx, opt)
+		return chatrooms, err
+	}))
+}
+
+// MembersIter returns an iterator over the members of a chatroom, fetched
+// pageSize at a time.
+func (s *ChatroomService) MembersIter(ctx context.Context, chatroomid string, pageSize int) *Iterator[*Member] {
+	return newIterator(ctx, "", numberedPages(pageSize, func(ctx context.Context, opt *PageOptions) ([]*Member, error) {
+		members, _, err := s.MembersPageContext(ctx, chatroomid, opt)
+		return members, err
+	}))
+}
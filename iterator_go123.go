@@ -0,0 +1,33 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package easemob
+
+import "iter"
+
+// All returns the remaining items of it for use with range. An error ends
+// the sequence, paired with the zero item.
+//
+//	for user, err := range client.Users.Iter(ctx, nil).All() {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (it *Iterator[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for it.Next() {
+			if !yield(it.Value(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
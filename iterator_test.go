@@ -0,0 +1,87 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIterator_errorKeepsCursor(t *testing.T) {
+	errPage := errors.New("page 2 failed")
+	fail := true
+	fetch := func(ctx context.Context, cursor string) ([]string, string, error) {
+		switch cursor {
+		case "":
+			return []string{"a", "b"}, "c1", nil
+		case "c1":
+			if fail {
+				return nil, "", errPage
+			}
+			return []string{"c"}, "", nil
+		}
+		t.Fatalf("fetch(%q): unexpected cursor", cursor)
+		return nil, "", nil
+	}
+
+	it := newIterator(context.Background(), "", fetch)
+	var got []string
+	for it.Next() {
+		got = append(got, it.Value())
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+	if it.Err() != errPage {
+		t.Errorf("Err() = %v, want %v", it.Err(), errPage)
+	}
+	if it.Cursor() != "c1" {
+		t.Errorf("Cursor() = %q, want %q", it.Cursor(), "c1")
+	}
+	if it.Next() {
+		t.Error("Next() after an error returned true")
+	}
+
+	fail = false
+	it = newIterator(context.Background(), it.Cursor(), fetch)
+	got = nil
+	for it.Next() {
+		got = append(got, it.Value())
+	}
+	if it.Err() != nil {
+		t.Fatalf("resumed iteration: %v", it.Err())
+	}
+	if want := []string{"c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resumed items = %v, want %v", got, want)
+	}
+	if it.Cursor() != "" {
+		t.Errorf("Cursor() at the end = %q, want empty", it.Cursor())
+	}
+}
+
+func TestNumberedPages_defaultSize(t *testing.T) {
+	var sizes []int
+	fetch := numberedPages(0, func(ctx context.Context, opt *PageOptions) ([]int, error) {
+		sizes = append(sizes, opt.PageSize)
+		if opt.PageNum == 1 {
+			return make([]int, defaultPageSize), nil
+		}
+		return []int{0}, nil
+	})
+	it := newIterator(context.Background(), "", fetch)
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if it.Err() != nil || n != defaultPageSize+1 {
+		t.Fatalf("got %d items, err %v; want %d", n, it.Err(), defaultPageSize+1)
+	}
+	if want := []int{defaultPageSize, defaultPageSize}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("page sizes = %v, want %v", sizes, want)
+	}
+}
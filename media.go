@@ -0,0 +1,329 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	_ "image/gif"  // register decoder for SendImage
+	_ "image/jpeg" // register decoder for SendImage
+	_ "image/png"  // register decoder for SendImage
+	"io"
+	"math"
+	"net/http"
+)
+
+// probeSize is how much of a media file is held back to derive its
+// metadata from.
+const probeSize = 256 << 10
+
+// media is an uploaded media file and what could be learned about it on
+// the way.
+type media struct {
+	url      string
+	filename string
+	secret   string
+	size     int64
+
+	// head is the beginning of the file, up to probeSize bytes.
+	head []byte
+
+	// all reads the whole file again, or is nil if r can't be reread.
+	all *io.SectionReader
+}
+
+// SendImage uploads an image and sends it to a target in one call. The
+// dimensions of GIF, JPEG and PNG images are included in the message.
+func (s *MessagesService) SendImage(from string, to Target, r io.Reader, filename string) (SendResult, *Response, error) {
+	return s.SendImageContext(context.Background(), from, to, r, filename)
+}
+
+// SendImageContext is like SendImage but sends the requests with ctx.
+func (s *MessagesService) SendImageContext(ctx context.Context, from string, to Target, r io.Reader, filename string) (SendResult, *Response, error) {
+	m, resp, err := s.upload(ctx, r, filename)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	body := &ImageMessage{URL: m.url, Filename: m.filename, Secret: m.secret}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(m.head)); err == nil {
+		body.Size = &ImageSize{Width: cfg.Width, Height: cfg.Height}
+	}
+	return s.SendContext(ctx, from, to, body, nil)
+}
+
+// SendVoice uploads an audio clip and sends it to a target in one call. The
+// duration of AMR, WAV and MP4 audio is included in the message.
+func (s *MessagesService) SendVoice(from string, to Target, r io.Reader, filename string) (SendResult, *Response, error) {
+	return s.SendVoiceContext(context.Background(), from, to, r, filename)
+}
+
+// SendVoiceContext is like SendVoice but sends the requests with ctx.
+func (s *MessagesService) SendVoiceContext(ctx context.Context, from string, to Target, r io.Reader, filename string) (SendResult, *Response, error) {
+	m, resp, err := s.upload(ctx, r, filename)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	body := &VoiceMessage{
+		URL:        m.url,
+		Filename:   m.filename,
+		Secret:     m.secret,
+		Length:     m.duration(),
+		FileLength: m.size,
+	}
+	return s.SendContext(ctx, from, to, body, nil)
+}
+
+// SendVideo uploads a video, and its thumbnail if thumb is not nil, and
+// sends it to a target in one call. The duration of MP4 video is included
+// in the message. The type of the thumbnail is detected from its content.
+func (s *MessagesService) SendVideo(from string, to Target, r io.Reader, filename string, thumb io.Reader) (SendResult, *Response, error) {
+	return s.SendVideoContext(context.Background(), from, to, r, filename, thumb)
+}
+
+// SendVideoContext is like SendVideo but sends the requests with ctx.
+func (s *MessagesService) SendVideoContext(ctx context.Context, from string, to Target, r io.Reader, filename string, thumb io.Reader) (SendResult, *Response, error) {
+	m, resp, err := s.upload(ctx, r, filename)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	body := &VideoMessage{
+		URL:        m.url,
+		Filename:   m.filename,
+		Secret:     m.secret,
+		Length:     m.duration(),
+		FileLength: m.size,
+	}
+	if thumb != nil {
+		br := bufio.NewReaderSize(thumb, 512)
+		head, _ := br.Peek(512)
+		up, resp, err := s.client.Files.UploadContext(ctx, br, thumbFilename(head), nil)
+		if err != nil {
+			return nil, resp, err
+		}
+		body.Thumb = s.client.Files.URL(up.UUID)
+		body.ThumbSecret = up.ShareSecret
+	}
+	return s.SendContext(ctx, from, to, body, nil)
+}
+
+// SendFile uploads a file and sends it to a target in one call.
+func (s *MessagesService) SendFile(from string, to Target, r io.Reader, filename string) (SendResult, *Response, error) {
+	return s.SendFileContext(context.Background(), from, to, r, filename)
+}
+
+// SendFileContext is like SendFile but sends the requests with ctx.
+func (s *MessagesService) SendFileContext(ctx context.Context, from string, to Target, r io.Reader, filename string) (SendResult, *Response, error) {
+	m, resp, err := s.upload(ctx, r, filename)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	body := &FileMessage{URL: m.url, Filename: m.filename, Secret: m.secret, FileLength: m.size}
+	return s.SendContext(ctx, from, to, body, nil)
+}
+
+// thumbExts maps the image types detected by http.DetectContentType to the
+// extension given to an uploaded thumbnail, which sets its content type.
+var thumbExts = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
+// thumbFilename returns the name a thumbnail beginning with head is
+// uploaded as. A thumbnail of unknown type is sent without an extension,
+// and so as application/octet-stream.
+func thumbFilename(head []byte) string {
+	return "thumb" + thumbExts[http.DetectContentType(head)]
+}
+
+// upload uploads the content of r, keeping its beginning for inspection.
+func (s *MessagesService) upload(ctx context.Context, r io.Reader, filename string) (*media, *Response, error) {
+	var (
+		ra    io.ReaderAt
+		start int64
+	)
+	if rs, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		if off, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			ra, start = rs, off
+		}
+	}
+
+	br := bufio.NewReaderSize(r, probeSize)
+	head, err := br.Peek(probeSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, nil, err
+	}
+	head = append([]byte(nil), head...)
+
+	cr := &progressWriter{w: io.Discard}
+	up, resp, err := s.client.Files.UploadContext(ctx, io.TeeReader(br, cr), filename, nil)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	m := &media{
+		url:      s.client.Files.URL(up.UUID),
+		filename: filename,
+		secret:   up.ShareSecret,
+		size:     cr.n,
+		head:     head,
+	}
+	if ra != nil {
+		m.all = io.NewSectionReader(ra, start, cr.n)
+	}
+	return m, resp, nil
+}
+
+// duration returns the play time of an audio or video file in whole
+// seconds, rounded up, or 0 if its format isn't recognized.
+func (m *media) duration() int {
+	var secs float64
+	switch {
+	case bytes.HasPrefix(m.head, []byte("#!AMR")):
+		secs = amrDuration(m.head, m.size)
+	case len(m.head) >= 12 && string(m.head[:4]) == "RIFF" && string(m.head[8:12]) == "WAVE":
+		secs = wavDuration(m.head, m.size)
+	case len(m.head) >= 8 && string(m.head[4:8]) == "ftyp":
+		var r io.ReaderAt = bytes.NewReader(m.head)
+		size := int64(len(m.head))
+		if m.all != nil {
+			r, size = m.all, m.size
+		}
+		secs = mp4Duration(r, size)
+	}
+	if secs <= 0 {
+		return 0
+	}
+	return int(math.Ceil(secs))
+}
+
+// Frame sizes of AMR narrowband and wideband, by mode, without the frame
+// header byte.
+var (
+	amrNBFrameSizes = [16]int{12, 13, 15, 17, 19, 20, 26, 31, 5}
+	amrWBFrameSizes = [16]int{17, 23, 32, 36, 40, 46, 50, 58, 60, 5}
+)
+
+// amrDuration returns the duration of a single channel AMR file of size
+// bytes, assuming every frame has the mode of the first one. AMR frames
+// hold 20ms of audio.
+func amrDuration(head []byte, size int64) float64 {
+	magic, sizes := "#!AMR\n", amrNBFrameSizes
+	if bytes.HasPrefix(head, []byte("#!AMR-WB\n")) {
+		magic, sizes = "#!AMR-WB\n", amrWBFrameSizes
+	}
+	if len(head) <= len(magic) {
+		return 0
+	}
+	frame := sizes[head[len(magic)]>>3&0x0f]
+	if frame == 0 {
+		return 0
+	}
+	frames := (size - int64(len(magic))) / int64(frame+1)
+	return float64(frames) * 0.02
+}
+
+// wavDuration returns the duration of a WAV file of size bytes from its
+// fmt and data chunk headers.
+func wavDuration(head []byte, size int64) float64 {
+	var byteRate uint32
+	for off := 12; off+8 <= len(head); {
+		id := string(head[off : off+4])
+		n := binary.LittleEndian.Uint32(head[off+4 : off+8])
+		switch id {
+		case "fmt ":
+			if off+20 > len(head) {
+				return 0
+			}
+			byteRate = binary.LittleEndian.Uint32(head[off+16 : off+20])
+		case "data":
+			if byteRate == 0 {
+				return 0
+			}
+			data := int64(n)
+			if rest := size - int64(off+8); n == 0xffffffff || data > rest {
+				data = rest
+			}
+			return float64(data) / float64(byteRate)
+		}
+		off += 8 + int(n) + int(n&1)
+	}
+	return 0
+}
+
+// mp4Duration returns the duration of an MP4 or QuickTime file from its
+// movie header box, found within the first size bytes of r.
+func mp4Duration(r io.ReaderAt, size int64) float64 {
+	moov, moovSize := findBox(r, 0, size, "moov")
+	if moov < 0 {
+		return 0
+	}
+	mvhd, _ := findBox(r, moov, moov+moovSize, "mvhd")
+	if mvhd < 0 {
+		return 0
+	}
+
+	var buf [32]byte
+	if _, err := r.ReadAt(buf[:], mvhd); err != nil && err != io.EOF {
+		return 0
+	}
+	var timescale uint32
+	var duration uint64
+	if buf[0] == 1 {
+		timescale = binary.BigEndian.Uint32(buf[20:24])
+		duration = binary.BigEndian.Uint64(buf[24:32])
+	} else {
+		timescale = binary.BigEndian.Uint32(buf[12:16])
+		duration = uint64(binary.BigEndian.Uint32(buf[16:20]))
+	}
+	if timescale == 0 {
+		return 0
+	}
+	return float64(duration) / float64(timescale)
+}
+
+// findBox looks for a box of type typ among the boxes between off and end,
+// returning the offset and size of its content, or -1 if there is none.
+func findBox(r io.ReaderAt, off, end int64, typ string) (int64, int64) {
+	var hdr [16]byte
+	for off+8 <= end {
+		if _, err := r.ReadAt(hdr[:8], off); err != nil {
+			return -1, 0
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		hdrLen := int64(8)
+		switch size {
+		case 0:
+			size = end - off
+		case 1:
+			if _, err := r.ReadAt(hdr[8:16], off+8); err != nil {
+				return -1, 0
+			}
+			size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			hdrLen = 16
+		}
+		if size < hdrLen {
+			return -1, 0
+		}
+		if string(hdr[4:8]) == typ {
+			return off + hdrLen, size - hdrLen
+		}
+		off += size
+	}
+	return -1, 0
+}
@@ -0,0 +1,290 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// amrFile returns a narrowband AMR file of frames 12.2 kbit/s frames, or a
+// wideband one of 23.85 kbit/s frames if wb is set.
+func amrFile(frames int, wb bool) []byte {
+	magic, mode, size := "#!AMR\n", byte(7), 31
+	if wb {
+		magic, mode, size = "#!AMR-WB\n", 8, 60
+	}
+	b := []byte(magic)
+	for i := 0; i < frames; i++ {
+		b = append(b, mode<<3|0x04)
+		b = append(b, make([]byte, size)...)
+	}
+	return b
+}
+
+// wavFile returns a 16-bit mono WAV file at rate samples per second holding
+// samples samples. A dataSize other than 0 is written in place of the real
+// size of the data chunk, as streaming encoders do.
+func wavFile(rate, samples int, dataSize uint32) []byte {
+	var b bytes.Buffer
+	data := samples * 2
+	le := binary.LittleEndian
+	b.WriteString("RIFF")
+	binary.Write(&b, le, uint32(36+data))
+	b.WriteString("WAVE")
+	b.WriteString("fmt ")
+	binary.Write(&b, le, uint32(16))
+	binary.Write(&b, le, uint16(1))      // PCM
+	binary.Write(&b, le, uint16(1))      // channels
+	binary.Write(&b, le, uint32(rate))   // sample rate
+	binary.Write(&b, le, uint32(rate*2)) // byte rate
+	binary.Write(&b, le, uint16(2))      // block align
+	binary.Write(&b, le, uint16(16))     // bits per sample
+	b.WriteString("LIST")                // a chunk to skip
+	binary.Write(&b, le, uint32(3))
+	b.Write([]byte{'a', 'b', 'c', 0}) // odd size, padded
+	b.WriteString("data")
+	if dataSize == 0 {
+		dataSize = uint32(data)
+	}
+	binary.Write(&b, le, dataSize)
+	b.Write(make([]byte, data))
+	return b.Bytes()
+}
+
+// box returns an MP4 box of type typ holding content.
+func box(typ string, content ...[]byte) []byte {
+	c := bytes.Join(content, nil)
+	b := make([]byte, 8, 8+len(c))
+	binary.BigEndian.PutUint32(b, uint32(8+len(c)))
+	copy(b[4:], typ)
+	return append(b, c...)
+}
+
+// mvhd returns a version 0 movie header box.
+func mvhd(timescale, duration uint32) []byte {
+	c := make([]byte, 100)
+	binary.BigEndian.PutUint32(c[12:], timescale)
+	binary.BigEndian.PutUint32(c[16:], duration)
+	return box("mvhd", c)
+}
+
+// mvhd1 returns a version 1 movie header box.
+func mvhd1(timescale uint32, duration uint64) []byte {
+	c := make([]byte, 112)
+	c[0] = 1
+	binary.BigEndian.PutUint32(c[20:], timescale)
+	binary.BigEndian.PutUint64(c[24:], duration)
+	return box("mvhd", c)
+}
+
+// mp4File returns an MP4 file with the given movie header, its moov box
+// following an mdat box of mdat bytes.
+func mp4File(header []byte, mdat int) []byte {
+	ftyp := box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2mp41"))
+	return bytes.Join([][]byte{ftyp, box("mdat", make([]byte, mdat)), box("moov", header)}, nil)
+}
+
+// duration returns the duration of file as found by media.duration, with
+// only its first head bytes held back unless all is set.
+func duration(file []byte, head int, all bool) int {
+	if head > len(file) {
+		head = len(file)
+	}
+	m := &media{head: file[:head], size: int64(len(file))}
+	if all {
+		m.all = io.NewSectionReader(bytes.NewReader(file), 0, int64(len(file)))
+	}
+	return m.duration()
+}
+
+func TestMedia_duration(t *testing.T) {
+	tests := []struct {
+		name string
+		file []byte
+		all  bool
+		want int
+	}{
+		{"AMR-NB", amrFile(250, false), false, 5},
+		{"AMR-WB", amrFile(101, true), false, 3},
+		{"AMR without frames", amrFile(0, false), false, 0},
+		{"WAV", wavFile(8000, 16000, 0), false, 2},
+		{"WAV, rounded up", wavFile(8000, 8001, 0), false, 2},
+		{"streamed WAV", wavFile(8000, 24000, 0xffffffff), false, 3},
+		{"MP4", mp4File(mvhd(1000, 3500), 0), false, 4},
+		{"MP4 version 1", mp4File(mvhd1(90000, 900000), 0), false, 10},
+		{"MP4 moov past the probe", mp4File(mvhd(600, 6000), probeSize), true, 10},
+		{"MP4 moov past the probe, not seekable", mp4File(mvhd(600, 6000), probeSize), false, 0},
+		{"MP4 zero timescale", mp4File(mvhd(0, 6000), 0), false, 0},
+		{"MP4 without moov", box("ftyp", []byte("isom")), false, 0},
+		{"MP4 without mvhd", mp4File(box("trak"), 0), false, 0},
+		{"unknown", []byte("ID3\x03\x00\x00\x00\x00\x00\x00"), false, 0},
+		{"empty", nil, false, 0},
+	}
+	for _, tt := range tests {
+		if got := duration(tt.file, probeSize, tt.all); got != tt.want {
+			t.Errorf("%v: duration = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestMedia_duration_malformed feeds truncated and corrupted files to the
+// parsers, which must not panic.
+func TestMedia_duration_malformed(t *testing.T) {
+	files := [][]byte{
+		amrFile(3, false),
+		amrFile(3, true),
+		wavFile(8000, 10, 0),
+		wavFile(8000, 10, 0xffffffff),
+		mp4File(mvhd(1000, 3500), 16),
+		mp4File(mvhd1(1000, 3500), 16),
+	}
+
+	// A box with a 64-bit size beyond the file, and one smaller than its
+	// own header.
+	huge := box("moov")
+	binary.BigEndian.PutUint32(huge, 1)
+	huge = append(huge, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	files = append(files,
+		append(box("ftyp", []byte("isom")), huge...),
+		append(box("ftyp", []byte("isom")), 0, 0, 0, 4, 'm', 'o', 'o', 'v'),
+		append(box("ftyp", []byte("isom")), box("moov", []byte{0, 0, 0, 1, 'm', 'v', 'h', 'd'})...),
+	)
+
+	// A WAV file whose chunks claim the largest sizes.
+	wav := wavFile(8000, 10, 0)
+	for _, off := range []int{16, 40} {
+		w := append([]byte(nil), wav...)
+		binary.LittleEndian.PutUint32(w[off:], 0xffffffff)
+		files = append(files, w)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for _, prefix := range []string{"#!AMR\n", "#!AMR-WB\n", "RIFF\x00\x00\x00\x00WAVE", "\x00\x00\x00\x10ftyp"} {
+		for i := 0; i < 200; i++ {
+			garbage := make([]byte, rnd.Intn(256))
+			rnd.Read(garbage)
+			files = append(files, append([]byte(prefix), garbage...))
+		}
+	}
+
+	for _, f := range files {
+		for n := 0; n <= len(f); n++ {
+			duration(f[:n], probeSize, false)
+			duration(f, n, false)
+			duration(f[:n], n, true)
+		}
+	}
+}
+
+func TestThumbFilename(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	tests := []struct {
+		head []byte
+		want string
+	}{
+		{buf.Bytes(), "thumb.png"},
+		{[]byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), "thumb.jpg"},
+		{[]byte("GIF89a"), "thumb.gif"},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "thumb.webp"},
+		{[]byte("not an image"), "thumb"},
+		{nil, "thumb"},
+	}
+	for _, tt := range tests {
+		if got := thumbFilename(tt.head); got != tt.want {
+			t.Errorf("thumbFilename(%q) = %q, want %q", tt.head, got, tt.want)
+		}
+	}
+}
+
+// handleMessages serves the messages endpoint on mux and returns the
+// bodies of the messages sent.
+func handleMessages(t *testing.T, mux *http.ServeMux) *[]map[string]interface{} {
+	var sent []map[string]interface{}
+	mux.HandleFunc("/org/app/messages", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		var put struct {
+			Target []string               `json:"target"`
+			Msg    map[string]interface{} `json:"msg"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&put); err != nil {
+			t.Errorf("decoding message: %v", err)
+		}
+		sent = append(sent, put.Msg)
+		result := make(map[string]string)
+		for _, id := range put.Target {
+			result[id] = "success"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": result})
+	})
+	return &sent
+}
+
+func TestMessagesService_SendVoice(t *testing.T) {
+	client, mux := setup(t)
+	files := handleFiles(t, mux)
+	sent := handleMessages(t, mux)
+
+	// Larger than the probe, through a reader that can't seek, so that the
+	// bytes held back must be sent along with the rest.
+	wav := wavFile(16000, 10*16000, 0)
+	if len(wav) <= probeSize {
+		t.Fatalf("test file of %d bytes is within the probe size", len(wav))
+	}
+	if _, _, err := client.Messages.SendVoice("alice", ToUsers("bob"), ioutil.NopCloser(bytes.NewReader(wav)), "hello.wav"); err != nil {
+		t.Fatalf("SendVoice returned error: %v", err)
+	}
+	if !bytes.Equal(files.files["file-1"], wav) {
+		t.Errorf("uploaded %d bytes, want the %d of the file", len(files.files["file-1"]), len(wav))
+	}
+	if len(*sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(*sent))
+	}
+	msg := (*sent)[0]
+	if msg["type"] != "audio" || msg["length"] != 10.0 || msg["file_length"] != float64(len(wav)) {
+		t.Errorf("sent %v", msg)
+	}
+	if url, _ := msg["url"].(string); !strings.HasSuffix(url, "/org/app/chatfiles/file-1") {
+		t.Errorf("sent url %q", url)
+	}
+}
+
+func TestMessagesService_SendVideo_thumbnail(t *testing.T) {
+	client, mux := setup(t)
+	files := handleFiles(t, mux)
+	sent := handleMessages(t, mux)
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	img.Set(0, 0, color.White)
+	var thumb bytes.Buffer
+	png.Encode(&thumb, img)
+	video := mp4File(mvhd(1000, 2500), 100)
+
+	if _, _, err := client.Messages.SendVideo("alice", ToUsers("bob"), bytes.NewReader(video), "clip.mp4", &thumb); err != nil {
+		t.Fatalf("SendVideo returned error: %v", err)
+	}
+	if ct := files.types["file-2"]; ct != "image/png" {
+		t.Errorf("thumbnail uploaded as %q, want image/png", ct)
+	}
+	msg := (*sent)[0]
+	if msg["length"] != 3.0 {
+		t.Errorf("sent length %v, want 3", msg["length"])
+	}
+	if url, _ := msg["thumb"].(string); !strings.HasSuffix(url, "/chatfiles/file-2") {
+		t.Errorf("sent thumb %q", url)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"encoding/json"
+)
+
+// Message types, as found in the "type" field of a message body.
+const (
+	TypeText     = "txt"
+	TypeImage    = "img"
+	TypeVoice    = "audio"
+	TypeVideo    = "video"
+	TypeFile     = "file"
+	TypeLocation = "loc"
+	TypeCommand  = "cmd"
+	TypeCustom   = "custom"
+)
+
+// A MessageBody is the content of a message: a *TextMessage, *ImageMessage,
+// *VoiceMessage, *VideoMessage, *FileMessage, *LocationMessage,
+// *CommandMessage or *CustomMessage, or an *UnknownMessage for decoded
+// bodies of other types.
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/basics/messages
+type MessageBody interface {
+	MessageType() string
+}
+
+// TextMessage is a text message.
+type TextMessage struct {
+	Msg string `json:"msg"`
+}
+
+// ImageMessage is an image uploaded to the chatfiles endpoint.
+type ImageMessage struct {
+	URL      string     `json:"url"`
+	Filename string     `json:"filename"`
+	Secret   string     `json:"secret,omitempty"`
+	Size     *ImageSize `json:"size,omitempty"`
+}
+
+// ImageSize holds the dimensions of an image in pixels.
+type ImageSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// VoiceMessage is an audio clip uploaded to the chatfiles endpoint.
+type VoiceMessage struct {
+	URL        string `json:"url"`
+	Filename   string `json:"filename"`
+	Secret     string `json:"secret,omitempty"`
+	Length     int    `json:"length"` // duration in seconds
+	FileLength int64  `json:"file_length,omitempty"`
+}
+
+// VideoMessage is a video uploaded to the chatfiles endpoint, along with its
+// thumbnail.
+type VideoMessage struct {
+	URL         string `json:"url"`
+	Filename    string `json:"filename"`
+	Secret      string `json:"secret,omitempty"`
+	Thumb       string `json:"thumb,omitempty"`
+	ThumbSecret string `json:"thumb_secret,omitempty"`
+	Length      int    `json:"length"` // duration in seconds
+	FileLength  int64  `json:"file_length,omitempty"`
+}
+
+// FileMessage is a file uploaded to the chatfiles endpoint.
+type FileMessage struct {
+	URL        string `json:"url"`
+	Filename   string `json:"filename"`
+	Secret     string `json:"secret,omitempty"`
+	FileLength int64  `json:"file_length,omitempty"`
+}
+
+// LocationMessage is a geographic location.
+type LocationMessage struct {
+	Lat  float64 `json:"lat"`
+	Lng  float64 `json:"lng"`
+	Addr string  `json:"addr"`
+}
+
+// CommandMessage is a transparent command, delivered to the app but never
+// shown to the user.
+type CommandMessage struct {
+	Action string `json:"action"`
+}
+
+// CustomMessage is a message whose meaning is defined by the app.
+type CustomMessage struct {
+	CustomEvent string            `json:"customEvent"`
+	CustomExts  map[string]string `json:"customExts,omitempty"`
+}
+
+// UnknownMessage is a decoded message body of a type this package does not
+// model. Raw holds the body as received, and is encoded as is; without it,
+// the body encodes as its type alone.
+type UnknownMessage struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+func (m *TextMessage) MessageType() string     { return TypeText }
+func (m *ImageMessage) MessageType() string    { return TypeImage }
+func (m *VoiceMessage) MessageType() string    { return TypeVoice }
+func (m *VideoMessage) MessageType() string    { return TypeVideo }
+func (m *FileMessage) MessageType() string     { return TypeFile }
+func (m *LocationMessage) MessageType() string { return TypeLocation }
+func (m *CommandMessage) MessageType() string  { return TypeCommand }
+func (m *CustomMessage) MessageType() string   { return TypeCustom }
+func (m *UnknownMessage) MessageType() string  { return m.Type }
+
+// The bodies are sent with their type alongside their fields.
+
+func (m *TextMessage) MarshalJSON() ([]byte, error) {
+	type body TextMessage
+	return marshalBody(m, (*body)(m))
+}
+
+func (m *ImageMessage) MarshalJSON() ([]byte, error) {
+	type body ImageMessage
+	return marshalBody(m, (*body)(m))
+}
+
+func (m *VoiceMessage) MarshalJSON() ([]byte, error) {
+	type body VoiceMessage
+	return marshalBody(m, (*body)(m))
+}
+
+func (m *VideoMessage) MarshalJSON() ([]byte, error) {
+	type body VideoMessage
+	return marshalBody(m, (*body)(m))
+}
+
+func (m *FileMessage) MarshalJSON() ([]byte, error) {
+	type body FileMessage
+	return marshalBody(m, (*body)(m))
+}
+
+func (m *LocationMessage) MarshalJSON() ([]byte, error) {
+	type body LocationMessage
+	return marshalBody(m, (*body)(m))
+}
+
+func (m *CommandMessage) MarshalJSON() ([]byte, error) {
+	type body CommandMessage
+	return marshalBody(m, (*body)(m))
+}
+
+func (m *CustomMessage) MarshalJSON() ([]byte, error) {
+	type body CustomMessage
+	return marshalBody(m, (*body)(m))
+}
+
+func (m *UnknownMessage) MarshalJSON() ([]byte, error) {
+	if len(m.Raw) == 0 {
+		return json.Marshal(map[string]string{"type": m.Type})
+	}
+	return m.Raw, nil
+}
+
+// marshalBody encodes the fields of v, a MessageBody converted to a type
+// without a MarshalJSON method, together with the type of m.
+func marshalBody(m MessageBody, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["type"], _ = json.Marshal(m.MessageType())
+	return json.Marshal(fields)
+}
+
+// DecodeMessageBody decodes a message body according to its "type" field.
+func DecodeMessageBody(data []byte) (MessageBody, error) {
+	var t struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+
+	var m MessageBody
+	switch t.Type {
+	case TypeText:
+		m = new(TextMessage)
+	case TypeImage:
+		m = new(ImageMessage)
+	case TypeVoice:
+		m = new(VoiceMessage)
+	case TypeVideo:
+		m = new(VideoMessage)
+	case TypeFile:
+		m = new(FileMessage)
+	case TypeLocation:
+		m = new(LocationMessage)
+	case TypeCommand:
+		m = new(CommandMessage)
+	case TypeCustom:
+		m = new(CustomMessage)
+	default:
+		return &UnknownMessage{Type: t.Type, Raw: append(json.RawMessage(nil), data...)}, nil
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MessagePayload is the content of a delivered message as found in chat
+// history records and callbacks: its bodies and extension attributes.
+type MessagePayload struct {
+	Bodies []MessageBody          `json:"bodies"`
+	Ext    map[string]interface{} `json:"ext,omitempty"`
+}
+
+// UnmarshalJSON decodes the bodies of a payload into their typed form.
+func (p *MessagePayload) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Bodies []json.RawMessage      `json:"bodies"`
+		Ext    map[string]interface{} `json:"ext"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	p.Bodies = make([]MessageBody, len(v.Bodies))
+	for i, raw := range v.Bodies {
+		body, err := DecodeMessageBody(raw)
+		if err != nil {
+			return err
+		}
+		p.Bodies[i] = body
+	}
+	p.Ext = v.Ext
+	return nil
+}
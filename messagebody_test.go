@@ -0,0 +1,82 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMessageBody_roundTrip(t *testing.T) {
+	bodies := []MessageBody{
+		&TextMessage{Msg: "hello"},
+		&LocationMessage{Lat: 39.9, Lng: 116.4, Addr: "Beijing"},
+		&CommandMessage{Action: "sync"},
+		&CustomMessage{CustomEvent: "gift", CustomExts: map[string]string{"id": "1"}},
+	}
+	for _, m := range bodies {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal(%#v) returned error: %v", m, err)
+		}
+		got, err := DecodeMessageBody(data)
+		if err != nil {
+			t.Fatalf("DecodeMessageBody(%s) returned error: %v", data, err)
+		}
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("DecodeMessageBody(%s) = %#v, want %#v", data, got, m)
+		}
+	}
+}
+
+func TestUnknownMessage_MarshalJSON(t *testing.T) {
+	raw := `{"type":"poll","question":"?"}`
+	m, err := DecodeMessageBody([]byte(raw))
+	if err != nil {
+		t.Fatalf("DecodeMessageBody returned error: %v", err)
+	}
+	if m.MessageType() != "poll" {
+		t.Errorf("MessageType() = %q, want %q", m.MessageType(), "poll")
+	}
+	if data, err := json.Marshal(m); err != nil || string(data) != raw {
+		t.Errorf("Marshal = %s, %v; want %s", data, err, raw)
+	}
+
+	data, err := json.Marshal(&UnknownMessage{Type: "poll"})
+	if err != nil {
+		t.Fatalf("Marshal without Raw returned error: %v", err)
+	}
+	if want := `{"type":"poll"}`; string(data) != want {
+		t.Errorf("Marshal without Raw = %s, want %s", data, want)
+	}
+}
+
+func TestMessagesService_Send(t *testing.T) {
+	client, mux := setup(t)
+	mux.HandleFunc("/org/app/messages", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		testJSONBody(t, r, `{
+			"target_type": "chatgroups",
+			"target": ["g1", "g2"],
+			"msg": {"type": "loc", "lat": 39.9, "lng": 116.4, "addr": "Beijing"},
+			"from": "alice",
+			"ext": {"k": "v"}
+		}`)
+		fmt.Fprint(w, `{"data":{"g1":"success","g2":"group not found"}}`)
+	})
+
+	body := &LocationMessage{Lat: 39.9, Lng: 116.4, Addr: "Beijing"}
+	result, _, err := client.Messages.Send("alice", ToChatgroups("g1", "g2"), body, map[string]interface{}{"k": "v"})
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if failed := result.Failed(); !reflect.DeepEqual(failed, []string{"g2"}) {
+		t.Errorf("Failed() = %v, want [g2]", failed)
+	}
+}
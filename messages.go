@@ -6,6 +6,7 @@
 package easemob
 
 import (
+  "context"
   "net/http"
 )
 
@@ -20,12 +21,72 @@ type MessagesService struct {
 }
 
 /**
- * Send text message to users
+ * SendResult maps each target of a message to the outcome of sending to it,
+ * "success" or the reason it failed.
+ */
+type SendResult map[string]string
+
+/**
+ * Failed returns the targets the message could not be sent to.
+ */
+func (r SendResult) Failed() []string {
+  var failed []string
+  for target, status := range r {
+    if status != "success" {
+      failed = append(failed, target)
+    }
+  }
+  return failed
+}
+
+/**
+ * Target is the recipients of a message: users, chatgroups or chatrooms.
+ */
+type Target struct {
+  Type string
+  IDs  []string
+}
+
+/**
+ * ToUsers targets users by username.
+ */
+func ToUsers(usernames ...string) Target {
+  return Target{Type: "users", IDs: usernames}
+}
+
+/**
+ * ToChatgroups targets groups by group id.
+ */
+func ToChatgroups(groupids ...string) Target {
+  return Target{Type: "chatgroups", IDs: groupids}
+}
+
+/**
+ * ToChatrooms targets chatrooms by chatroom id.
+ */
+func ToChatrooms(chatroomids ...string) Target {
+  return Target{Type: "chatrooms", IDs: chatroomids}
+}
+
+/**
+ * Send a message of any type
  *
- * http://docs.easemob.com/doku.php?id=start:100serverintegration:50messages#发送文本消息
+ * :param From: sender shown for the message, admin if empty
+ *
+ * :param Ext: extension attributes defined by the app, may be nil
+ *
+ * http://docs-im.easemob.com/im/server/basics/messages
  */
-func (s *MessagesService) SendTextMessagesToUsers(from string, text string,
-  userIds ...string) (*Response, error) {
+func (s *MessagesService) Send(from string, to Target, body MessageBody,
+  ext map[string]interface{}) (SendResult, *Response, error) {
+  return s.SendContext(context.Background(), from, to, body, ext)
+}
+
+/**
+ * SendContext is like Send but sends the request with ctx.
+ */
+func (s *MessagesService) SendContext(ctx context.Context, from string, to Target,
+  body MessageBody, ext map[string]interface{}) (SendResult, *Response, error) {
 
   var (
     err        error
@@ -36,19 +97,94 @@ func (s *MessagesService) SendTextMessagesToUsers(from string, text string,
   )
 
   putOptions = &MessagePutOptions{
-    TargetType: "users",
-    Target:     userIds,
-    Msg:        &MessageType{Type: "txt", Msg: text},
+    TargetType: to.Type,
+    Target:     to.IDs,
+    Msg:        body,
     From:       from,
+    Extend:     ext,
   }
 
   path = "messages"
 
-  req, err = s.client.NewRequest("POST", path, putOptions)
+  req, err = s.client.NewRequestContext(ctx, "POST", path, putOptions)
   if err != nil {
-    return nil, err
+    return nil, nil, err
   }
 
   resp, err = s.client.Do(req)
-  return resp, err
+  if err != nil {
+    return nil, resp, err
+  }
+
+  var result SendResult
+  err = resp.decodeData(&result)
+  return result, resp, err
+}
+
+/**
+ * Send a message to users
+ */
+func (s *MessagesService) SendToUsers(from string, body MessageBody,
+  usernames ...string) (SendResult, *Response, error) {
+  return s.SendContext(context.Background(), from, ToUsers(usernames...), body, nil)
+}
+
+/**
+ * SendToUsersContext is like SendToUsers but sends the request with ctx.
+ */
+func (s *MessagesService) SendToUsersContext(ctx context.Context, from string,
+  body MessageBody, usernames ...string) (SendResult, *Response, error) {
+  return s.SendContext(ctx, from, ToUsers(usernames...), body, nil)
+}
+
+/**
+ * Send a message to groups
+ */
+func (s *MessagesService) SendToChatgroups(from string, body MessageBody,
+  groupids ...string) (SendResult, *Response, error) {
+  return s.SendContext(context.Background(), from, ToChatgroups(groupids...), body, nil)
+}
+
+/**
+ * SendToChatgroupsContext is like SendToChatgroups but sends the request with
+ * ctx.
+ */
+func (s *MessagesService) SendToChatgroupsContext(ctx context.Context, from string,
+  body MessageBody, groupids ...string) (SendResult, *Response, error) {
+  return s.SendContext(ctx, from, ToChatgroups(groupids...), body, nil)
+}
+
+/**
+ * Send a message to chatrooms
+ */
+func (s *MessagesService) SendToChatrooms(from string, body MessageBody,
+  chatroomids ...string) (SendResult, *Response, error) {
+  return s.SendContext(context.Background(), from, ToChatrooms(chatroomids...), body, nil)
+}
+
+/**
+ * SendToChatroomsContext is like SendToChatrooms but sends the request with
+ * ctx.
+ */
+func (s *MessagesService) SendToChatroomsContext(ctx context.Context, from string,
+  body MessageBody, chatroomids ...string) (SendResult, *Response, error) {
+  return s.SendContext(ctx, from, ToChatrooms(chatroomids...), body, nil)
+}
+
+/**
+ * Send text message to users
+ *
+ * http://docs.easemob.com/doku.php?id=start:100serverintegration:50messages#发送文本消息
+ */
+func (s *MessagesService) SendTextMessagesToUsers(from string, text string,
+  userIds ...string) (SendResult, *Response, error) {
+  return s.SendTextMessagesToUsersContext(context.Background(), from, text, userIds...)
+}
+
+/**
+ * SendTextMessagesToUsersContext is like SendTextMessagesToUsers but sends the
+ * request with ctx.
+ */
+func (s *MessagesService) SendTextMessagesToUsersContext(ctx context.Context, from string, text string, userIds ...string) (SendResult, *Response, error) {
+  return s.SendToUsersContext(ctx, from, &TextMessage{Msg: text}, userIds...)
 }
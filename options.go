@@ -0,0 +1,164 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A Logger receives notices from a Client. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// An Option configures a Client created by NewClient.
+type Option func(*clientConfig) error
+
+type clientConfig struct {
+	httpClient   *http.Client
+	timeout      time.Duration
+	baseURL      string
+	orgName      string
+	appName      string
+	clientID     string
+	clientSecret string
+	token        string
+	userAgent    string
+	logger       Logger
+	retryPolicy  *RetryPolicy
+	rateLimiter  *RateLimiter
+	tokenStore   TokenStore
+}
+
+func (cfg *clientConfig) validate() error {
+	if cfg.orgName == "" || cfg.appName == "" {
+		return errors.New("easemob: org and app name are required")
+	}
+	if cfg.token == "" && (cfg.clientID == "" || cfg.clientSecret == "") && cfg.tokenStore == nil {
+		return errors.New("easemob: client credentials, a token or a token store are required")
+	}
+	return nil
+}
+
+// WithApp sets the org and app the client works on.
+func WithApp(orgName, appName string) Option {
+	return func(cfg *clientConfig) error {
+		if strings.Contains(orgName, "/") || strings.Contains(appName, "/") {
+			return fmt.Errorf("easemob: invalid org or app name %q/%q", orgName, appName)
+		}
+		cfg.orgName, cfg.appName = orgName, appName
+		return nil
+	}
+}
+
+// WithCredentials sets the client id and secret used to request tokens.
+func WithCredentials(clientID, clientSecret string) Option {
+	return func(cfg *clientConfig) error {
+		cfg.clientID, cfg.clientSecret = clientID, clientSecret
+		return nil
+	}
+}
+
+// WithToken sets a token to use until the API rejects it.
+func WithToken(token string) Option {
+	return func(cfg *clientConfig) error {
+		cfg.token = token
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to communicate with the API, in
+// place of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(cfg *clientConfig) error {
+		if hc == nil {
+			return errors.New("easemob: nil HTTP client")
+		}
+		cfg.httpClient = hc
+		return nil
+	}
+}
+
+// WithTimeout sets the time limit of each request to the API. The HTTP
+// client is copied rather than modified.
+func WithTimeout(d time.Duration) Option {
+	return func(cfg *clientConfig) error {
+		if d < 0 {
+			return fmt.Errorf("easemob: negative timeout %v", d)
+		}
+		cfg.timeout = d
+		return nil
+	}
+}
+
+// WithBaseURL sets the base URL of the API, for private deployments and apps
+// on other clusters than the default one, such as SingaporeBaseURL.
+func WithBaseURL(rawURL string) Option {
+	return func(cfg *clientConfig) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("easemob: invalid base URL: %v", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("easemob: base URL %q is not an absolute HTTP URL", rawURL)
+		}
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		cfg.baseURL = u.String()
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return func(cfg *clientConfig) error {
+		cfg.userAgent = ua
+		return nil
+	}
+}
+
+// WithLogger sets the logger receiving the client's notices.
+func WithLogger(l Logger) Option {
+	return func(cfg *clientConfig) error {
+		cfg.logger = l
+		return nil
+	}
+}
+
+// WithRetryPolicy sets how requests failing with a transient error are
+// retried.
+func WithRetryPolicy(p *RetryPolicy) Option {
+	return func(cfg *clientConfig) error {
+		if p != nil && (p.MinBackoff < 0 || p.MaxBackoff < 0 || p.Jitter < 0 || p.Jitter > 1) {
+			return errors.New("easemob: invalid retry policy")
+		}
+		cfg.retryPolicy = p
+		return nil
+	}
+}
+
+// WithRateLimiter sets the limiter holding requests back to stay within
+// Easemob's quotas.
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(cfg *clientConfig) error {
+		cfg.rateLimiter = l
+		return nil
+	}
+}
+
+// WithTokenStore sets the store through which the client shares its token.
+func WithTokenStore(s TokenStore) Option {
+	return func(cfg *clientConfig) error {
+		cfg.tokenStore = s
+		return nil
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewClient_invalid(t *testing.T) {
+	app := WithApp("org", "app")
+	creds := WithCredentials("id", "secret")
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"nothing", nil},
+		{"no app", []Option{creds}},
+		{"no org", []Option{WithApp("", "app"), creds}},
+		{"slash in org", []Option{WithApp("o/rg", "app"), creds}},
+		{"slash in app", []Option{WithApp("org", "a/pp"), creds}},
+		{"no credentials", []Option{app}},
+		{"no secret", []Option{app, WithCredentials("id", "")}},
+		{"no client id", []Option{app, WithCredentials("", "secret")}},
+		{"relative base URL", []Option{app, creds, WithBaseURL("/api/")}},
+		{"base URL without host", []Option{app, creds, WithBaseURL("https:///")}},
+		{"base URL scheme", []Option{app, creds, WithBaseURL("ftp://a1.easemob.com/")}},
+		{"unparsable base URL", []Option{app, creds, WithBaseURL("http://[::1")}},
+		{"nil HTTP client", []Option{app, creds, WithHTTPClient(nil)}},
+		{"negative timeout", []Option{app, creds, WithTimeout(-time.Second)}},
+		{"negative backoff", []Option{app, creds, WithRetryPolicy(&RetryPolicy{MinBackoff: -1})}},
+		{"negative max backoff", []Option{app, creds, WithRetryPolicy(&RetryPolicy{MaxBackoff: -1})}},
+		{"jitter over 1", []Option{app, creds, WithRetryPolicy(&RetryPolicy{Jitter: 1.5})}},
+		{"negative jitter", []Option{app, creds, WithRetryPolicy(&RetryPolicy{Jitter: -0.1})}},
+	}
+	for _, tt := range tests {
+		if c, err := NewClient(tt.opts...); err == nil {
+			t.Errorf("NewClient with %v returned %+v and no error", tt.name, c)
+		}
+	}
+}
+
+func TestNewClient_valid(t *testing.T) {
+	app := WithApp("org", "app")
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"credentials", []Option{app, WithCredentials("id", "secret")}},
+		{"token", []Option{app, WithToken("token")}},
+		{"token store", []Option{app, WithTokenStore(NewMemoryTokenStore())}},
+		{"nil retry policy", []Option{app, WithToken("token"), WithRetryPolicy(nil)}},
+	}
+	for _, tt := range tests {
+		if _, err := NewClient(tt.opts...); err != nil {
+			t.Errorf("NewClient with %v returned error: %v", tt.name, err)
+		}
+	}
+}
+
+func TestNewClient_options(t *testing.T) {
+	hc := &http.Client{}
+	policy := &RetryPolicy{MaxAttempts: 2}
+	limiter := NewRateLimiter(nil)
+	c, err := NewClient(
+		WithApp("org", "app"),
+		WithCredentials("id", "secret"),
+		WithBaseURL("https://a1-sgp.easemob.com/api"),
+		WithHTTPClient(hc),
+		WithTimeout(3*time.Second),
+		WithUserAgent("test-agent"),
+		WithRetryPolicy(policy),
+		WithRateLimiter(limiter),
+	)
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+	if got, want := c.BaseURL.String(), "https://a1-sgp.easemob.com/api/"; got != want {
+		t.Errorf("BaseURL = %v, want %v", got, want)
+	}
+	if c.OrgName != "org" || c.AppName != "app" {
+		t.Errorf("app = %v/%v, want org/app", c.OrgName, c.AppName)
+	}
+	if c.Credentials.ClientId != "id" || c.Credentials.Secret != "secret" {
+		t.Errorf("Credentials = %+v", c.Credentials)
+	}
+	if c.client == hc || c.client.Timeout != 3*time.Second || hc.Timeout != 0 {
+		t.Errorf("WithTimeout modified the given HTTP client or was not applied")
+	}
+	if c.UserAgent != "test-agent" || c.RetryPolicy != policy || c.RateLimiter != limiter {
+		t.Errorf("options not applied: %+v", c)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A Field is a user attribute the users endpoints can filter and sort on.
+type Field string
+
+// Fields accepted in queries.
+const (
+	FieldCreated  Field = "created"
+	FieldModified Field = "modified"
+)
+
+// Order is the direction of a sort.
+type Order string
+
+// Sort directions.
+const (
+	Asc  Order = "asc"
+	Desc Order = "desc"
+)
+
+// Query builds the QL expressions accepted by the users endpoints. Its
+// methods return a modified copy, so queries can be built up in one
+// expression and reused as a base:
+//
+//	q := easemob.Query{}.CreatedBetween(start, end).OrderBy(easemob.FieldCreated, easemob.Desc).Limit(100)
+//	users, _, err := client.Users.ListAll(q.ListOptions())
+//
+// The zero Query selects every user.
+//
+// Easemob API docs: http://www.easemob.com/docs/rest/userapi/#im-3
+type Query struct {
+	conds []string
+	order string
+	limit int
+}
+
+func (q Query) where(f Field, op string, t time.Time) Query {
+	conds := make([]string, len(q.conds), len(q.conds)+1)
+	copy(conds, q.conds)
+	q.conds = append(conds, fmt.Sprintf("%s %s %d", f, op, t.UnixNano()/int64(time.Millisecond)))
+	return q
+}
+
+// CreatedAfter restricts q to users created after t.
+func (q Query) CreatedAfter(t time.Time) Query {
+	return q.where(FieldCreated, ">", t)
+}
+
+// CreatedBefore restricts q to users created before t.
+func (q Query) CreatedBefore(t time.Time) Query {
+	return q.where(FieldCreated, "<", t)
+}
+
+// CreatedBetween restricts q to users created after start and before end.
+func (q Query) CreatedBetween(start, end time.Time) Query {
+	return q.CreatedAfter(start).CreatedBefore(end)
+}
+
+// ModifiedAfter restricts q to users modified after t.
+func (q Query) ModifiedAfter(t time.Time) Query {
+	return q.where(FieldModified, ">", t)
+}
+
+// ModifiedBefore restricts q to users modified before t.
+func (q Query) ModifiedBefore(t time.Time) Query {
+	return q.where(FieldModified, "<", t)
+}
+
+// ModifiedBetween restricts q to users modified after start and before end.
+func (q Query) ModifiedBetween(start, end time.Time) Query {
+	return q.ModifiedAfter(start).ModifiedBefore(end)
+}
+
+// OrderBy sorts the results of q on f. A later call replaces the order set
+// by an earlier one.
+func (q Query) OrderBy(f Field, o Order) Query {
+	q.order = fmt.Sprintf("%s %s", f, o)
+	return q
+}
+
+// Limit sets the number of users a request made with q covers. It is sent
+// as the limit parameter, not as part of the QL expression.
+func (q Query) Limit(n int) Query {
+	q.limit = n
+	return q
+}
+
+// String renders q as a QL expression.
+func (q Query) String() string {
+	var b strings.Builder
+	b.WriteString("select *")
+	if len(q.conds) > 0 {
+		b.WriteString(" where ")
+		b.WriteString(strings.Join(q.conds, " and "))
+	}
+	if q.order != "" {
+		b.WriteString(" order by ")
+		b.WriteString(q.order)
+	}
+	return b.String()
+}
+
+// ListOptions returns the list options carrying q.
+func (q Query) ListOptions() *ListOptions {
+	return &ListOptions{QL: q.String(), Limit: q.limit}
+}
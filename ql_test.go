@@ -0,0 +1,91 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQuery_String(t *testing.T) {
+	start := time.Date(2017, 7, 14, 2, 40, 0, 123456789, time.UTC)
+	end := start.Add(time.Hour)
+	// Millisecond timestamps are the same in every location.
+	local := end.In(time.FixedZone("CST", 8*3600))
+
+	tests := []struct {
+		name string
+		q    Query
+		want string
+	}{
+		{"zero", Query{}, "select *"},
+		{"created after", Query{}.CreatedAfter(start), "select * where created > 1500000000123"},
+		{"created before, other location", Query{}.CreatedBefore(local), "select * where created < 1500003600123"},
+		{"created between", Query{}.CreatedBetween(start, end),
+			"select * where created > 1500000000123 and created < 1500003600123"},
+		{"modified between", Query{}.ModifiedBetween(start, end),
+			"select * where modified > 1500000000123 and modified < 1500003600123"},
+		{"combined", Query{}.CreatedAfter(start).ModifiedBefore(end),
+			"select * where created > 1500000000123 and modified < 1500003600123"},
+		{"order", Query{}.OrderBy(FieldCreated, Desc), "select * order by created desc"},
+		{"order replaced", Query{}.OrderBy(FieldCreated, Desc).OrderBy(FieldModified, Asc),
+			"select * order by modified asc"},
+		{"conditions and order", Query{}.CreatedBetween(start, end).OrderBy(FieldCreated, Asc),
+			"select * where created > 1500000000123 and created < 1500003600123 order by created asc"},
+		{"limit", Query{}.Limit(20), "select *"},
+	}
+	for _, tt := range tests {
+		if got := tt.q.String(); got != tt.want {
+			t.Errorf("%v: String() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQuery_copies(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	base := Query{}.CreatedAfter(start)
+	a := base.ModifiedAfter(start)
+	b := base.ModifiedBefore(start)
+	if want := "select * where created > 1500000000000 and modified > 1500000000000"; a.String() != want {
+		t.Errorf("a = %q, want %q", a, want)
+	}
+	if want := "select * where created > 1500000000000 and modified < 1500000000000"; b.String() != want {
+		t.Errorf("b = %q, want %q", b, want)
+	}
+	if want := "select * where created > 1500000000000"; base.String() != want {
+		t.Errorf("base = %q, want %q", base, want)
+	}
+}
+
+func TestUsersService_DeleteCreatedBetween(t *testing.T) {
+	client, mux := setup(t)
+	mux.HandleFunc("/org/app/users", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+		want := map[string]string{
+			"ql":    "select * where created > 1500000000000 and created < 1500003600000",
+			"limit": "5",
+		}
+		got := map[string]string{"ql": r.FormValue("ql"), "limit": r.FormValue("limit")}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("query = %v, want %v", got, want)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"entities": []map[string]string{{"username": "alice"}, {"username": "bob"}},
+		})
+	})
+
+	start := time.Unix(1500000000, 0)
+	users, _, err := client.Users.DeleteCreatedBetween(start, start.Add(time.Hour), 5)
+	if err != nil {
+		t.Fatalf("DeleteCreatedBetween returned error: %v", err)
+	}
+	if len(users) != 2 || users[0].Username != "alice" || users[1].Username != "bob" {
+		t.Errorf("DeleteCreatedBetween returned %+v", users)
+	}
+}
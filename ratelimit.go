@@ -0,0 +1,194 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An EndpointCategory is a family of REST endpoints which Easemob throttles
+// under a common quota.
+type EndpointCategory string
+
+const (
+	CategoryUsers     EndpointCategory = "users"
+	CategoryMessages  EndpointCategory = "messages"
+	CategoryGroups    EndpointCategory = "chatgroups"
+	CategoryChatrooms EndpointCategory = "chatrooms"
+	CategoryFiles     EndpointCategory = "chatfiles"
+	CategoryToken     EndpointCategory = "token"
+
+	// CategoryOther covers every endpoint without a category of its own.
+	CategoryOther EndpointCategory = ""
+)
+
+// ErrRateLimited is returned by a non-blocking RateLimiter when a request
+// would exceed its category's rate. APIErrors for throttled requests match
+// it as well.
+var ErrRateLimited = errors.New("easemob: rate limit exceeded")
+
+// A Rate is the sustained number of requests per second allowed for an
+// endpoint category, plus how many may be sent at once after a quiet period.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// A RateLimiter holds requests back so that each endpoint category stays
+// within its Rate, rather than letting the server throttle them. Categories
+// without a Rate are not limited. It is safe for concurrent use, and may be
+// shared by the clients of one app since Easemob quotas apply per app.
+//
+// For example, to keep to the default quotas of 100 calls per second for
+// users and groups and 20 for messages:
+//
+//	client.RateLimiter = easemob.NewRateLimiter(map[easemob.EndpointCategory]easemob.Rate{
+//		easemob.CategoryUsers:    {PerSecond: 100, Burst: 100},
+//		easemob.CategoryGroups:   {PerSecond: 100, Burst: 100},
+//		easemob.CategoryMessages: {PerSecond: 20, Burst: 20},
+//	})
+type RateLimiter struct {
+	// NonBlocking makes requests over the rate fail at once with
+	// ErrRateLimited instead of waiting for capacity.
+	NonBlocking bool
+
+	buckets map[EndpointCategory]*bucket
+	now     func() time.Time // time.Now if nil; replaced by tests
+}
+
+// NewRateLimiter returns a blocking RateLimiter enforcing limits.
+func NewRateLimiter(limits map[EndpointCategory]Rate) *RateLimiter {
+	l := &RateLimiter{buckets: make(map[EndpointCategory]*bucket)}
+	for category, rate := range limits {
+		if rate.PerSecond <= 0 {
+			continue
+		}
+		burst := float64(rate.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+		l.buckets[category] = &bucket{rate: rate.PerSecond, burst: burst, tokens: burst}
+	}
+	return l
+}
+
+// Allow reports whether a request in category may be sent now, and if so
+// accounts for it.
+func (l *RateLimiter) Allow(category EndpointCategory) bool {
+	b := l.buckets[category]
+	if b == nil {
+		return true
+	}
+	return b.reserve(l.clock(), 0) == 0
+}
+
+// Wait accounts for a request in category, blocking until it may be sent or
+// ctx is done. If l is non-blocking, Wait returns ErrRateLimited instead of
+// blocking.
+func (l *RateLimiter) Wait(ctx context.Context, category EndpointCategory) error {
+	b := l.buckets[category]
+	if b == nil {
+		return nil
+	}
+
+	now := l.clock()
+	var maxWait time.Duration = -1
+	if l.NonBlocking {
+		maxWait = 0
+	} else if deadline, ok := ctx.Deadline(); ok {
+		maxWait = deadline.Sub(now)
+	}
+
+	d := b.reserve(now, maxWait)
+	if d < 0 {
+		if l.NonBlocking {
+			return ErrRateLimited
+		}
+		return context.DeadlineExceeded
+	}
+	if d == 0 {
+		return nil
+	}
+	if err := sleepContext(ctx, d); err != nil {
+		b.cancel()
+		return err
+	}
+	return nil
+}
+
+func (l *RateLimiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+// A bucket is a token bucket refilled at rate tokens per second.
+type bucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token and returns how long to wait before using it. If
+// the wait would exceed maxWait, no token is taken and reserve returns -1.
+// A negative maxWait means no limit.
+func (b *bucket) reserve(now time.Time, maxWait time.Duration) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	tokens := b.tokens - 1
+	var d time.Duration
+	if tokens < 0 {
+		d = time.Duration(-tokens / b.rate * float64(time.Second))
+	}
+	if maxWait >= 0 && d > maxWait {
+		return -1
+	}
+	b.tokens = tokens
+	return d
+}
+
+// cancel returns a token reserved by a request which was abandoned.
+func (b *bucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// endpointCategory returns the category of the endpoint req is sent to.
+func (c *Client) endpointCategory(req *http.Request) EndpointCategory {
+	prefix := strings.TrimSuffix(c.BaseURL.Path, "/") + "/" + c.OrgName + "/" + c.AppName + "/"
+	path := strings.TrimPrefix(req.URL.Path, prefix)
+	if i := strings.Index(path, "/"); i >= 0 {
+		path = path[:i]
+	}
+
+	switch category := EndpointCategory(path); category {
+	case CategoryUsers, CategoryMessages, CategoryGroups, CategoryChatrooms, CategoryFiles, CategoryToken:
+		return category
+	}
+	return CategoryOther
+}
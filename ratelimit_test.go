@@ -0,0 +1,157 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock which only moves when told to.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// newTestLimiter returns a RateLimiter limiting CategoryUsers to rate, and
+// the clock it runs on.
+func newTestLimiter(rate Rate) (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Now()}
+	l := NewRateLimiter(map[EndpointCategory]Rate{CategoryUsers: rate})
+	l.now = clock.now
+	return l, clock
+}
+
+// allowed returns how many requests in a row l lets through now.
+func allowed(l *RateLimiter) int {
+	n := 0
+	for l.Allow(CategoryUsers) {
+		n++
+	}
+	return n
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	l, clock := newTestLimiter(Rate{PerSecond: 10, Burst: 3})
+
+	if n := allowed(l); n != 3 {
+		t.Errorf("allowed %d requests at first, want the burst of 3", n)
+	}
+	clock.advance(100 * time.Millisecond)
+	if n := allowed(l); n != 1 {
+		t.Errorf("allowed %d requests after 100ms, want 1", n)
+	}
+	clock.advance(250 * time.Millisecond)
+	if n := allowed(l); n != 2 {
+		t.Errorf("allowed %d requests after 250ms, want 2", n)
+	}
+	clock.advance(time.Minute)
+	if n := allowed(l); n != 3 {
+		t.Errorf("allowed %d requests after a minute, want the burst of 3", n)
+	}
+	if !l.Allow(CategoryMessages) {
+		t.Error("a category without a rate was limited")
+	}
+}
+
+func TestRateLimiter_Wait_nonBlocking(t *testing.T) {
+	l, clock := newTestLimiter(Rate{PerSecond: 2, Burst: 1})
+	l.NonBlocking = true
+	ctx := context.Background()
+
+	if err := l.Wait(ctx, CategoryUsers); err != nil {
+		t.Fatalf("first Wait returned error: %v", err)
+	}
+	if err := l.Wait(ctx, CategoryUsers); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Wait over the rate returned %v, want ErrRateLimited", err)
+	}
+	clock.advance(500 * time.Millisecond)
+	if err := l.Wait(ctx, CategoryUsers); err != nil {
+		t.Errorf("Wait after a refill returned error: %v", err)
+	}
+}
+
+func TestRateLimiter_Wait_deadline(t *testing.T) {
+	l, clock := newTestLimiter(Rate{PerSecond: 1, Burst: 1})
+	if !l.Allow(CategoryUsers) {
+		t.Fatal("first request not allowed")
+	}
+
+	// The next token comes in a second, after the deadline: Wait must
+	// fail at once without taking it.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx, CategoryUsers); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait returned %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("Wait blocked for %v before failing", d)
+	}
+	clock.advance(time.Second)
+	if !l.Allow(CategoryUsers) {
+		t.Error("Wait past the deadline kept a token")
+	}
+}
+
+func TestRateLimiter_Wait_canceled(t *testing.T) {
+	l, clock := newTestLimiter(Rate{PerSecond: 1, Burst: 1})
+	if !l.Allow(CategoryUsers) {
+		t.Fatal("first request not allowed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := l.Wait(ctx, CategoryUsers); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait returned %v, want context.Canceled", err)
+	}
+	clock.advance(time.Second)
+	if !l.Allow(CategoryUsers) {
+		t.Error("canceled Wait kept its token")
+	}
+}
+
+func TestClient_endpointCategory(t *testing.T) {
+	client, _ := setup(t)
+	tests := []struct {
+		path string
+		want EndpointCategory
+	}{
+		{"users", CategoryUsers},
+		{"users/alice/contacts/users/bob", CategoryUsers},
+		{"messages", CategoryMessages},
+		{"chatgroups/1234/users", CategoryGroups},
+		{"chatrooms", CategoryChatrooms},
+		{"chatfiles/uuid", CategoryFiles},
+		{"token", CategoryToken},
+		{"chatmessages", CategoryOther},
+		{"", CategoryOther},
+	}
+	for _, tt := range tests {
+		req, err := client.NewRequest("GET", tt.path, nil)
+		if err != nil {
+			t.Fatalf("NewRequest(%q) returned error: %v", tt.path, err)
+		}
+		if got := client.endpointCategory(req); got != tt.want {
+			t.Errorf("endpointCategory(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
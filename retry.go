@@ -0,0 +1,188 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// A RetryPolicy decides which failed requests Client.Do sends again and how
+// long it waits in between. Delays grow exponentially from MinBackoff to
+// MaxBackoff, unless the server asks for a specific delay with a Retry-After
+// header.
+//
+// Requests whose method is not idempotent, such as POST, are only retried
+// when the server rejected them unprocessed because of throttling (429 and
+// 503), unless RetryNonIdempotent is set.
+//
+// A RetryPolicy must not be modified while in use.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made, including the
+	// first. Zero or less means requests are never retried.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry. It doubles for every
+	// further retry, up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Jitter is the fraction of each delay, between 0 and 1, that is
+	// randomized to keep clients from retrying in lockstep.
+	Jitter float64
+
+	// RetryableStatus lists the response status codes worth retrying.
+	RetryableStatus []int
+
+	// RetryNonIdempotent allows retrying requests with a non-idempotent
+	// method on any retryable status or network error, at the risk of
+	// applying them twice.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by clients which don't set one.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  10 * time.Second,
+	Jitter:      0.5,
+	RetryableStatus: []int{
+		http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+func (p *RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// retryStatus reports whether req is worth sending again after the server
+// answered with status code.
+func (p *RetryPolicy) retryStatus(req *http.Request, code int) bool {
+	retryable := false
+	for _, c := range p.RetryableStatus {
+		if c == code {
+			retryable = true
+			break
+		}
+	}
+	if !retryable {
+		return false
+	}
+	// Throttled requests were turned away before doing anything.
+	if code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable {
+		return true
+	}
+	return p.RetryNonIdempotent || idempotent(req.Method)
+}
+
+// retryError reports whether req is worth sending again after it failed
+// without a response. The server may or may not have processed it.
+func (p *RetryPolicy) retryError(req *http.Request) bool {
+	return p.RetryNonIdempotent || idempotent(req.Method)
+}
+
+// backoff returns how long to wait before the retry following attempt. resp
+// is the response to that attempt, or nil if it failed without one.
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return d
+		}
+	}
+
+	d := p.MinBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if j := p.Jitter; j > 0 && d > 0 {
+		if j > 1 {
+			j = 1
+		}
+		spread := time.Duration(float64(d) * j)
+		d = d - spread + time.Duration(rand.Int63n(int64(spread)+1))
+	}
+	return d
+}
+
+// retryAfter parses the value of a Retry-After header, which holds either a
+// number of seconds or an HTTP date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// sleepContext pauses for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rewindable reports whether req can be sent again.
+func rewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest returns a copy of req whose body is ready to be sent again.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return r, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("easemob: cannot resend %v %v: request body is not rewindable", req.Method, req.URL)
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = body
+	return r, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"1.5", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	got, ok := retryAfter(date)
+	if !ok || got <= 58*time.Second || got > time.Minute {
+		t.Errorf("retryAfter(%q) = %v, %v; want about a minute", date, got, ok)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := &RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.backoff(tt.attempt, nil); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {"7"}}}
+	if got := p.backoff(1, resp); got != 7*time.Second {
+		t.Errorf("backoff with Retry-After: 7 = %v, want 7s", got)
+	}
+	resp.Header.Set("Retry-After", "later")
+	if got := p.backoff(2, resp); got != 200*time.Millisecond {
+		t.Errorf("backoff with an invalid Retry-After = %v, want 200ms", got)
+	}
+}
+
+func TestRetryPolicy_backoff_jitter(t *testing.T) {
+	for _, jitter := range []float64{0.25, 0.5, 1, 2} {
+		p := &RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: jitter}
+		spread := jitter
+		if spread > 1 {
+			spread = 1
+		}
+		for attempt := 1; attempt <= 6; attempt++ {
+			max := (&RetryPolicy{MinBackoff: p.MinBackoff, MaxBackoff: p.MaxBackoff}).backoff(attempt, nil)
+			min := max - time.Duration(float64(max)*spread)
+			seen := make(map[time.Duration]bool)
+			for i := 0; i < 100; i++ {
+				d := p.backoff(attempt, nil)
+				if d < min || d > max {
+					t.Fatalf("jitter %v: backoff(%d) = %v, want within [%v, %v]", jitter, attempt, d, min, max)
+				}
+				seen[d] = true
+			}
+			if len(seen) < 2 {
+				t.Errorf("jitter %v: backoff(%d) always %v", jitter, attempt, max)
+			}
+		}
+	}
+}
+
+// handleUsers serves the users endpoint on mux, answering with the statuses
+// of codes in turn and then with 200, and returns the request bodies it
+// receives.
+func handleUsers(t *testing.T, mux *http.ServeMux, codes ...int) *[]string {
+	var mu sync.Mutex
+	var bodies []string
+	mux.HandleFunc("/org/app/users", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		code := http.StatusOK
+		if len(codes) > 0 {
+			code, codes = codes[0], codes[1:]
+		}
+		mu.Unlock()
+		if code != http.StatusOK {
+			w.WriteHeader(code)
+			fmt.Fprintf(w, `{"error":"failure","error_description":%q}`, http.StatusText(code))
+			return
+		}
+		fmt.Fprint(w, `{"entities":[{"username":"alice"}]}`)
+	})
+	return &bodies
+}
+
+// setupRetry is setup with a retry policy fast enough for tests.
+func setupRetry(t *testing.T) (*Client, *http.ServeMux) {
+	client, mux := setup(t)
+	policy := DefaultRetryPolicy
+	policy.MinBackoff = time.Millisecond
+	policy.MaxBackoff = 10 * time.Millisecond
+	client.RetryPolicy = &policy
+	return client, mux
+}
+
+func TestClient_retry_postUnavailable(t *testing.T) {
+	client, mux := setupRetry(t)
+	bodies := handleUsers(t, mux, http.StatusServiceUnavailable)
+
+	if _, _, err := client.Users.Register("alice", "secret"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if len(*bodies) != 2 {
+		t.Fatalf("server got %d requests, want 2", len(*bodies))
+	}
+	if b := *bodies; b[0] == "" || b[1] != b[0] {
+		t.Errorf("retried body %q, want %q", b[1], b[0])
+	}
+}
+
+func TestClient_retry_postServerError(t *testing.T) {
+	client, mux := setupRetry(t)
+	bodies := handleUsers(t, mux, http.StatusInternalServerError)
+
+	if _, _, err := client.Users.Register("alice", "secret"); err == nil {
+		t.Fatal("Register returned no error")
+	}
+	if len(*bodies) != 1 {
+		t.Errorf("server got %d requests, want 1: a POST failing with 500 is not retried", len(*bodies))
+	}
+}
+
+func TestClient_retry_getServerError(t *testing.T) {
+	client, mux := setupRetry(t)
+	var gets int32
+	mux.HandleFunc("/org/app/users/alice", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&gets, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error":"internal_server_error"}`)
+			return
+		}
+		fmt.Fprint(w, `{"entities":[{"username":"alice"}]}`)
+	})
+
+	if _, _, err := client.Users.Get("alice"); err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if gets != 3 {
+		t.Errorf("server got %d requests, want 3", gets)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// tokenRefreshMargin is how long before its expiry a token is
+	// considered stale and proactively refreshed.
+	tokenRefreshMargin = 5 * time.Minute
+
+	// tokenFetchTimeout bounds a shared token request, which outlives the
+	// context of the caller that started it.
+	tokenFetchTimeout = 30 * time.Second
+)
+
+// tokenCall is an in-flight token request shared by concurrent callers.
+type tokenCall struct {
+	done chan struct{}
+	err  error
+}
+
+// GetToken fetches a new auth token and stores it on the client. Concurrent
+// callers share a single request to the token endpoint.
+func (c *Client) GetToken() error {
+	return c.GetTokenContext(context.Background())
+}
+
+// GetTokenContext is like GetToken but gives up waiting for the token when
+// ctx is done.
+func (c *Client) GetTokenContext(ctx context.Context) error {
+	c.tokenMu.Lock()
+	stale := c.Token
+	c.tokenMu.Unlock()
+	return c.refreshToken(ctx, stale)
+}
+
+// accessToken returns a valid token, fetching one first if the client has
+// none yet or the current one is about to expire.
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.tokenMu.Lock()
+	token := c.Token
+	valid := c.tokenValid()
+	c.tokenMu.Unlock()
+	if valid {
+		return token, nil
+	}
+
+	if err := c.refreshToken(ctx, token); err != nil {
+		return "", err
+	}
+
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	return c.Token, nil
+}
+
+// tokenValid reports whether the current token can still be used. A token
+// without a known expiry, such as one passed to NewClient, is trusted until
+// the API rejects it. c.tokenMu must be held.
+func (c *Client) tokenValid() bool {
+	if c.Token == "" {
+		return false
+	}
+	return c.tokenExpiry.IsZero() || time.Now().Add(tokenRefreshMargin).Before(c.tokenExpiry)
+}
+
+// refreshToken replaces stale with a new token. If another caller already
+// replaced it, or is in the middle of doing so, no extra request is made.
+// The request runs detached from ctx so that a caller giving up does not
+// fail the others waiting on it.
+func (c *Client) refreshToken(ctx context.Context, stale string) error {
+	c.tokenMu.Lock()
+	if c.Token != stale && c.tokenValid() {
+		c.tokenMu.Unlock()
+		return nil
+	}
+	call := c.tokenCall
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		c.tokenCall = call
+		go c.runTokenCall(context.WithoutCancel(ctx), call, stale)
+	}
+	c.tokenMu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) runTokenCall(ctx context.Context, call *tokenCall, stale string) {
+	ctx, cancel := context.WithTimeout(ctx, tokenFetchTimeout)
+	defer cancel()
+
+	token, expiry, err := c.obtainToken(ctx, stale)
+
+	c.tokenMu.Lock()
+	if err == nil {
+		c.setToken(token, expiry)
+	}
+	c.tokenCall = nil
+	c.tokenMu.Unlock()
+
+	call.err = err
+	close(call.done)
+}
+
+// setToken stores a token valid until expiry. c.tokenMu must be held.
+func (c *Client) setToken(token string, expiry time.Time) {
+	c.Token = token
+	c.tokenExpiry = expiry
+	c.Expires = 0
+	if !expiry.IsZero() {
+		c.Expires = int64(time.Until(expiry) / time.Second)
+	}
+}
+
+// obtainToken returns a token other than stale, taking it from the client's
+// TokenStore when the store holds a usable one and requesting a new one from
+// the token endpoint otherwise.
+func (c *Client) obtainToken(ctx context.Context, stale string) (string, time.Time, error) {
+	store := c.TokenStore
+	if store == nil {
+		return c.fetchToken(ctx)
+	}
+
+	if l, ok := store.(TokenLocker); ok {
+		unlock, err := l.LockToken()
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		defer unlock()
+	}
+
+	token, expiry, err := store.Load()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if token != "" && token != stale && time.Now().Add(tokenRefreshMargin).Before(expiry) {
+		return token, expiry, nil
+	}
+
+	token, expiry, err = c.fetchToken(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	// The token is good even if it could not be shared; other clients
+	// will fetch their own.
+	if err := store.Save(token, expiry); err != nil {
+		c.logf("easemob: saving token: %v", err)
+	}
+	return token, expiry, nil
+}
+
+// fetchToken requests a new token from the token endpoint.
+func (c *Client) fetchToken(ctx context.Context) (string, time.Time, error) {
+	var u string
+	u = "token"
+
+	req, err := c.NewRequestWithoutAuthContext(ctx, "POST", u, c.Credentials)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if resp.AccessToken == "" {
+		return "", time.Time{}, errors.New("easemob: token response has no access_token")
+	}
+
+	var expiry time.Time
+	if resp.Expires > 0 {
+		expiry = time.Now().Add(time.Duration(resp.Expires) * time.Second)
+	}
+	return resp.AccessToken, expiry, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// handleToken serves the token endpoint on mux, issuing token valid for
+// expires seconds, and counts the requests in n.
+func handleToken(t *testing.T, mux *http.ServeMux, token string, expires int, n *int32) {
+	mux.HandleFunc("/org/app/token", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		atomic.AddInt32(n, 1)
+		// Hold the request so that concurrent callers pile up behind it.
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprintf(w, `{"access_token":%q,"expires_in":%d}`, token, expires)
+	})
+}
+
+// handleUser serves alice on mux to requests authorized with token, and
+// counts all requests in n.
+func handleUser(t *testing.T, mux *http.ServeMux, token string, n *int32) {
+	mux.HandleFunc("/org/app/users/alice", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		atomic.AddInt32(n, 1)
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"unauthorized","error_description":"Unable to authenticate due to expired access token"}`)
+			return
+		}
+		fmt.Fprint(w, `{"entities":[{"username":"alice"}]}`)
+	})
+}
+
+func TestClient_token_concurrent(t *testing.T) {
+	client, mux := setup(t)
+	client.Token = ""
+	var tokens, gets int32
+	handleToken(t, mux, "fresh", 7200, &tokens)
+	handleUser(t, mux, "fresh", &gets)
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := client.Users.Get("alice"); err != nil {
+				t.Errorf("Users.Get returned error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if tokens != 1 {
+		t.Errorf("%d token requests for %d concurrent calls, want 1", tokens, n)
+	}
+	if gets != n {
+		t.Errorf("%d user requests, want %d", gets, n)
+	}
+}
+
+func TestClient_token_expired(t *testing.T) {
+	client, mux := setup(t)
+	var tokens, gets int32
+	handleToken(t, mux, "fresh", 7200, &tokens)
+	handleUser(t, mux, "fresh", &gets)
+
+	user, _, err := client.Users.Get("alice")
+	if err != nil {
+		t.Fatalf("Users.Get with an expired token returned error: %v", err)
+	}
+	if user.Username != "alice" {
+		t.Errorf("Users.Get returned %+v", user)
+	}
+	if gets != 2 || tokens != 1 {
+		t.Errorf("got %d user and %d token requests, want 2 and 1", gets, tokens)
+	}
+	if client.Token != "fresh" {
+		t.Errorf("client token is %q, want the refreshed one", client.Token)
+	}
+}
+
+func TestClient_token_stale(t *testing.T) {
+	client, mux := setup(t)
+	var tokens, gets int32
+	handleToken(t, mux, "fresh", 7200, &tokens)
+	handleUser(t, mux, "fresh", &gets)
+
+	// A token expiring within tokenRefreshMargin is replaced before use.
+	client.setToken("stale", time.Now().Add(time.Minute))
+	if _, _, err := client.Users.Get("alice"); err != nil {
+		t.Fatalf("Users.Get returned error: %v", err)
+	}
+	if gets != 1 || tokens != 1 {
+		t.Errorf("got %d user and %d token requests, want 1 and 1", gets, tokens)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A TokenStore keeps an app token so that several clients can share it
+// instead of each requesting their own. A store holds the token of a single
+// Easemob app.
+type TokenStore interface {
+	// Load returns the stored token and its expiry, or an empty token if
+	// the store holds none.
+	Load() (token string, expiry time.Time, err error)
+
+	// Save stores token, replacing any previous one.
+	Save(token string, expiry time.Time) error
+}
+
+// A TokenLocker is a TokenStore that can serialize token requests among its
+// users. While the lock is held, a client checks the store again and only
+// requests a new token if it still holds none.
+type TokenLocker interface {
+	TokenStore
+
+	// LockToken blocks until the lock is acquired and returns the function
+	// releasing it.
+	LockToken() (unlock func(), err error)
+}
+
+// MemoryTokenStore is a TokenStore shared by clients within a process.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+
+	lock sync.Mutex
+}
+
+// NewMemoryTokenStore returns an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return new(MemoryTokenStore)
+}
+
+// Load implements TokenStore.
+func (s *MemoryTokenStore) Load() (string, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token, s.expiry, nil
+}
+
+// Save implements TokenStore.
+func (s *MemoryTokenStore) Save(token string, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token, s.expiry = token, expiry
+	return nil
+}
+
+// LockToken implements TokenLocker.
+func (s *MemoryTokenStore) LockToken() (func(), error) {
+	s.lock.Lock()
+	return s.lock.Unlock, nil
+}
+
+// FileTokenStore is a TokenStore shared by processes on a host through a
+// file. Writes replace the file atomically, and token requests are
+// serialized with an advisory lock on a sibling file named Path + ".lock"
+// where the platform supports it.
+type FileTokenStore struct {
+	Path string
+}
+
+// NewFileTokenStore returns a FileTokenStore keeping its token in path.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{Path: path}
+}
+
+type storedToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresAt   int64  `json:"expires_at,omitempty"`
+}
+
+// Load implements TokenStore. A missing file holds no token.
+func (s *FileTokenStore) Load() (string, time.Time, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var t storedToken
+	if err := json.Unmarshal(data, &t); err != nil {
+		return "", time.Time{}, err
+	}
+
+	var expiry time.Time
+	if t.ExpiresAt > 0 {
+		expiry = time.Unix(t.ExpiresAt, 0)
+	}
+	return t.AccessToken, expiry, nil
+}
+
+// Save implements TokenStore. The token is written to a temporary file which
+// is then renamed over Path, so readers never see a partial write.
+func (s *FileTokenStore) Save(token string, expiry time.Time) error {
+	t := storedToken{AccessToken: token}
+	if !expiry.IsZero() {
+		t.ExpiresAt = expiry.Unix()
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(name, s.Path)
+	}
+	if err != nil {
+		os.Remove(name)
+	}
+	return err
+}
+
+// LockToken implements TokenLocker.
+func (s *FileTokenStore) LockToken() (func(), error) {
+	f, err := os.OpenFile(s.Path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package easemob
+
+import "os"
+
+// Without flock, token requests are not serialized across processes; the
+// atomic writes in Save still keep the file consistent.
+
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
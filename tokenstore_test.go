@@ -0,0 +1,179 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileTokenStore_missing(t *testing.T) {
+	s := NewFileTokenStore(filepath.Join(t.TempDir(), "token.json"))
+	token, expiry, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if token != "" || !expiry.IsZero() {
+		t.Errorf("Load = %q, %v; want no token", token, expiry)
+	}
+}
+
+func TestFileTokenStore_roundTrip(t *testing.T) {
+	s := NewFileTokenStore(filepath.Join(t.TempDir(), "token.json"))
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := s.Save("abc", expiry); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	token, got, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if token != "abc" || !got.Equal(expiry) {
+		t.Errorf("Load = %q, %v; want %q, %v", token, got, "abc", expiry)
+	}
+
+	if err := s.Save("def", time.Time{}); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if token, got, _ := s.Load(); token != "def" || !got.IsZero() {
+		t.Errorf("Load = %q, %v; want %q without expiry", token, got, "def")
+	}
+}
+
+func TestFileTokenStore_corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := ioutil.WriteFile(path, []byte(`{"access_token":`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := NewFileTokenStore(path)
+	if _, _, err := s.Load(); err == nil {
+		t.Error("Load of a corrupt file returned no error")
+	}
+
+	// Saving replaces the corrupt file.
+	if err := s.Save("abc", time.Time{}); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if token, _, err := s.Load(); err != nil || token != "abc" {
+		t.Errorf("Load = %q, %v; want %q", token, err, "abc")
+	}
+}
+
+func TestFileTokenStore_concurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token.json")
+	written := make(map[string]bool)
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 20; j++ {
+			written[fmt.Sprintf("token-%d-%d", i, j)] = true
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			s := NewFileTokenStore(path)
+			for j := 0; j < 20; j++ {
+				if err := s.Save(fmt.Sprintf("token-%d-%d", i, j), time.Now().Add(time.Hour)); err != nil {
+					t.Errorf("Save returned error: %v", err)
+				}
+			}
+		}(i)
+		// Readers must never see a partial write.
+		go func() {
+			defer wg.Done()
+			s := NewFileTokenStore(path)
+			for j := 0; j < 20; j++ {
+				token, _, err := s.Load()
+				if err != nil {
+					t.Errorf("Load returned error: %v", err)
+				}
+				if token != "" && !written[token] {
+					t.Errorf("Load returned %q, which was never saved", token)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	token, _, err := NewFileTokenStore(path).Load()
+	if err != nil || !written[token] {
+		t.Errorf("Load = %q, %v; want one of the saved tokens", token, err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Errorf("directory holds %v, want the token file alone", names)
+	}
+}
+
+func TestClient_TokenStore_shared(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		store func(i int) TokenStore
+	}{
+		{"memory", func() func(int) TokenStore {
+			s := NewMemoryTokenStore()
+			return func(int) TokenStore { return s }
+		}()},
+		{"file", func() func(int) TokenStore {
+			path := filepath.Join(t.TempDir(), "token.json")
+			return func(int) TokenStore { return NewFileTokenStore(path) }
+		}()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			first, mux := setup(t)
+			var tokens int32
+			handleToken(t, mux, "shared", 7200, &tokens)
+
+			var clients []*Client
+			for i := 0; i < 5; i++ {
+				c, err := NewClient(
+					WithApp("org", "app"),
+					WithCredentials("id", "secret"),
+					WithBaseURL(first.BaseURL.String()),
+					WithTokenStore(tt.store(i)),
+				)
+				if err != nil {
+					t.Fatalf("NewClient returned error: %v", err)
+				}
+				clients = append(clients, c)
+			}
+			var wg sync.WaitGroup
+			for _, c := range clients {
+				wg.Add(1)
+				go func(c *Client) {
+					defer wg.Done()
+					if err := c.GetToken(); err != nil {
+						t.Errorf("GetToken returned error: %v", err)
+					}
+				}(c)
+			}
+			wg.Wait()
+
+			if n := atomic.LoadInt32(&tokens); n != 1 {
+				t.Errorf("%d token requests for %d clients sharing a store, want 1", n, len(clients))
+			}
+			for _, c := range clients {
+				if c.Token != "shared" {
+					t.Errorf("client token = %q, want %q", c.Token, "shared")
+				}
+			}
+		})
+	}
+}
@@ -5,7 +5,11 @@
 
 package easemob
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
 // UsersService handles communication with the user related
 // methods of the Easemob API.
@@ -15,26 +19,50 @@ type UsersService struct {
 	client *Client
 }
 
+// UserStatus maps usernames to their connection status, "online" or
+// "offline".
+type UserStatus map[string]string
+
+// Online reports whether username is connected.
+func (s UserStatus) Online(username string) bool {
+	return s[username] == "online"
+}
+
 // Register a user without Authorization
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#im
-func (s *UsersService) RegisterWithoutAuth(username string, password string, nickname string) (*Response, error) {
+func (s *UsersService) RegisterWithoutAuth(username string, password string, nickname string) (*User, *Response, error) {
+	return s.RegisterWithoutAuthContext(context.Background(), username, password, nickname)
+}
+
+// RegisterWithoutAuthContext is like RegisterWithoutAuth but sends the request with ctx.
+func (s *UsersService) RegisterWithoutAuthContext(ctx context.Context, username string, password string, nickname string) (*User, *Response, error) {
 	put := &PutOptions{Username: username, Password: password, Nickname: nickname}
 
 	var u string
 	u = "users"
 
-	req, err := s.client.NewRequestWithoutAuth("POST", u, put)
+	req, err := s.client.NewRequestWithoutAuthContext(ctx, "POST", u, put)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	user, err := resp.firstUser()
+	return user, resp, err
 }
 
 // Register users with Authorization
-func (s *UsersService) Registers(usernames []string, password string) (*Response, error) {
+func (s *UsersService) Registers(usernames []string, password string) ([]*User, *Response, error) {
+	return s.RegistersContext(context.Background(), usernames, password)
+}
+
+// RegistersContext is like Registers but sends the request with ctx.
+func (s *UsersService) RegistersContext(ctx context.Context, usernames []string, password string) ([]*User, *Response, error) {
 	puts := []PutOptions{}
 	for _, username := range usernames {
 		put := PutOptions{Username: username, Password: password}
@@ -44,33 +72,54 @@ func (s *UsersService) Registers(usernames []string, password string) (*Response
 	var u string
 	u = "users"
 
-	req, err := s.client.NewRequest("POST", u, puts)
+	req, err := s.client.NewRequestContext(ctx, "POST", u, puts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var users []*User
+	err = resp.decodeEntities(&users)
+	return users, resp, err
 }
 
 // Register a user with Authorization
-func (s *UsersService) Register(username string, password string) (*Response, error) {
+func (s *UsersService) Register(username string, password string) (*User, *Response, error) {
+	return s.RegisterContext(context.Background(), username, password)
+}
+
+// RegisterContext is like Register but sends the request with ctx.
+func (s *UsersService) RegisterContext(ctx context.Context, username string, password string) (*User, *Response, error) {
 	put := &PutOptions{Username: username, Password: password}
 
 	var u string
 	u = "users"
 
-	req, err := s.client.NewRequest("POST", u, put)
+	req, err := s.client.NewRequestContext(ctx, "POST", u, put)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	user, err := resp.firstUser()
+	return user, resp, err
 }
 
 // group Register a user with Authorization
-func (s *UsersService) RegisterGroup(users map[string]string) (*Response, error) {
+func (s *UsersService) RegisterGroup(users map[string]string) ([]*User, *Response, error) {
+	return s.RegisterGroupContext(context.Background(), users)
+}
+
+// RegisterGroupContext is like RegisterGroup but sends the request with ctx.
+func (s *UsersService) RegisterGroupContext(ctx context.Context, users map[string]string) ([]*User, *Response, error) {
 	puts := []PutOptions{}
 	for username, password := range users {
 		put := PutOptions{Username: username, Password: password}
@@ -80,105 +129,170 @@ func (s *UsersService) RegisterGroup(users map[string]string) (*Response, error)
 	var u string
 	u = "users"
 
-	req, err := s.client.NewRequest("POST", u, puts)
+	req, err := s.client.NewRequestContext(ctx, "POST", u, puts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var created []*User
+	err = resp.decodeEntities(&created)
+	return created, resp, err
 }
 
 // user status online offline
-func (s *UsersService) UserStatus(username string) (*Response, error) {
+func (s *UsersService) UserStatus(username string) (UserStatus, *Response, error) {
+	return s.UserStatusContext(context.Background(), username)
+}
+
+// UserStatusContext is like UserStatus but sends the request with ctx.
+func (s *UsersService) UserStatusContext(ctx context.Context, username string) (UserStatus, *Response, error) {
 	put := &PutOptions{}
 
 	var u string
 	u = "users/" + username + "/status"
 
-	req, err := s.client.NewRequest("GET", u, put)
+	req, err := s.client.NewRequestContext(ctx, "GET", u, put)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var status UserStatus
+	err = resp.decodeData(&status)
+	return status, resp, err
 }
 
 // Disconnect user
-func (s *UsersService) Disconnect(username string) (*Response, error) {
+func (s *UsersService) Disconnect(username string) (bool, *Response, error) {
+	return s.DisconnectContext(context.Background(), username)
+}
+
+// DisconnectContext is like Disconnect but sends the request with ctx.
+func (s *UsersService) DisconnectContext(ctx context.Context, username string) (bool, *Response, error) {
 	put := &PutOptions{}
 
 	var u string
 	u = "users/" + username + "/disconnect"
 
-	req, err := s.client.NewRequest("GET", u, put)
+	req, err := s.client.NewRequestContext(ctx, "GET", u, put)
 	if err != nil {
-		return nil, err
+		return false, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return false, resp, err
+	}
+
+	var data struct {
+		Result bool `json:"result"`
+	}
+	err = resp.decodeData(&data)
+	return data.Result, resp, err
 }
 
 // Get fetches a User.
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#im-2
-func (s *UsersService) Get(owner string) (*Response, error) {
+func (s *UsersService) Get(owner string) (*User, *Response, error) {
+	return s.GetContext(context.Background(), owner)
+}
+
+// GetContext is like Get but sends the request with ctx.
+func (s *UsersService) GetContext(ctx context.Context, owner string) (*User, *Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v", owner)
-	req, err := s.client.NewRequest("GET", u, nil)
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	user, err := resp.firstUser()
+	return user, resp, err
 }
 
 // ListAll lists all Easemob users.
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#im-3
-func (s *UsersService) ListAll(opt *ListOptions) (*Response, error) {
+func (s *UsersService) ListAll(opt *ListOptions) ([]*User, *Response, error) {
+	return s.ListAllContext(context.Background(), opt)
+}
+
+// ListAllContext is like ListAll but sends the request with ctx.
+func (s *UsersService) ListAllContext(ctx context.Context, opt *ListOptions) ([]*User, *Response, error) {
 	u, err := addOptions("users", opt)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	req, err := s.client.NewRequest("GET", u, nil)
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return resp, err
+		return nil, resp, err
 	}
-	return resp, err
+
+	var users []*User
+	err = resp.decodeEntities(&users)
+	return users, resp, err
 }
 
 // Delete a user.
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#im-5
-func (s *UsersService) Delete(owner string) (*Response, error) {
+func (s *UsersService) Delete(owner string) (*User, *Response, error) {
+	return s.DeleteContext(context.Background(), owner)
+}
+
+// DeleteContext is like Delete but sends the request with ctx.
+func (s *UsersService) DeleteContext(ctx context.Context, owner string) (*User, *Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v", owner)
-	req, err := s.client.NewRequest("DELETE", u, nil)
+	req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
 	}
-	return s.client.Do(req)
+
+	user, err := resp.firstUser()
+	return user, resp, err
 }
 
 // ResetPassword
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#resetpassword
 func (s *UsersService) ResetPassword(owner string, password string) (*Response, error) {
+	return s.ResetPasswordContext(context.Background(), owner, password)
+}
+
+// ResetPasswordContext is like ResetPassword but sends the request with ctx.
+func (s *UsersService) ResetPasswordContext(ctx context.Context, owner string, password string) (*Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v/password", owner)
 
 	opt := &PutOptions{NewPass: password}
-	req, err := s.client.NewRequest("PUT", u, opt)
+	req, err := s.client.NewRequestContext(ctx, "PUT", u, opt)
 	if err != nil {
 		return nil, err
 	}
@@ -190,164 +304,428 @@ func (s *UsersService) ResetPassword(owner string, password string) (*Response,
 // EditUsername
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#nickname
-func (s *UsersService) EditNickname(owner string, nickname string) (*Response, error) {
+func (s *UsersService) EditNickname(owner string, nickname string) (*User, *Response, error) {
+	return s.EditNicknameContext(context.Background(), owner, nickname)
+}
+
+// EditNicknameContext is like EditNickname but sends the request with ctx.
+func (s *UsersService) EditNicknameContext(ctx context.Context, owner string, nickname string) (*User, *Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v", owner)
 
 	opt := &PutOptions{Nickname: nickname}
-	req, err := s.client.NewRequest("PUT", u, opt)
+	req, err := s.client.NewRequestContext(ctx, "PUT", u, opt)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	user, err := resp.firstUser()
+	return user, resp, err
 }
 
 // AddFriend add a friend.
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#contacts
-func (s *UsersService) AddFriend(owner string, friend string) (*Response, error) {
+func (s *UsersService) AddFriend(owner string, friend string) (*User, *Response, error) {
+	return s.AddFriendContext(context.Background(), owner, friend)
+}
+
+// AddFriendContext is like AddFriend but sends the request with ctx.
+func (s *UsersService) AddFriendContext(ctx context.Context, owner string, friend string) (*User, *Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v/contacts/users/%v", owner, friend)
 
-	req, err := s.client.NewRequest("POST", u, nil)
+	req, err := s.client.NewRequestContext(ctx, "POST", u, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	user, err := resp.firstUser()
+	return user, resp, err
 }
 
 // DeleteFriend
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#delfriend
-func (s *UsersService) DeleteFriend(owner string, friend string) (*Response, error) {
+func (s *UsersService) DeleteFriend(owner string, friend string) (*User, *Response, error) {
+	return s.DeleteFriendContext(context.Background(), owner, friend)
+}
+
+// DeleteFriendContext is like DeleteFriend but sends the request with ctx.
+func (s *UsersService) DeleteFriendContext(ctx context.Context, owner string, friend string) (*User, *Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v/contacts/users/%v", owner, friend)
 
-	req, err := s.client.NewRequest("DELETE", u, nil)
+	req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	user, err := resp.firstUser()
+	return user, resp, err
 }
 
-// DeleteFriends
+// DeleteByQuery deletes the users matching q, up to the limit of q, and
+// returns them.
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#im-6
-// func (s *UsersService) DeleteFriends(start time.Time, end time.Time) (*Response, error) {
-//     var u string
-//     u = "users"
+func (s *UsersService) DeleteByQuery(q Query) ([]*User, *Response, error) {
+	return s.DeleteByQueryContext(context.Background(), q)
+}
+
+// DeleteByQueryContext is like DeleteByQuery but sends the request with ctx.
+func (s *UsersService) DeleteByQueryContext(ctx context.Context, q Query) ([]*User, *Response, error) {
+	u, err := addOptions("users", q.ListOptions())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
 
-//     // opt := &ListOptions{QL: fmt.Sprintf("created>%d", ...)}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
 
-// }
+	var users []*User
+	err = resp.decodeEntities(&users)
+	return users, resp, err
+}
+
+// DeleteCreatedBetween deletes the users created after start and before
+// end, up to limit of them, and returns them.
+//
+// Easemob API docs: http://www.easemob.com/docs/rest/userapi/#im-6
+func (s *UsersService) DeleteCreatedBetween(start time.Time, end time.Time, limit int) ([]*User, *Response, error) {
+	return s.DeleteCreatedBetweenContext(context.Background(), start, end, limit)
+}
+
+// DeleteCreatedBetweenContext is like DeleteCreatedBetween but sends the
+// request with ctx.
+func (s *UsersService) DeleteCreatedBetweenContext(ctx context.Context, start time.Time, end time.Time, limit int) ([]*User, *Response, error) {
+	return s.DeleteByQueryContext(ctx, Query{}.CreatedBetween(start, end).Limit(limit))
+}
+
+// DeleteBatch deletes up to limit users, oldest first, and returns them.
+//
+// Easemob API docs: http://www.easemob.com/docs/rest/userapi/#im-6
+func (s *UsersService) DeleteBatch(limit int) ([]*User, *Response, error) {
+	return s.DeleteBatchContext(context.Background(), limit)
+}
+
+// DeleteBatchContext is like DeleteBatch but sends the request with ctx.
+func (s *UsersService) DeleteBatchContext(ctx context.Context, limit int) ([]*User, *Response, error) {
+	u, err := addOptions("users", &ListOptions{Limit: limit})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var users []*User
+	err = resp.decodeEntities(&users)
+	return users, resp, err
+}
+
+// defaultDeleteBatchSize is the number of users DeleteAll removes per
+// request unless told otherwise.
+const defaultDeleteBatchSize = 100
+
+// DeleteAllOptions specifies the optional parameters to DeleteAll.
+type DeleteAllOptions struct {
+	// BatchSize is the number of users deleted per request. It overrides
+	// the limit of the filter. Defaults to 100.
+	BatchSize int
+
+	// Progress, if set, is called after each batch with the users of the
+	// batch and the number of users handled so far.
+	Progress func(batch []*User, total int)
+
+	// DryRun lists the users matching the filter instead of deleting them.
+	DryRun bool
+}
+
+// DeleteAll deletes every user matching filter, batch after batch until
+// none are left, and returns the deleted users. On error, the users deleted
+// before it are returned along with it.
+func (s *UsersService) DeleteAll(ctx context.Context, filter Query, opt *DeleteAllOptions) ([]*User, error) {
+	if opt == nil {
+		opt = &DeleteAllOptions{}
+	}
+	size := opt.BatchSize
+	if size <= 0 {
+		size = defaultDeleteBatchSize
+	}
+	filter = filter.Limit(size)
+
+	var all []*User
+	report := func(batch []*User) {
+		all = append(all, batch...)
+		if opt.Progress != nil {
+			opt.Progress(batch, len(all))
+		}
+	}
+
+	if opt.DryRun {
+		it := s.Iter(ctx, filter.ListOptions())
+		var batch []*User
+		for it.Next() {
+			if batch = append(batch, it.Value()); len(batch) == size {
+				report(batch)
+				batch = nil
+			}
+		}
+		if len(batch) > 0 {
+			report(batch)
+		}
+		return all, it.Err()
+	}
+
+	for {
+		batch, _, err := s.DeleteByQueryContext(ctx, filter)
+		if err != nil {
+			return all, err
+		}
+		if len(batch) == 0 {
+			return all, nil
+		}
+		report(batch)
+	}
+}
 
 // GetFriends
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#queryfriend
-func (s *UsersService) GetFriends(owner string) (*Response, error) {
+func (s *UsersService) GetFriends(owner string) ([]string, *Response, error) {
+	return s.GetFriendsContext(context.Background(), owner)
+}
+
+// GetFriendsContext is like GetFriends but sends the request with ctx.
+func (s *UsersService) GetFriendsContext(ctx context.Context, owner string) ([]string, *Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v/contacts/users", owner)
 
-	req, err := s.client.NewRequest("GET", u, nil)
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var friends []string
+	err = resp.decodeData(&friends)
+	return friends, resp, err
 }
 
 // GetBlocks
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#blocksusers
-func (s *UsersService) GetBlocks(owner string) (*Response, error) {
+func (s *UsersService) GetBlocks(owner string) ([]string, *Response, error) {
+	return s.GetBlocksContext(context.Background(), owner)
+}
+
+// GetBlocksContext is like GetBlocks but sends the request with ctx.
+func (s *UsersService) GetBlocksContext(ctx context.Context, owner string) ([]string, *Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v/blocks/users", owner)
 
-	req, err := s.client.NewRequest("GET", u, nil)
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var blocks []string
+	err = resp.decodeData(&blocks)
+	return blocks, resp, err
+}
+
+// BlockListOptions specifies the page of a block list to retrieve.
+type BlockListOptions struct {
+	PageSize int    `url:"pageSize,omitempty"`
+	Cursor   string `url:"cursor,omitempty"`
+}
+
+// GetBlocksPage lists a page of the users blocked by owner. The cursor of
+// the next page is returned in Response.Cursor.
+//
+// Easemob API docs: http://docs-im.easemob.com/im/server/ready/user#获取黑名单列表
+func (s *UsersService) GetBlocksPage(owner string, opt *BlockListOptions) ([]string, *Response, error) {
+	return s.GetBlocksPageContext(context.Background(), owner, opt)
+}
+
+// GetBlocksPageContext is like GetBlocksPage but sends the request with ctx.
+func (s *UsersService) GetBlocksPageContext(ctx context.Context, owner string, opt *BlockListOptions) ([]string, *Response, error) {
+	u, err := addOptions(fmt.Sprintf("users/%v/blocks/users", owner), opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var blocks []string
+	err = resp.decodeData(&blocks)
+	return blocks, resp, err
 }
 
 // AddBlocks
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#addblocksusers
-func (s *UsersService) AddBlocks(owner string, usernames []string) (*Response, error) {
+func (s *UsersService) AddBlocks(owner string, usernames []string) ([]string, *Response, error) {
+	return s.AddBlocksContext(context.Background(), owner, usernames)
+}
+
+// AddBlocksContext is like AddBlocks but sends the request with ctx.
+func (s *UsersService) AddBlocksContext(ctx context.Context, owner string, usernames []string) ([]string, *Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v/blocks/users", owner)
 
 	opt := &PutOptions{Usernames: usernames}
-	req, err := s.client.NewRequest("POST", u, opt)
+	req, err := s.client.NewRequestContext(ctx, "POST", u, opt)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var blocks []string
+	err = resp.decodeData(&blocks)
+	return blocks, resp, err
 }
 
 // DeleteBlock
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#delblocksusers
-func (s *UsersService) DeleteBlock(owner string, friend string) (*Response, error) {
+func (s *UsersService) DeleteBlock(owner string, friend string) (*User, *Response, error) {
+	return s.DeleteBlockContext(context.Background(), owner, friend)
+}
+
+// DeleteBlockContext is like DeleteBlock but sends the request with ctx.
+func (s *UsersService) DeleteBlockContext(ctx context.Context, owner string, friend string) (*User, *Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v/blocks/users/%v", owner, friend)
 
-	req, err := s.client.NewRequest("DELETE", u, nil)
+	req, err := s.client.NewRequestContext(ctx, "DELETE", u, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	user, err := resp.firstUser()
+	return user, resp, err
 }
 
 // Status
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/sendmessage/#status
-func (s *UsersService) Status(owner string) (*Response, error) {
+func (s *UsersService) Status(owner string) (UserStatus, *Response, error) {
+	return s.StatusContext(context.Background(), owner)
+}
+
+// StatusContext is like Status but sends the request with ctx.
+func (s *UsersService) StatusContext(ctx context.Context, owner string) (UserStatus, *Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v/status", owner)
 
-	req, err := s.client.NewRequest("GET", u, nil)
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var status UserStatus
+	err = resp.decodeData(&status)
+	return status, resp, err
 }
 
 // UserGroups
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/groups/#joinedchatgroups
-func (s *GroupService) UserGroups(user string) (*Response, error) {
+func (s *GroupService) UserGroups(user string) ([]*Group, *Response, error) {
+	return s.UserGroupsContext(context.Background(), user)
+}
+
+// UserGroupsContext is like UserGroups but sends the request with ctx.
+func (s *GroupService) UserGroupsContext(ctx context.Context, user string) ([]*Group, *Response, error) {
 	var u string
 	u = fmt.Sprintf("users/%v/joined_chatgroups", user)
 
-	req, err := s.client.NewRequest("GET", u, nil)
+	req, err := s.client.NewRequestContext(ctx, "GET", u, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var groups []*Group
+	err = resp.decodeData(&groups)
+	return groups, resp, err
 }
 
 // Offline Message Count
 //
 // Easemob API docs: http://www.easemob.com/docs/rest/userapi/#msgcount
-func (s *UsersService) OfflineMsgCount(username string) (*Response, error) {
+func (s *UsersService) OfflineMsgCount(username string) (int, *Response, error) {
+	return s.OfflineMsgCountContext(context.Background(), username)
+}
+
+// OfflineMsgCountContext is like OfflineMsgCount but sends the request with ctx.
+func (s *UsersService) OfflineMsgCountContext(ctx context.Context, username string) (int, *Response, error) {
 
 	var (
 		url string
@@ -355,11 +733,17 @@ func (s *UsersService) OfflineMsgCount(username string) (*Response, error) {
 
 	url = fmt.Sprintf("users/%v/offline_msg_count", username)
 
-	req, err := s.client.NewRequest("GET", url, nil)
+	req, err := s.client.NewRequestContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 
 	resp, err := s.client.Do(req)
-	return resp, err
+	if err != nil {
+		return 0, resp, err
+	}
+
+	var counts map[string]int
+	err = resp.decodeData(&counts)
+	return counts[username], resp, err
 }
@@ -0,0 +1,168 @@
+// Copyright 2015 The go-easemob AUTHORS. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package easemob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// userStore serves listing and deletion of the users endpoint from a list
+// of usernames, oldest first.
+type userStore struct {
+	users   []string
+	deletes []int // limit of each delete request
+}
+
+func handleUserStore(t *testing.T, mux *http.ServeMux, n int) *userStore {
+	s := new(userStore)
+	for i := 0; i < n; i++ {
+		s.users = append(s.users, fmt.Sprintf("u%02d", i))
+	}
+	mux.HandleFunc("/org/app/users", func(w http.ResponseWriter, r *http.Request) {
+		if want := "select * where created > 1500000000000"; r.FormValue("ql") != want {
+			t.Errorf("ql = %q, want %q", r.FormValue("ql"), want)
+		}
+		limit, _ := strconv.Atoi(r.FormValue("limit"))
+		start, _ := strconv.Atoi(r.FormValue("cursor"))
+		end := start + limit
+		if end > len(s.users) {
+			end = len(s.users)
+		}
+		page := s.users[start:end]
+
+		body := map[string]interface{}{}
+		switch r.Method {
+		case "GET":
+			if end < len(s.users) {
+				body["cursor"] = strconv.Itoa(end)
+			}
+		case "DELETE":
+			s.deletes = append(s.deletes, limit)
+			s.users = s.users[end:]
+		default:
+			t.Errorf("Request method: %v", r.Method)
+		}
+		var entities []map[string]string
+		for _, u := range page {
+			entities = append(entities, map[string]string{"username": u})
+		}
+		body["entities"] = entities
+		json.NewEncoder(w).Encode(body)
+	})
+	return s
+}
+
+func usernames(users []*User) []string {
+	var names []string
+	for _, u := range users {
+		names = append(names, u.Username)
+	}
+	return names
+}
+
+var deleteFilter = Query{}.CreatedAfter(time.Unix(1500000000, 0))
+
+func TestUsersService_DeleteAll(t *testing.T) {
+	client, mux := setup(t)
+	store := handleUserStore(t, mux, 25)
+	want := append([]string(nil), store.users...)
+
+	var batches []int
+	var totals []int
+	deleted, err := client.Users.DeleteAll(context.Background(), deleteFilter, &DeleteAllOptions{
+		BatchSize: 10,
+		Progress: func(batch []*User, total int) {
+			batches = append(batches, len(batch))
+			totals = append(totals, total)
+		},
+	})
+	if err != nil {
+		t.Fatalf("DeleteAll returned error: %v", err)
+	}
+	if got := usernames(deleted); !reflect.DeepEqual(got, want) {
+		t.Errorf("DeleteAll returned %v, want %v", got, want)
+	}
+	if len(store.users) != 0 {
+		t.Errorf("users left: %v", store.users)
+	}
+	// The last request finds no users left.
+	if want := []int{10, 10, 10, 10}; !reflect.DeepEqual(store.deletes, want) {
+		t.Errorf("delete limits = %v, want %v", store.deletes, want)
+	}
+	if want := []int{10, 10, 5}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("Progress saw batches of %v, want %v", batches, want)
+	}
+	if want := []int{10, 20, 25}; !reflect.DeepEqual(totals, want) {
+		t.Errorf("Progress saw totals %v, want %v", totals, want)
+	}
+}
+
+func TestUsersService_DeleteAll_defaultBatchSize(t *testing.T) {
+	client, mux := setup(t)
+	store := handleUserStore(t, mux, 3)
+
+	if _, err := client.Users.DeleteAll(context.Background(), deleteFilter.Limit(1), nil); err != nil {
+		t.Fatalf("DeleteAll returned error: %v", err)
+	}
+	if want := []int{defaultDeleteBatchSize, defaultDeleteBatchSize}; !reflect.DeepEqual(store.deletes, want) {
+		t.Errorf("delete limits = %v, want %v", store.deletes, want)
+	}
+}
+
+func TestUsersService_DeleteAll_dryRun(t *testing.T) {
+	client, mux := setup(t)
+	store := handleUserStore(t, mux, 25)
+	want := append([]string(nil), store.users...)
+
+	var batches [][]string
+	listed, err := client.Users.DeleteAll(context.Background(), deleteFilter, &DeleteAllOptions{
+		BatchSize: 10,
+		DryRun:    true,
+		Progress: func(batch []*User, total int) {
+			batches = append(batches, usernames(batch))
+		},
+	})
+	if err != nil {
+		t.Fatalf("DeleteAll returned error: %v", err)
+	}
+	if got := usernames(listed); !reflect.DeepEqual(got, want) {
+		t.Errorf("DeleteAll returned %v, want %v", got, want)
+	}
+	if len(store.deletes) != 0 || len(store.users) != 25 {
+		t.Errorf("dry run sent %d deletes, %d users left", len(store.deletes), len(store.users))
+	}
+	if wantBatches := [][]string{want[:10], want[10:20], want[20:]}; !reflect.DeepEqual(batches, wantBatches) {
+		t.Errorf("Progress saw %v, want %v", batches, wantBatches)
+	}
+}
+
+func TestUsersService_DeleteAll_error(t *testing.T) {
+	client, mux := setup(t)
+	var n int
+	mux.HandleFunc("/org/app/users", func(w http.ResponseWriter, r *http.Request) {
+		if n++; n > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"illegal_argument"}`)
+			return
+		}
+		fmt.Fprint(w, `{"entities":[{"username":"alice"}]}`)
+	})
+
+	deleted, err := client.Users.DeleteAll(context.Background(), Query{}, nil)
+	if err == nil {
+		t.Fatal("DeleteAll returned no error")
+	}
+	if got := usernames(deleted); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Errorf("DeleteAll returned %v along with its error, want [alice]", got)
+	}
+}